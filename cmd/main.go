@@ -5,25 +5,40 @@ import (
 	"database/sql"
 	"fmt"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
+	"metachat/chat-service/internal/auth"
+	"metachat/chat-service/internal/cache"
+	"metachat/chat-service/internal/events"
 	grpcServer "metachat/chat-service/internal/grpc"
+	"metachat/chat-service/internal/jobs"
+	"metachat/chat-service/internal/metrics"
+	"metachat/chat-service/internal/ratelimit"
 	"metachat/chat-service/internal/repository"
 	"metachat/chat-service/internal/service"
+	"metachat/chat-service/internal/tracing"
 
 	pb "github.com/kegazani/metachat-proto/chat"
 	"github.com/sirupsen/logrus"
 )
 
+var schemaNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
 func main() {
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("")
@@ -67,6 +82,7 @@ func main() {
 	dbPassword := viper.GetString("database.password")
 	dbName := viper.GetString("database.dbname")
 	sslmode := viper.GetString("database.sslmode")
+	dbSchema := viper.GetString("database.schema")
 
 	if dbHost == "" {
 		dbHost = "localhost"
@@ -86,14 +102,27 @@ func main() {
 	if sslmode == "" {
 		sslmode = "disable"
 	}
+	if dbSchema == "" {
+		dbSchema = "public"
+	}
+	if !schemaNamePattern.MatchString(dbSchema) {
+		logger.Fatalf("Invalid database schema name: %q", dbSchema)
+	}
 
 	dsn := "postgres://" + dbUser + ":" + dbPassword + "@" + dbHost + ":" +
-		strings.TrimSpace(strings.Replace(fmt.Sprintf("%d", dbPort), " ", "", -1)) + "/" + dbName + "?sslmode=" + sslmode
+		strings.TrimSpace(strings.Replace(fmt.Sprintf("%d", dbPort), " ", "", -1)) + "/" + dbName + "?sslmode=" + sslmode +
+		"&search_path=" + url.QueryEscape(dbSchema) + "&timezone=UTC"
 
-	db, err := sql.Open("postgres", dsn)
+	connector, err := pq.NewConnector(dsn)
 	if err != nil {
 		logger.Fatalf("Failed to connect to database: %v", err)
 	}
+
+	viper.SetDefault("database.connect_retries", 5)
+	viper.SetDefault("database.connect_retry_delay", 200*time.Millisecond)
+
+	db := sql.OpenDB(repository.NewRetryingConnector(connector,
+		viper.GetInt("database.connect_retries"), viper.GetDuration("database.connect_retry_delay")))
 	defer db.Close()
 
 	db.SetMaxOpenConns(25)
@@ -106,13 +135,134 @@ func main() {
 
 	logger.Info("Connected to PostgreSQL database")
 
-	chatRepo := repository.NewChatRepository(db)
-	if err := chatRepo.InitializeTables(); err != nil {
-		logger.Fatalf("Failed to initialize database tables: %v", err)
+	viper.SetDefault("database.migrate_on_boot", true)
+
+	migrateOnly := len(os.Args) > 1 && os.Args[1] == "migrate"
+	if err := runMigrations(db, dbSchema, migrateOnly || viper.GetBool("database.migrate_on_boot"), logger); err != nil {
+		// Reversed pair duplicates make the pair normalization migration
+		// fail; say how many there are before giving up.
+		if viper.GetBool("database.check_pair_normalization") {
+			checkPairNormalization(repository.NewChatRepository(db, repository.Options{Schema: dbSchema}), logger)
+		}
+		logger.Fatalf("Failed to migrate database: %v", err)
+	}
+	if migrateOnly {
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(),
+		viper.GetString("tracing.otlp_endpoint"), "chat-service", viper.GetBool("tracing.insecure"))
+	if err != nil {
+		logger.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	viper.SetDefault("database.query_timeout", 5*time.Second)
+
+	chatRepo := repository.NewTracedRepository(repository.NewChatRepository(db, repository.Options{
+		Schema:         dbSchema,
+		AuditLog:       viper.GetBool("audit.enabled"),
+		QueryTimeout:   viper.GetDuration("database.query_timeout"),
+		FullTextSearch: viper.GetBool("database.full_text_search"),
+	}))
+	if err := chatRepo.EnsureSearchIndex(); err != nil {
+		logger.Fatalf("Failed to create search index: %v", err)
+	}
+
+	if addr := viper.GetString("cache.redis.addr"); addr != "" {
+		viper.SetDefault("cache.ttl", 30*time.Second)
+		viper.SetDefault("cache.redis.timeout", 100*time.Millisecond)
+		viper.SetDefault("cache.breaker.failure_threshold", 5)
+		viper.SetDefault("cache.breaker.cooldown", 10*time.Second)
+
+		// Short timeouts keep a slow or unreachable Redis from adding more
+		// than a little latency before reads fall through to Postgres.
+		redisTimeout := viper.GetDuration("cache.redis.timeout")
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:         addr,
+			Password:     viper.GetString("cache.redis.password"),
+			DB:           viper.GetInt("cache.redis.db"),
+			DialTimeout:  redisTimeout,
+			ReadTimeout:  redisTimeout,
+			WriteTimeout: redisTimeout,
+		})
+		defer redisClient.Close()
+
+		if err := redisClient.Ping(context.Background()).Err(); err != nil {
+			logger.WithError(err).Warn("Redis is unreachable, chat reads will fall through to the database")
+		}
+
+		store := cache.NewBreaker(
+			cache.NewRedis(redisClient, "chat-service:"),
+			viper.GetInt("cache.breaker.failure_threshold"),
+			viper.GetDuration("cache.breaker.cooldown"),
+		)
+		chatRepo = repository.NewCachedRepository(chatRepo, store, viper.GetDuration("cache.ttl"), logger)
+		logger.WithField("addr", addr).Info("Chat cache enabled")
+	}
+
+	if viper.GetBool("database.check_pair_normalization") {
+		checkPairNormalization(chatRepo, logger)
+	}
+
+	viper.SetDefault("chats.include_empty", true)
+	viper.SetDefault("messages.announcements_notify_muted", true)
+
+	serviceConfig := service.Config{
+		ContentHashing:     viper.GetBool("messages.content_hashing"),
+		DuplicateIDPolicy:  viper.GetString("messages.duplicate_id_policy"),
+		LanguageDetection:  viper.GetString("messages.language_detection"),
+		AllowedLanguages:   viper.GetStringSlice("messages.allowed_languages"),
+		MaxMessagesPerChat: viper.GetInt64("messages.max_per_chat"),
+		MaxMessageLength:   viper.GetInt("chat.max_message_length"),
+		LengthMode:         viper.GetString("messages.length_mode"),
+		OversizePolicy:     viper.GetString("messages.oversize_policy"),
+		TruncationMarker:   viper.GetString("messages.truncation_marker"),
+		JoinMarkers:        viper.GetBool("messages.join_markers"),
+		MaxPinsPerChat:     viper.GetInt("messages.max_pins_per_chat"),
+		MaxReplyDepth:      viper.GetInt("messages.max_reply_depth"),
+
+		AnnouncementsNotifyMuted: viper.GetBool("messages.announcements_notify_muted"),
+
+		EditHistoryVisibility: viper.GetString("messages.edit_history_visibility"),
+		ReactionsOnDelete:     viper.GetString("messages.reactions_on_delete"),
+
+		AllowedAttachmentTypes: viper.GetStringSlice("messages.attachments.allowed_mime_types"),
+		MaxAttachmentSize:      viper.GetInt64("messages.attachments.max_size_bytes"),
+
+		DefaultWriteConcern: service.WriteConcern(viper.GetString("messages.write_concern")),
+		AllowFastWrites:     viper.GetBool("messages.allow_fast_writes"),
+
+		AllowedChatSources:  viper.GetStringSlice("chats.allowed_sources"),
+		UnknownSourcePolicy: viper.GetString("chats.unknown_source_policy"),
+		IncludeEmptyChats:   viper.GetBool("chats.include_empty"),
+		HideBlockedChats:    viper.GetBool("chats.hide_blocked"),
+		ChatListHardCap:     viper.GetInt("chats.list_hard_cap"),
+		MaxChatBatchSize:    viper.GetInt("chats.max_batch_size"),
+
+		StreamBufferSize:    viper.GetInt("streaming.buffer_size"),
+		TypingTTL:           viper.GetDuration("streaming.typing_ttl"),
+		SlowConsumerPolicy:  viper.GetString("streaming.slow_consumer_policy"),
+		SlowConsumerTimeout: viper.GetDuration("streaming.slow_consumer_timeout"),
+		FanOutWorkers:       viper.GetInt("streaming.fan_out_workers"),
+	}
+
+	if perMinute := viper.GetInt("chat.rate_limit_per_minute"); perMinute > 0 {
+		serviceConfig.RateLimiter = ratelimit.NewMemoryLimiter(perMinute)
+		serviceConfig.RateLimitWarnRatio = viper.GetFloat64("chat.rate_limit_warn_ratio")
+		logger.Infof("Limiting messages to %d per minute per sender", perMinute)
 	}
 
-	chatService := service.NewChatService(chatRepo, logger)
-	grpcSrv := grpcServer.NewChatServer(chatService, logger)
+	publisher := events.NewNoopPublisher()
+	if brokers := viper.GetStringSlice("events.kafka.brokers"); len(brokers) > 0 {
+		publisher = events.NewKafkaPublisher(brokers, viper.GetString("events.kafka.topic"), logger)
+		logger.Infof("Publishing message events to Kafka topic %s", viper.GetString("events.kafka.topic"))
+	}
+	serviceConfig.Publisher = publisher
+
+	chatService := service.NewChatService(chatRepo, serviceConfig, logger)
+	grpcSrv := grpcServer.NewChatServer(chatService, grpcServer.Options{
+		ExposeInternalErrors: viper.GetBool("errors.expose_internal"),
+	}, logger)
 
 	port := viper.GetString("server.port")
 	if port == "" {
@@ -130,8 +280,54 @@ func main() {
 		logger.Fatalf("Failed to listen on %s: %v", address, err)
 	}
 
-	s := grpc.NewServer()
+	methodLevels := grpcServer.ParseMethodLevels(viper.GetStringMapString("logging.method_levels"), logger)
+
+	compression := viper.GetString("grpc.compression")
+	if compression == "" {
+		compression = grpcServer.CompressionAllow
+	}
+
+	interceptors := []grpc.UnaryServerInterceptor{
+		grpcServer.UnaryTracingInterceptor(),
+		grpcServer.UnaryMetricsInterceptor(),
+		grpcServer.UnaryLoggingInterceptor(logger, methodLevels),
+		grpcServer.UnaryCompressionInterceptor(compression),
+	}
+
+	var streamInterceptors []grpc.StreamServerInterceptor
+
+	if viper.GetBool("auth.enabled") {
+		verifier, err := loadAuthVerifier()
+		if err != nil {
+			logger.Fatalf("Failed to load auth public key: %v", err)
+		}
+		interceptors = append(interceptors, grpcServer.UnaryAuthInterceptor(verifier, logger))
+		streamInterceptors = append(streamInterceptors, grpcServer.StreamAuthInterceptor(verifier, logger))
+		logger.Info("JWT authentication enabled")
+	}
+
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
 	pb.RegisterChatServiceServer(s, grpcSrv)
+	s.RegisterService(&grpcServer.AuditServiceDesc, grpcSrv)
+	s.RegisterService(&grpcServer.StreamServiceDesc, grpcSrv)
+
+	healthSrv := health.NewServer()
+	healthpb.RegisterHealthServer(s, healthSrv)
+
+	viper.SetDefault("jobs.database_health.enabled", true)
+	viper.SetDefault("jobs.database_health.interval", 10*time.Second)
+
+	jobRunner := jobs.NewRunner(viper.GetInt("jobs.max_concurrency"), logger)
+	jobRunner.Register(jobs.Job{
+		Name:     "database_health",
+		Enabled:  viper.GetBool("jobs.database_health.enabled"),
+		Interval: viper.GetDuration("jobs.database_health.interval"),
+		Run:      grpcServer.DatabaseHealthCheck(db, healthSrv, 5*time.Second, viper.GetDuration("jobs.database_health.interval"), logger),
+	})
+	jobRunner.Start(context.Background())
 
 	if viper.GetBool("grpc.reflection_enabled") {
 		reflection.Register(s)
@@ -145,12 +341,35 @@ func main() {
 		}
 	}()
 
+	metricsPort := viper.GetString("metrics.port")
+	if metricsPort == "" {
+		metricsPort = "9090"
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsSrv := &http.Server{
+		Addr:              net.JoinHostPort(host, metricsPort),
+		Handler:           metricsMux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Infof("Starting metrics server on %s", metricsSrv.Addr)
+		if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatalf("Failed to start metrics server: %v", err)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logger.Info("Shutting down gRPC server...")
 
+	jobRunner.Stop()
+	healthSrv.Shutdown()
+
 	shutdownTimeout := viper.GetDuration("grpc.shutdown_timeout")
 	if shutdownTimeout == 0 {
 		shutdownTimeout = 10 * time.Second
@@ -172,6 +391,92 @@ func main() {
 		logger.Info("gRPC server shutdown timeout")
 	}
 
+	if err := chatService.WaitForPendingWrites(ctx); err != nil {
+		logger.WithError(err).Error("Gave up waiting for acknowledged messages to be stored")
+	}
+
+	if err := metricsSrv.Shutdown(ctx); err != nil {
+		logger.WithError(err).Warn("Failed to shut down metrics server")
+	}
+
+	if err := publisher.Close(); err != nil {
+		logger.WithError(err).Warn("Failed to flush event publisher")
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.WithError(err).Warn("Failed to flush traces")
+	}
+
 	logger.Info("Server exited")
 }
 
+// runMigrations applies pending migrations, or with apply unset only checks
+// that there are none so the service doesn't start against an old schema.
+func runMigrations(db *sql.DB, schema string, apply bool, logger *logrus.Logger) error {
+	ctx := context.Background()
+
+	migrator, err := repository.NewMigrator(ctx, db, schema)
+	if err != nil {
+		return err
+	}
+
+	if !apply {
+		pending, err := migrator.HasPending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return fmt.Errorf("database has pending migrations; run \"chat-service migrate\" or enable database.migrate_on_boot")
+		}
+		return nil
+	}
+
+	results, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		logger.WithFields(logrus.Fields{
+			"version":  result.Source.Version,
+			"duration": result.Duration,
+		}).Info("Applied database migration")
+	}
+
+	return nil
+}
+
+func checkPairNormalization(repo repository.ChatRepository, logger *logrus.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report, err := repo.CheckPairNormalization(ctx)
+	if err != nil {
+		logger.WithError(err).Warn("Failed to check chat participant pair normalization")
+		return
+	}
+
+	if report.ReversedDuplicates > 0 {
+		logger.WithField("offending_rows", report.ReversedDuplicates).
+			Warn("Chats table contains reversed participant pair duplicates")
+	}
+	if !report.NormalizedConstraint {
+		logger.Warn("Chats table has no normalized unique constraint on the participant pair")
+	}
+}
+
+func loadAuthVerifier() (*auth.Verifier, error) {
+	publicKey := viper.GetString("auth.public_key")
+	if publicKey == "" {
+		path := viper.GetString("auth.public_key_path")
+		if path == "" {
+			return nil, fmt.Errorf("auth.public_key or auth.public_key_path must be set")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		publicKey = string(data)
+	}
+
+	return auth.NewVerifier([]byte(publicKey), viper.GetString("auth.service_role"))
+}
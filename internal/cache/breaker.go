@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrBreakerOpen = errors.New("cache circuit breaker is open")
+
+// Breaker stops calling a failing Store for a while so an unreachable cache
+// costs callers nothing instead of a timeout per call. After threshold
+// consecutive failures every call fails fast with ErrBreakerOpen for
+// cooldown; the first call after that goes through again, and another
+// failure reopens the breaker. Misses count as successes.
+type Breaker struct {
+	next      Store
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewBreaker(next Store, threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &Breaker{next: next, threshold: threshold, cooldown: cooldown}
+}
+
+func (b *Breaker) Get(ctx context.Context, key, field string) ([]byte, error) {
+	if !b.allow() {
+		return nil, ErrBreakerOpen
+	}
+	value, err := b.next.Get(ctx, key, field)
+	b.record(err)
+	return value, err
+}
+
+func (b *Breaker) Set(ctx context.Context, key, field string, value []byte, ttl time.Duration) error {
+	if !b.allow() {
+		return ErrBreakerOpen
+	}
+	err := b.next.Set(ctx, key, field, value, ttl)
+	b.record(err)
+	return err
+}
+
+func (b *Breaker) Delete(ctx context.Context, keys ...string) error {
+	if !b.allow() {
+		return ErrBreakerOpen
+	}
+	err := b.next.Delete(ctx, keys...)
+	b.record(err)
+	return err
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !time.Now().Before(b.openUntil)
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || errors.Is(err, ErrMiss) {
+		b.failures = 0
+		return
+	}
+	// The caller giving up says nothing about the store's health.
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
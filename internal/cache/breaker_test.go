@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errDown = errors.New("connection refused")
+
+type fakeStore struct {
+	err   error
+	calls int
+}
+
+func (s *fakeStore) Get(context.Context, string, string) ([]byte, error) {
+	s.calls++
+	return nil, s.err
+}
+
+func (s *fakeStore) Set(context.Context, string, string, []byte, time.Duration) error {
+	s.calls++
+	return s.err
+}
+
+func (s *fakeStore) Delete(context.Context, ...string) error {
+	s.calls++
+	return s.err
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		errs      []error
+		wantOpen  bool
+		wantCalls int
+	}{
+		{"successes", []error{nil, nil, nil}, false, 3},
+		{"misses are not failures", []error{ErrMiss, ErrMiss, ErrMiss}, false, 3},
+		{"cancellations are not failures", []error{context.Canceled, context.Canceled, context.Canceled}, false, 3},
+		{"below threshold", []error{errDown, errDown}, false, 2},
+		{"success resets the count", []error{errDown, errDown, nil, errDown, errDown}, false, 5},
+		{"threshold reached", []error{errDown, errDown, errDown}, true, 3},
+		{"timeouts count", []error{context.DeadlineExceeded, context.DeadlineExceeded, context.DeadlineExceeded}, true, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			b := NewBreaker(store, 3, time.Minute)
+
+			for _, err := range tt.errs {
+				store.err = err
+				b.Get(context.Background(), "key", "field")
+			}
+
+			store.err = nil
+			err := b.Delete(context.Background(), "key")
+			if got := errors.Is(err, ErrBreakerOpen); got != tt.wantOpen {
+				t.Errorf("open = %v, want %v", got, tt.wantOpen)
+			}
+			wantCalls := tt.wantCalls
+			if !tt.wantOpen {
+				wantCalls++
+			}
+			if store.calls != wantCalls {
+				t.Errorf("store calls = %d, want %d", store.calls, wantCalls)
+			}
+		})
+	}
+}
+
+func TestBreakerRecoversAfterCooldown(t *testing.T) {
+	store := &fakeStore{err: errDown}
+	b := NewBreaker(store, 1, 20*time.Millisecond)
+
+	b.Set(context.Background(), "key", "field", nil, time.Minute)
+	if _, err := b.Get(context.Background(), "key", "field"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Get() error = %v, want ErrBreakerOpen", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	// The first call after the cooldown reaches the store; failing again
+	// reopens the breaker.
+	if _, err := b.Get(context.Background(), "key", "field"); !errors.Is(err, errDown) {
+		t.Fatalf("Get() after cooldown error = %v, want the store's error", err)
+	}
+	if _, err := b.Get(context.Background(), "key", "field"); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Get() after another failure error = %v, want ErrBreakerOpen", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	store.err = nil
+	if _, err := b.Get(context.Background(), "key", "field"); err != nil {
+		t.Fatalf("Get() once healthy error = %v", err)
+	}
+	if err := b.Delete(context.Background(), "key"); err != nil {
+		t.Fatalf("Delete() once healthy error = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrMiss = errors.New("cache miss")
+
+// Store holds cached values as fields of a hash so related entries, such as
+// one user's chat lists under different filters, expire and are invalidated
+// together.
+type Store interface {
+	Get(ctx context.Context, key, field string) ([]byte, error)
+	Set(ctx context.Context, key, field string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+func (r *Redis) Get(ctx context.Context, key, field string) ([]byte, error) {
+	value, err := r.client.HGet(ctx, r.prefix+key, field).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+// Set stores the field and resets the TTL of the whole hash, so an entry can
+// outlive its TTL by as long as its siblings keep being written.
+func (r *Redis) Set(ctx context.Context, key, field string, value []byte, ttl time.Duration) error {
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, r.prefix+key, field, value)
+	pipe.Expire(ctx, r.prefix+key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.prefix + key
+	}
+	return r.client.Del(ctx, prefixed...).Err()
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// MessageCreated is emitted once a sent message has been committed.
+// Recipients who have muted the chat are listed in MutedRecipientIDs instead
+// of RecipientIDs, so they get no push. Announcements may be configured to
+// reach muted recipients too, in which case everyone is in RecipientIDs.
+type MessageCreated struct {
+	MessageID         string    `json:"message_id"`
+	ChatID            string    `json:"chat_id"`
+	SenderID          string    `json:"sender_id"`
+	RecipientIDs      []string  `json:"recipient_ids"`
+	MutedRecipientIDs []string  `json:"muted_recipient_ids,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	Announcement      bool      `json:"announcement,omitempty"`
+}
+
+// Publisher delivers chat events to other services.
+type Publisher interface {
+	PublishMessageCreated(ctx context.Context, event *MessageCreated) error
+	Close() error
+}
+
+type noopPublisher struct{}
+
+// NewNoopPublisher returns a Publisher that drops every event, used when no
+// broker is configured.
+func NewNoopPublisher() Publisher {
+	return noopPublisher{}
+}
+
+func (noopPublisher) PublishMessageCreated(context.Context, *MessageCreated) error { return nil }
+
+func (noopPublisher) Close() error { return nil }
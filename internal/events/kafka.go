@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher writes events to topic, keyed by chat ID so a chat's
+// events stay ordered within a partition. Writes are asynchronous; delivery
+// failures are reported to the logger rather than to the caller.
+func NewKafkaPublisher(brokers []string, topic string, logger *logrus.Logger) Publisher {
+	return &kafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireOne,
+			BatchTimeout: 50 * time.Millisecond,
+			Async:        true,
+			Completion: func(messages []kafka.Message, err error) {
+				if err != nil {
+					logger.WithError(err).WithField("count", len(messages)).Error("Failed to publish chat events to Kafka")
+				}
+			},
+		},
+	}
+}
+
+func (p *kafkaPublisher) PublishMessageCreated(ctx context.Context, event *MessageCreated) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(event.ChatID),
+		Value: payload,
+		Headers: []kafka.Header{
+			{Key: "event_type", Value: []byte("MessageCreated")},
+		},
+	})
+}
+
+func (p *kafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,128 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"metachat/chat-service/internal/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// AuditServiceServer serves the audit log. metachat-proto v0.2.2 has no audit
+// RPC, so the service is described by hand and speaks well-known Struct
+// messages: the request may set actor_id, chat_id, from and to (RFC 3339)
+// and limit, and the response holds an entries list, newest first.
+type AuditServiceServer interface {
+	GetAuditLog(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+// AuditServiceDesc registers an AuditServiceServer, e.g. the ChatServer, with
+// grpc.Server.RegisterService.
+var AuditServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.ChatAuditService",
+	HandlerType: (*AuditServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAuditLog",
+			Handler:    getAuditLogHandler,
+		},
+	},
+	Metadata: "internal/grpc/audit.go",
+}
+
+func getAuditLogHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(structpb.Struct)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuditServiceServer).GetAuditLog(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.ChatAuditService/GetAuditLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuditServiceServer).GetAuditLog(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func (s *ChatServer) GetAuditLog(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	logger := s.loggerFor(ctx)
+
+	filter, err := auditFilterFromProto(req)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.WithField("filter", req.AsMap()).Info("Getting audit log via gRPC")
+
+	entries, err := s.service.GetAuditLog(ctx, filter)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get audit log")
+		return nil, s.toStatusError(err, "get audit log")
+	}
+
+	list := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, map[string]interface{}{
+			"id":         entry.ID,
+			"actor_id":   entry.ActorID,
+			"action":     string(entry.Action),
+			"chat_id":    entry.ChatID,
+			"target_id":  entry.TargetID,
+			"created_at": entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+
+	return structpb.NewStruct(map[string]interface{}{"entries": list})
+}
+
+// auditFilterFromProto reads a GetAuditLog request, rejecting unknown fields
+// and values of the wrong type with InvalidArgument.
+func auditFilterFromProto(req *structpb.Struct) (models.AuditFilter, error) {
+	var filter models.AuditFilter
+	for name, value := range req.GetFields() {
+		switch name {
+		case "actor_id", "chat_id", "from", "to", "limit":
+		default:
+			return filter, status.Errorf(codes.InvalidArgument, "unknown filter field %q", name)
+		}
+
+		if name == "limit" {
+			n, ok := value.GetKind().(*structpb.Value_NumberValue)
+			if !ok || n.NumberValue != float64(int(n.NumberValue)) {
+				return filter, status.Error(codes.InvalidArgument, "limit must be an integer")
+			}
+			filter.Limit = int(n.NumberValue)
+			continue
+		}
+
+		s, ok := value.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return filter, status.Errorf(codes.InvalidArgument, "%s must be a string", name)
+		}
+		switch name {
+		case "actor_id":
+			filter.ActorID = s.StringValue
+		case "chat_id":
+			filter.ChatID = s.StringValue
+		case "from", "to":
+			t, err := time.Parse(time.RFC3339Nano, s.StringValue)
+			if err != nil {
+				return filter, status.Errorf(codes.InvalidArgument, "%s must be an RFC 3339 time", name)
+			}
+			if name == "from" {
+				filter.From = &t
+			} else {
+				filter.To = &t
+			}
+		}
+	}
+	return filter, nil
+}
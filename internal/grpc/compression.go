@@ -0,0 +1,46 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+const (
+	CompressionAllow    = "allow"
+	CompressionForce    = "force"
+	CompressionDisallow = "disallow"
+)
+
+// UnaryCompressionInterceptor applies the configured response compression
+// policy. With "allow" the server mirrors the compression of the request,
+// "force" gzips responses whenever the client advertises gzip support and
+// "disallow" always sends uncompressed responses.
+func UnaryCompressionInterceptor(mode string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		switch mode {
+		case CompressionForce:
+			if clientSupportsCompressor(ctx, gzip.Name) {
+				_ = grpc.SetSendCompressor(ctx, gzip.Name)
+			}
+		case CompressionDisallow:
+			_ = grpc.SetSendCompressor(ctx, encoding.Identity)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func clientSupportsCompressor(ctx context.Context, name string) bool {
+	compressors, err := grpc.ClientSupportedCompressors(ctx)
+	if err != nil {
+		return false
+	}
+	for _, c := range compressors {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/service"
+
+	pb "github.com/kegazani/metachat-proto/chat"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/protobuf/proto"
+)
+
+// historyService serves a fixed page of messages.
+type historyService struct {
+	service.ChatService
+	page *models.MessagePage
+}
+
+func (s *historyService) GetChatMessages(context.Context, string, string, int, string, bool) (*models.MessagePage, error) {
+	return s.page, nil
+}
+
+// payloadStats records the sizes of the payloads the server sends.
+type payloadStats struct {
+	mu  sync.Mutex
+	out []*stats.OutPayload
+}
+
+func (h *payloadStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context   { return ctx }
+func (h *payloadStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context { return ctx }
+func (h *payloadStats) HandleConn(context.Context, stats.ConnStats)                       {}
+
+func (h *payloadStats) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if out, ok := s.(*stats.OutPayload); ok {
+		h.mu.Lock()
+		h.out = append(h.out, out)
+		h.mu.Unlock()
+	}
+}
+
+func (h *payloadStats) last() *stats.OutPayload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.out[len(h.out)-1]
+}
+
+func TestCompressionKeepsResponsesIdentical(t *testing.T) {
+	page := &models.MessagePage{}
+	for i := 0; i < 200; i++ {
+		page.Messages = append(page.Messages, &models.Message{
+			ID:        fmt.Sprintf("m%d", i),
+			ChatID:    testChatID,
+			SenderID:  testUserID,
+			Content:   strings.Repeat("history ", 20),
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC),
+		})
+	}
+	req := &pb.GetChatMessagesRequest{ChatId: testChatID, Limit: 200}
+
+	tests := []struct {
+		mode           string
+		clientGzip     bool
+		wantCompressed bool
+	}{
+		{CompressionAllow, false, false},
+		{CompressionAllow, true, true},
+		// Clients advertise every compressor they have registered.
+		{CompressionForce, false, true},
+		{CompressionDisallow, true, false},
+	}
+
+	var want *pb.GetChatMessagesResponse
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/gzip=%v", tt.mode, tt.clientGzip), func(t *testing.T) {
+			sizes := new(payloadStats)
+			conn := newTestClient(t, newTestServer(&historyService{page: page}),
+				grpc.UnaryInterceptor(UnaryCompressionInterceptor(tt.mode)),
+				grpc.StatsHandler(sizes),
+			)
+
+			var opts []grpc.CallOption
+			if tt.clientGzip {
+				opts = append(opts, grpc.UseCompressor(gzip.Name))
+			}
+			resp, err := pb.NewChatServiceClient(conn).GetChatMessages(context.Background(), req, opts...)
+			if err != nil {
+				t.Fatalf("GetChatMessages() error = %v", err)
+			}
+			if len(resp.Messages) != len(page.Messages) {
+				t.Fatalf("got %d messages, want %d", len(resp.Messages), len(page.Messages))
+			}
+			if want == nil {
+				want = resp
+			} else if !proto.Equal(resp, want) {
+				t.Errorf("response differs from the uncompressed one")
+			}
+
+			out := sizes.last()
+			if compressed := out.CompressedLength < out.Length; compressed != tt.wantCompressed {
+				t.Errorf("response sent in %d of %d bytes, want compressed = %v", out.CompressedLength, out.Length, tt.wantCompressed)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"metachat/chat-service/internal/repository"
+	"metachat/chat-service/internal/service"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *ChatServer) toStatusError(err error, action string) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidID),
+		errors.Is(err, service.ErrEmptyMessage),
+		errors.Is(err, service.ErrMessageTooLong),
+		errors.Is(err, service.ErrInvalidCursor),
+		errors.Is(err, service.ErrInvalidBucket),
+		errors.Is(err, service.ErrInvalidTimeRange),
+		errors.Is(err, service.ErrInvalidReaction),
+		errors.Is(err, service.ErrInvalidDeleteMode),
+		errors.Is(err, service.ErrTooFewParticipants),
+		errors.Is(err, service.ErrInvalidSearchQuery),
+		errors.Is(err, service.ErrInvalidAttachment),
+		errors.Is(err, service.ErrInvalidReply),
+		errors.Is(err, service.ErrInvalidAnnouncement),
+		errors.Is(err, service.ErrBatchTooLarge),
+		errors.Is(err, service.ErrInvalidArchiveFilter):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, repository.ErrChatNotFound):
+		return status.Errorf(codes.NotFound, "chat not found")
+	case errors.Is(err, repository.ErrMessageNotFound):
+		return status.Errorf(codes.NotFound, "message not found")
+	case errors.Is(err, service.ErrNotParticipant):
+		return status.Errorf(codes.PermissionDenied, "user is not a participant in this chat")
+	case errors.Is(err, service.ErrBlocked):
+		return status.Errorf(codes.PermissionDenied, "user cannot message this recipient")
+	case errors.Is(err, service.ErrNotMessageSender):
+		return status.Errorf(codes.PermissionDenied, "user is not the sender of this message")
+	case errors.Is(err, service.ErrServiceOnly):
+		return status.Errorf(codes.PermissionDenied, "only a trusted service may do this")
+	case errors.Is(err, service.ErrNotChatCreator):
+		return status.Errorf(codes.PermissionDenied, "user is not the creator of this chat")
+	case errors.Is(err, service.ErrLanguageNotAllowed):
+		return status.Errorf(codes.InvalidArgument, "message language is not allowed")
+	case errors.Is(err, service.ErrUnknownChatSource):
+		return status.Errorf(codes.InvalidArgument, "unknown chat source")
+	case errors.Is(err, service.ErrInvalidChatType):
+		return status.Errorf(codes.InvalidArgument, "invalid chat type for participants")
+	case errors.Is(err, service.ErrReplyTooDeep):
+		return status.Errorf(codes.FailedPrecondition, "reply chain is too deep")
+	case errors.Is(err, service.ErrChatQuotaExceeded):
+		return status.Errorf(codes.ResourceExhausted, "chat message quota exceeded")
+	case errors.Is(err, repository.ErrPinLimitReached):
+		return status.Errorf(codes.ResourceExhausted, "chat pin limit reached")
+	case errors.Is(err, service.ErrRateLimited):
+		return status.Errorf(codes.ResourceExhausted, "message rate limit exceeded")
+	case errors.Is(err, repository.ErrDuplicateMessageID):
+		return status.Errorf(codes.AlreadyExists, "message id already exists")
+	case errors.Is(err, context.DeadlineExceeded), repository.IsQueryCanceled(err):
+		return status.Errorf(codes.DeadlineExceeded, "failed to %s: timed out", action)
+	case errors.Is(err, context.Canceled):
+		return status.Errorf(codes.Canceled, "failed to %s: canceled", action)
+	case s.options.ExposeInternalErrors:
+		return status.Errorf(codes.Internal, "failed to %s: %v", action, err)
+	default:
+		return status.Errorf(codes.Internal, "failed to %s", action)
+	}
+}
@@ -0,0 +1,83 @@
+package grpc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/repository"
+	"metachat/chat-service/internal/service"
+
+	pb "github.com/kegazani/metachat-proto/chat"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatusErrorSeparatesMissingChatsFromFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"missing chat", repository.ErrChatNotFound, codes.NotFound},
+		{"wrapped missing chat", fmt.Errorf("get chat: %w", repository.ErrChatNotFound), codes.NotFound},
+		{"missing message", repository.ErrMessageNotFound, codes.NotFound},
+		{"lost connection", driver.ErrBadConn, codes.Internal},
+		{"closed pool", fmt.Errorf("get chat: %w", sql.ErrConnDone), codes.Internal},
+		{"driver error", errors.New("pq: too many connections"), codes.Internal},
+	}
+
+	srv := newTestServer(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(srv.toStatusError(tt.err, "send message")); got != tt.want {
+				t.Errorf("toStatusError(%v) code = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// failingService fails every GetChat call with err.
+type failingService struct {
+	service.ChatService
+	err error
+}
+
+func (s *failingService) GetChat(context.Context, string) (*models.Chat, error) {
+	return nil, s.err
+}
+
+func TestInternalErrorDetailIsLoggedNotReturned(t *testing.T) {
+	dbErr := errors.New(`pq: relation "chats" does not exist`)
+	tests := []struct {
+		expose  bool
+		wantMsg string
+	}{
+		{false, "failed to get chat"},
+		{true, "failed to get chat: " + dbErr.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("expose=%v", tt.expose), func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			srv := NewChatServer(&failingService{err: dbErr}, Options{ExposeInternalErrors: tt.expose}, logger)
+
+			_, err := srv.GetChat(context.Background(), &pb.GetChatRequest{ChatId: testChatID})
+			st, _ := status.FromError(err)
+			if st.Code() != codes.Internal || st.Message() != tt.wantMsg {
+				t.Errorf("GetChat() error = %v, want Internal %q", err, tt.wantMsg)
+			}
+
+			entry := hook.LastEntry()
+			if entry == nil || entry.Level != logrus.ErrorLevel || entry.Data[logrus.ErrorKey] != dbErr {
+				t.Errorf("last log = %v, want the database error logged", entry)
+			}
+		})
+	}
+}
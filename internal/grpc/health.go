@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	pb "github.com/kegazani/metachat-proto/chat"
+)
+
+const healthServicePrefix = "/grpc.health.v1.Health/"
+
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// DatabaseHealthCheck marks the server and the chat service SERVING and
+// returns a job that pings the database and flips both to NOT_SERVING while
+// pings fail, and back once the database is reachable again. After a failed
+// ping it retries with exponential backoff, starting at 250ms and stopping
+// once the next delay would exceed maxBackoff, so a recovery shows up well
+// before the next scheduled run.
+func DatabaseHealthCheck(db pinger, healthSrv *health.Server, timeout, maxBackoff time.Duration, logger *logrus.Logger) func(ctx context.Context) error {
+	serving := true
+	setDatabaseHealth(healthSrv, serving)
+
+	ping := func(ctx context.Context) error {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return db.PingContext(pingCtx)
+	}
+
+	report := func(healthy bool) {
+		if healthy == serving {
+			return
+		}
+		serving = healthy
+		setDatabaseHealth(healthSrv, serving)
+		if serving {
+			logger.Info("Database reachable again, reporting SERVING")
+		} else {
+			logger.Warn("Database unreachable, reporting NOT_SERVING")
+		}
+	}
+
+	return func(ctx context.Context) error {
+		err := ping(ctx)
+		for delay := 250 * time.Millisecond; err != nil && delay <= maxBackoff; delay *= 2 {
+			if ctx.Err() != nil {
+				return nil
+			}
+			report(false)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+
+			err = ping(ctx)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		report(err == nil)
+		return err
+	}
+}
+
+func setDatabaseHealth(healthSrv *health.Server, serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+
+	healthSrv.SetServingStatus("", status)
+	healthSrv.SetServingStatus(pb.ChatService_ServiceDesc.ServiceName, status)
+}
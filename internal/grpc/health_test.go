@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// fakePinger fails the first failures pings and succeeds after that.
+type fakePinger struct {
+	mu       sync.Mutex
+	failures int
+	pings    int
+}
+
+func (p *fakePinger) PingContext(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pings++
+	if p.pings <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func servingStatus(t *testing.T, healthSrv *health.Server) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+
+	resp, err := healthSrv.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	return resp.Status
+}
+
+func TestDatabaseHealthCheckReportsOutages(t *testing.T) {
+	db := &fakePinger{failures: 1}
+	healthSrv := health.NewServer()
+	logger, _ := test.NewNullLogger()
+
+	// Without room for a backoff retry, each run pings once.
+	check := DatabaseHealthCheck(db, healthSrv, time.Second, 0, logger)
+	if got := servingStatus(t, healthSrv); got != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("status before the first run = %v, want SERVING", got)
+	}
+
+	if err := check(context.Background()); err == nil {
+		t.Fatal("check with the database down returned no error")
+	}
+	if got := servingStatus(t, healthSrv); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("status with the database down = %v, want NOT_SERVING", got)
+	}
+
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("check with the database back error = %v", err)
+	}
+	if got := servingStatus(t, healthSrv); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status with the database back = %v, want SERVING", got)
+	}
+}
+
+func TestDatabaseHealthCheckRetriesWithinARun(t *testing.T) {
+	db := &fakePinger{failures: 1}
+	healthSrv := health.NewServer()
+	logger, _ := test.NewNullLogger()
+
+	// The first retry comes after 250ms, within the backoff cap.
+	check := DatabaseHealthCheck(db, healthSrv, time.Second, 250*time.Millisecond, logger)
+	if err := check(context.Background()); err != nil {
+		t.Fatalf("check error = %v, want the retry to succeed", err)
+	}
+	if db.pings != 2 {
+		t.Errorf("pinged %d times, want 2", db.pings)
+	}
+	if got := servingStatus(t, healthSrv); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("status after recovering = %v, want SERVING", got)
+	}
+}
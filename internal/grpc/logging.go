@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+type loggerContextKey struct{}
+
+// UnaryLoggingInterceptor attaches a logger to the request context. Methods
+// listed in methodLevels write their info logs at the listed level instead,
+// so a chatty RPC set to debug stays quiet under the global info level while
+// one set to warn still shows under a global warn level. Unlisted methods log
+// through logger unchanged.
+func UnaryLoggingInterceptor(logger *logrus.Logger, methodLevels map[string]logrus.Level) grpc.UnaryServerInterceptor {
+	methodLoggers := make(map[string]*logrus.Logger, len(methodLevels))
+	for method, level := range methodLevels {
+		// Entries pass through the method logger only to be relevelled and
+		// written by logger, which does the filtering, hooks and output.
+		threshold := logger.GetLevel()
+		if threshold < logrus.InfoLevel {
+			threshold = logrus.InfoLevel
+		}
+		hooks := make(logrus.LevelHooks)
+		hooks.Add(&relevelHook{logger: logger, info: level})
+		methodLoggers[strings.ToLower(method)] = &logrus.Logger{
+			Out:       io.Discard,
+			Hooks:     hooks,
+			Formatter: logger.Formatter,
+			Level:     threshold,
+			ExitFunc:  logger.ExitFunc,
+		}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := path.Base(info.FullMethod)
+
+		methodLogger, ok := methodLoggers[strings.ToLower(method)]
+		if !ok {
+			methodLogger = logger
+		}
+
+		start := time.Now()
+		resp, err := handler(context.WithValue(ctx, loggerContextKey{}, methodLogger), req)
+
+		methodLogger.WithFields(logrus.Fields{
+			"method":   method,
+			"code":     status.Code(err).String(),
+			"duration": time.Since(start),
+		}).Debug("gRPC request completed")
+
+		return resp, err
+	}
+}
+
+// relevelHook writes entries to logger, with info entries moved to the
+// method's configured level.
+type relevelHook struct {
+	logger *logrus.Logger
+	info   logrus.Level
+}
+
+func (h *relevelHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *relevelHook) Fire(entry *logrus.Entry) error {
+	level := entry.Level
+	if level == logrus.InfoLevel {
+		level = h.info
+	}
+	h.logger.WithFields(entry.Data).WithTime(entry.Time).WithContext(entry.Context).Log(level, entry.Message)
+	return nil
+}
+
+// ParseMethodLevels converts a method name to level name mapping from config
+// into logrus levels, skipping entries with an unknown level. Method names are
+// matched case-insensitively since viper lowercases map keys.
+func ParseMethodLevels(levels map[string]string, logger *logrus.Logger) map[string]logrus.Level {
+	methodLevels := make(map[string]logrus.Level, len(levels))
+	for method, name := range levels {
+		level, err := logrus.ParseLevel(name)
+		if err != nil {
+			logger.WithError(err).WithField("method", method).Warn("Ignoring invalid method log level")
+			continue
+		}
+		methodLevels[method] = level
+	}
+	return methodLevels
+}
+
+func (s *ChatServer) loggerFor(ctx context.Context) *logrus.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*logrus.Logger); ok {
+		return logger
+	}
+	return s.logger
+}
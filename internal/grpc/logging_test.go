@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc"
+)
+
+// logRequest runs a handler that logs once at info and once at warn through
+// the interceptor, as fullMethod.
+func logRequest(t *testing.T, interceptor grpc.UnaryServerInterceptor, srv *ChatServer, fullMethod string) {
+	t.Helper()
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		logger := srv.loggerFor(ctx)
+		logger.WithField("method", fullMethod).Info("Handling request")
+		logger.Warn("Request looks odd")
+		return nil, nil
+	}
+	if _, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: fullMethod}, handler); err != nil {
+		t.Fatalf("interceptor error = %v", err)
+	}
+}
+
+func TestUnaryLoggingInterceptorMethodLevels(t *testing.T) {
+	tests := []struct {
+		name       string
+		global     logrus.Level
+		method     string
+		wantLevels []logrus.Level
+	}{
+		{"debug method under info", logrus.InfoLevel, "GetChatMessages", []logrus.Level{logrus.WarnLevel}},
+		{"debug method under debug", logrus.DebugLevel, "GetChatMessages", []logrus.Level{logrus.DebugLevel, logrus.WarnLevel, logrus.DebugLevel}},
+		{"warn method under warn", logrus.WarnLevel, "CreateChat", []logrus.Level{logrus.WarnLevel, logrus.WarnLevel}},
+		{"unlisted method", logrus.InfoLevel, "GetChat", []logrus.Level{logrus.InfoLevel, logrus.WarnLevel}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, hook := test.NewNullLogger()
+			logger.SetLevel(tt.global)
+			// Viper hands over lowercased keys.
+			levels := ParseMethodLevels(map[string]string{"getchatmessages": "debug", "createchat": "warn"}, logger)
+			interceptor := UnaryLoggingInterceptor(logger, levels)
+
+			logRequest(t, interceptor, NewChatServer(nil, Options{}, logger), "/chat.ChatService/"+tt.method)
+
+			var got []logrus.Level
+			for _, entry := range hook.AllEntries() {
+				got = append(got, entry.Level)
+			}
+			if !slices.Equal(got, tt.wantLevels) {
+				t.Fatalf("logged at %v, want %v", got, tt.wantLevels)
+			}
+			for _, entry := range hook.AllEntries() {
+				if entry.Message == "Handling request" && entry.Data["method"] == nil {
+					t.Errorf("relevelled entry lost its fields: %v", entry.Data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package grpc
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"metachat/chat-service/internal/metrics"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func UnaryMetricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		method := path.Base(info.FullMethod)
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		metrics.GRPCRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+		metrics.GRPCRequestsTotal.WithLabelValues(method, code.String()).Inc()
+		if code != codes.OK {
+			metrics.GRPCRequestErrorsTotal.WithLabelValues(method, code.String()).Inc()
+		}
+
+		return resp, err
+	}
+}
@@ -2,41 +2,97 @@ package grpc
 
 import (
 	"context"
+	"strconv"
+	"time"
 
+	"metachat/chat-service/internal/auth"
 	"metachat/chat-service/internal/models"
 	"metachat/chat-service/internal/service"
 
 	"github.com/sirupsen/logrus"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	pb "github.com/kegazani/metachat-proto/chat"
 )
 
+// Response headers carrying values the v0.2.2 response messages have no fields
+// for: chatsTruncatedHeader marks a GetUserChats list cut at the hard cap.
+// nextCursorHeader and prevCursorHeader hold the GetChatMessages page tokens
+// for older and newer messages, which clients pass back in
+// before_message_id; each token knows which way it pages. The last-read
+// headers give GetChat callers their own read position, and
+// messageCountHeader the chat's undeleted message count; both only go to
+// participants. The rate-limit headers tell
+// SendMessage callers how many sends they have left, with a warning flag
+// once that runs low. GetChat also returns the chat's creation source in
+// chatSourceHeader.
+const (
+	chatsTruncatedHeader     = "x-chats-truncated"
+	nextCursorHeader         = "x-next-cursor"
+	prevCursorHeader         = "x-prev-cursor"
+	lastReadMessageHeader    = "x-last-read-message-id"
+	lastReadAtHeader         = "x-last-read-at"
+	messageCountHeader       = "x-message-count"
+	rateLimitRemainingHeader = "x-rate-limit-remaining"
+	rateLimitLimitHeader     = "x-rate-limit-limit"
+	rateLimitWarningHeader   = "x-rate-limit-warning"
+)
+
+// Request headers for options the v0.2.2 request messages have no fields for:
+// writeConcernHeader lets SendMessage callers request a write concern,
+// announcementHeader set to "true" posts the message as an announcement and
+// chatSourceHeader records where CreateChat was called from, checked against
+// chats.allowed_sources.
+//
+// archivedHeader selects whether GetUserChats excludes, includes or only
+// returns archived chats. Without it archived chats are excluded, which
+// changed the RPC's default for existing clients: those that want every chat,
+// as before archiving existed, must send "include".
+const (
+	writeConcernHeader = "x-write-concern"
+	announcementHeader = "x-announcement"
+	archivedHeader     = "x-archived"
+	chatSourceHeader   = "x-chat-source"
+)
+
+type Options struct {
+	// ExposeInternalErrors includes the underlying error text in Internal
+	// status messages. Meant for development; in production clients get a
+	// generic message and the detail stays in the server log.
+	ExposeInternalErrors bool
+}
+
 type ChatServer struct {
 	pb.UnimplementedChatServiceServer
 	service service.ChatService
+	options Options
 	logger  *logrus.Logger
 }
 
-func NewChatServer(svc service.ChatService, logger *logrus.Logger) *ChatServer {
+func NewChatServer(svc service.ChatService, opts Options, logger *logrus.Logger) *ChatServer {
 	return &ChatServer{
 		service: svc,
+		options: opts,
 		logger:  logger,
 	}
 }
 
 func (s *ChatServer) CreateChat(ctx context.Context, req *pb.CreateChatRequest) (*pb.CreateChatResponse, error) {
-	s.logger.WithFields(logrus.Fields{
+	logger := s.loggerFor(ctx)
+
+	logger.WithFields(logrus.Fields{
 		"user_id1": req.UserId1,
 		"user_id2": req.UserId2,
 	}).Info("Creating chat via gRPC")
 
-	chat, err := s.service.CreateChat(ctx, req.UserId1, req.UserId2)
+	chat, err := s.service.CreateChat(ctx, req.UserId1, req.UserId2, service.CreateChatOptions{
+		Source: incomingHeader(ctx, chatSourceHeader),
+	})
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to create chat")
-		return nil, status.Errorf(codes.Internal, "failed to create chat: %v", err)
+		logger.WithError(err).Error("Failed to create chat")
+		return nil, s.toStatusError(err, "create chat")
 	}
 
 	return &pb.CreateChatResponse{
@@ -45,15 +101,29 @@ func (s *ChatServer) CreateChat(ctx context.Context, req *pb.CreateChatRequest)
 }
 
 func (s *ChatServer) GetChat(ctx context.Context, req *pb.GetChatRequest) (*pb.GetChatResponse, error) {
-	s.logger.WithField("chat_id", req.ChatId).Info("Getting chat via gRPC")
+	logger := s.loggerFor(ctx)
+
+	logger.WithField("chat_id", req.ChatId).Info("Getting chat via gRPC")
 
 	chat, err := s.service.GetChat(ctx, req.ChatId)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get chat")
-		if err.Error() == "chat not found" {
-			return nil, status.Errorf(codes.NotFound, "chat not found")
-		}
-		return nil, status.Errorf(codes.Internal, "failed to get chat: %v", err)
+		logger.WithError(err).Error("Failed to get chat")
+		return nil, s.toStatusError(err, "get chat")
+	}
+
+	header := metadata.MD{}
+	if userID, ok := auth.UserIDFromContext(ctx); ok && chat.HasParticipant(userID) {
+		header.Set(messageCountHeader, strconv.FormatInt(chat.MessageCount, 10))
+	}
+	if chat.Source != "" {
+		header.Set(chatSourceHeader, chat.Source)
+	}
+	if chat.LastRead != nil {
+		header.Set(lastReadMessageHeader, chat.LastRead.MessageID)
+		header.Set(lastReadAtHeader, chat.LastRead.ReadAt.UTC().Format(time.RFC3339Nano))
+	}
+	if header.Len() > 0 {
+		grpc.SetHeader(ctx, header)
 	}
 
 	return &pb.GetChatResponse{
@@ -62,16 +132,24 @@ func (s *ChatServer) GetChat(ctx context.Context, req *pb.GetChatRequest) (*pb.G
 }
 
 func (s *ChatServer) GetUserChats(ctx context.Context, req *pb.GetUserChatsRequest) (*pb.GetUserChatsResponse, error) {
-	s.logger.WithField("user_id", req.UserId).Info("Getting user chats via gRPC")
+	logger := s.loggerFor(ctx)
+
+	logger.WithField("user_id", req.UserId).Info("Getting user chats via gRPC")
 
-	chats, err := s.service.GetUserChats(ctx, req.UserId)
+	list, err := s.service.GetUserChats(ctx, req.UserId, service.UserChatsOptions{
+		Archived: models.ArchiveFilter(incomingHeader(ctx, archivedHeader)),
+	})
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get user chats")
-		return nil, status.Errorf(codes.Internal, "failed to get user chats: %v", err)
+		logger.WithError(err).Error("Failed to get user chats")
+		return nil, s.toStatusError(err, "get user chats")
+	}
+
+	if list.Truncated {
+		grpc.SetHeader(ctx, metadata.Pairs(chatsTruncatedHeader, "true"))
 	}
 
-	protoChats := make([]*pb.Chat, len(chats))
-	for i, c := range chats {
+	protoChats := make([]*pb.Chat, len(list.Chats))
+	for i, c := range list.Chats {
 		protoChats[i] = s.chatToProto(c)
 	}
 
@@ -81,21 +159,31 @@ func (s *ChatServer) GetUserChats(ctx context.Context, req *pb.GetUserChatsReque
 }
 
 func (s *ChatServer) SendMessage(ctx context.Context, req *pb.SendMessageRequest) (*pb.SendMessageResponse, error) {
-	s.logger.WithFields(logrus.Fields{
+	logger := s.loggerFor(ctx)
+
+	logger.WithFields(logrus.Fields{
 		"chat_id":   req.ChatId,
 		"sender_id": req.SenderId,
 	}).Info("Sending message via gRPC")
 
-	msg, err := s.service.SendMessage(ctx, req.ChatId, req.SenderId, req.Content)
+	msg, err := s.service.SendMessage(ctx, req.ChatId, req.SenderId, req.Content, service.SendMessageOptions{
+		WriteConcern: service.WriteConcern(incomingHeader(ctx, writeConcernHeader)),
+		Announcement: incomingHeader(ctx, announcementHeader) == "true",
+	})
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to send message")
-		if err.Error() == "chat not found" {
-			return nil, status.Errorf(codes.NotFound, "chat not found")
-		}
-		if err.Error() == "user is not a participant in this chat" {
-			return nil, status.Errorf(codes.PermissionDenied, "user is not a participant in this chat")
+		logger.WithError(err).Error("Failed to send message")
+		return nil, s.toStatusError(err, "send message")
+	}
+
+	if rl := msg.RateLimit; rl != nil {
+		header := metadata.Pairs(
+			rateLimitRemainingHeader, strconv.Itoa(rl.Remaining),
+			rateLimitLimitHeader, strconv.Itoa(rl.Limit),
+		)
+		if rl.Warning {
+			header.Set(rateLimitWarningHeader, "true")
 		}
-		return nil, status.Errorf(codes.Internal, "failed to send message: %v", err)
+		grpc.SetHeader(ctx, header)
 	}
 
 	return &pb.SendMessageResponse{
@@ -104,21 +192,30 @@ func (s *ChatServer) SendMessage(ctx context.Context, req *pb.SendMessageRequest
 }
 
 func (s *ChatServer) GetChatMessages(ctx context.Context, req *pb.GetChatMessagesRequest) (*pb.GetChatMessagesResponse, error) {
-	s.logger.WithField("chat_id", req.ChatId).Info("Getting chat messages via gRPC")
+	logger := s.loggerFor(ctx)
+
+	logger.WithField("chat_id", req.ChatId).Info("Getting chat messages via gRPC")
 
 	limit := int(req.Limit)
 	if limit <= 0 {
 		limit = 50
 	}
 
-	messages, err := s.service.GetChatMessages(ctx, req.ChatId, limit, req.BeforeMessageId)
+	page, err := s.service.GetChatMessages(ctx, req.ChatId, "", limit, req.BeforeMessageId, false)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get chat messages")
-		return nil, status.Errorf(codes.Internal, "failed to get chat messages: %v", err)
+		logger.WithError(err).Error("Failed to get chat messages")
+		return nil, s.toStatusError(err, "get chat messages")
+	}
+
+	if page.NextCursor != "" {
+		grpc.SetHeader(ctx, metadata.Pairs(nextCursorHeader, page.NextCursor))
+	}
+	if page.PrevCursor != "" {
+		grpc.SetHeader(ctx, metadata.Pairs(prevCursorHeader, page.PrevCursor))
 	}
 
-	protoMessages := make([]*pb.Message, len(messages))
-	for i, m := range messages {
+	protoMessages := make([]*pb.Message, len(page.Messages))
+	for i, m := range page.Messages {
 		protoMessages[i] = s.messageToProto(m)
 	}
 
@@ -128,21 +225,17 @@ func (s *ChatServer) GetChatMessages(ctx context.Context, req *pb.GetChatMessage
 }
 
 func (s *ChatServer) MarkMessagesAsRead(ctx context.Context, req *pb.MarkMessagesAsReadRequest) (*pb.MarkMessagesAsReadResponse, error) {
-	s.logger.WithFields(logrus.Fields{
+	logger := s.loggerFor(ctx)
+
+	logger.WithFields(logrus.Fields{
 		"chat_id": req.ChatId,
 		"user_id": req.UserId,
 	}).Info("Marking messages as read via gRPC")
 
 	count, err := s.service.MarkMessagesAsRead(ctx, req.ChatId, req.UserId)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to mark messages as read")
-		if err.Error() == "chat not found" {
-			return nil, status.Errorf(codes.NotFound, "chat not found")
-		}
-		if err.Error() == "user is not a participant in this chat" {
-			return nil, status.Errorf(codes.PermissionDenied, "user is not a participant in this chat")
-		}
-		return nil, status.Errorf(codes.Internal, "failed to mark messages as read: %v", err)
+		logger.WithError(err).Error("Failed to mark messages as read")
+		return nil, s.toStatusError(err, "mark messages as read")
 	}
 
 	return &pb.MarkMessagesAsReadResponse{
@@ -175,3 +268,16 @@ func (s *ChatServer) messageToProto(msg *models.Message) *pb.Message {
 
 	return protoMsg
 }
+
+func incomingHeader(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
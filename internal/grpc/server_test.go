@@ -0,0 +1,117 @@
+package grpc
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/service"
+
+	pb "github.com/kegazani/metachat-proto/chat"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// chatStore keeps the chats created through it.
+type chatStore struct {
+	service.ChatService
+	chats map[string]*models.Chat
+}
+
+func (s *chatStore) CreateChat(_ context.Context, userID1, userID2 string, opts service.CreateChatOptions) (*models.Chat, error) {
+	chat := &models.Chat{ID: testChatID, UserID1: userID1, UserID2: userID2, Type: models.ChatTypeDirect, Source: opts.Source}
+	s.chats[chat.ID] = chat
+	return chat, nil
+}
+
+func (s *chatStore) GetChat(_ context.Context, chatID string) (*models.Chat, error) {
+	return s.chats[chatID], nil
+}
+
+func TestChatSourceRoundTrip(t *testing.T) {
+	store := &chatStore{chats: make(map[string]*models.Chat)}
+	client := pb.NewChatServiceClient(newTestClient(t, newTestServer(store)))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), chatSourceHeader, "support")
+	resp, err := client.CreateChat(ctx, &pb.CreateChatRequest{UserId1: testUserID, UserId2: testOtherID})
+	if err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+
+	var header metadata.MD
+	if _, err := client.GetChat(context.Background(), &pb.GetChatRequest{ChatId: resp.Chat.Id}, grpc.Header(&header)); err != nil {
+		t.Fatalf("GetChat() error = %v", err)
+	}
+	if got := header.Get(chatSourceHeader); len(got) != 1 || got[0] != "support" {
+		t.Errorf("%s = %v, want [support]", chatSourceHeader, got)
+	}
+}
+
+// chatList answers GetUserChats with a fixed list.
+type chatList struct {
+	service.ChatService
+	list *models.ChatList
+}
+
+func (s *chatList) GetUserChats(context.Context, string, service.UserChatsOptions) (*models.ChatList, error) {
+	return s.list, nil
+}
+
+func TestGetUserChatsFlagsTruncation(t *testing.T) {
+	for _, truncated := range []bool{true, false} {
+		svc := &chatList{list: &models.ChatList{
+			Chats:     []*models.Chat{{ID: testChatID, UserID1: testUserID, UserID2: testOtherID}},
+			Truncated: truncated,
+		}}
+		client := pb.NewChatServiceClient(newTestClient(t, newTestServer(svc)))
+
+		var header metadata.MD
+		resp, err := client.GetUserChats(context.Background(), &pb.GetUserChatsRequest{UserId: testUserID}, grpc.Header(&header))
+		if err != nil {
+			t.Fatalf("GetUserChats() error = %v", err)
+		}
+		if len(resp.Chats) != 1 {
+			t.Errorf("got %d chats, want 1", len(resp.Chats))
+		}
+		want := []string(nil)
+		if truncated {
+			want = []string{"true"}
+		}
+		if got := header.Get(chatsTruncatedHeader); !slices.Equal(got, want) {
+			t.Errorf("truncated %v: %s = %v, want %v", truncated, chatsTruncatedHeader, got, want)
+		}
+	}
+}
+
+func TestGetChatLastReadHeaders(t *testing.T) {
+	readAt := time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC)
+	store := &chatStore{chats: map[string]*models.Chat{
+		testChatID: {
+			ID: testChatID, UserID1: testUserID, UserID2: testOtherID,
+			LastRead: &models.ReadPointer{MessageID: "m2", MessageCreatedAt: readAt.Add(-time.Minute), ReadAt: readAt},
+		},
+	}}
+	client := pb.NewChatServiceClient(newTestClient(t, newTestServer(store)))
+
+	var header metadata.MD
+	if _, err := client.GetChat(context.Background(), &pb.GetChatRequest{ChatId: testChatID}, grpc.Header(&header)); err != nil {
+		t.Fatalf("GetChat() error = %v", err)
+	}
+	if got := header.Get(lastReadMessageHeader); !slices.Equal(got, []string{"m2"}) {
+		t.Errorf("%s = %v, want [m2]", lastReadMessageHeader, got)
+	}
+	if got := header.Get(lastReadAtHeader); !slices.Equal(got, []string{readAt.Format(time.RFC3339Nano)}) {
+		t.Errorf("%s = %v, want [%s]", lastReadAtHeader, got, readAt.Format(time.RFC3339Nano))
+	}
+
+	store.chats[testChatID].LastRead = nil
+	header = nil
+	if _, err := client.GetChat(context.Background(), &pb.GetChatRequest{ChatId: testChatID}, grpc.Header(&header)); err != nil {
+		t.Fatalf("GetChat() error = %v", err)
+	}
+	if got := header.Get(lastReadMessageHeader); got != nil {
+		t.Errorf("%s for an unread chat = %v, want none", lastReadMessageHeader, got)
+	}
+}
@@ -0,0 +1,217 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// StreamServiceServer streams live chat events. Like the audit service it is
+// described by hand because metachat-proto v0.2.2 has no streaming RPCs.
+// Requests are Structs with a chat_id and, for service callers acting for a
+// user, a user_id. Each streamed event is a Struct with its type and chat_id
+// plus whichever of user_id, emoji, expires_at and message the event carries.
+// A message includes announcement: true if it is one.
+type StreamServiceServer interface {
+	StreamChatEvents(req *structpb.Struct, stream grpc.ServerStream) error
+	StreamTypingEvents(req *structpb.Struct, stream grpc.ServerStream) error
+	SendTypingEvent(ctx context.Context, req *structpb.Struct) (*emptypb.Empty, error)
+}
+
+// StreamServiceDesc registers a StreamServiceServer, e.g. the ChatServer,
+// with grpc.Server.RegisterService.
+var StreamServiceDesc = grpc.ServiceDesc{
+	ServiceName: "chat.ChatStreamService",
+	HandlerType: (*StreamServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SendTypingEvent",
+			Handler:    sendTypingEventHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamChatEvents",
+			Handler:       streamChatEventsHandler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamTypingEvents",
+			Handler:       streamTypingEventsHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "internal/grpc/stream.go",
+}
+
+func streamChatEventsHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(StreamServiceServer).StreamChatEvents(req, stream)
+}
+
+func streamTypingEventsHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(StreamServiceServer).StreamTypingEvents(req, stream)
+}
+
+func sendTypingEventHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(structpb.Struct)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StreamServiceServer).SendTypingEvent(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/chat.ChatStreamService/SendTypingEvent",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StreamServiceServer).SendTypingEvent(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func (s *ChatServer) StreamChatEvents(req *structpb.Struct, stream grpc.ServerStream) error {
+	return s.streamEvents(req, stream, "stream chat events", s.service.SubscribeChatEvents)
+}
+
+func (s *ChatServer) StreamTypingEvents(req *structpb.Struct, stream grpc.ServerStream) error {
+	return s.streamEvents(req, stream, "stream typing events", s.service.StreamTypingEvents)
+}
+
+func (s *ChatServer) SendTypingEvent(ctx context.Context, req *structpb.Struct) (*emptypb.Empty, error) {
+	logger := s.loggerFor(ctx)
+
+	chatID, userID, err := streamTarget(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.service.SendTypingEvent(ctx, chatID, userID); err != nil {
+		logger.WithError(err).Error("Failed to send typing event")
+		return nil, s.toStatusError(err, "send typing event")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// streamEvents subscribes through subscribe and forwards events until the
+// client goes away. If the hub drops the subscriber for falling behind, the
+// stream ends with ResourceExhausted so the client knows to resubscribe.
+func (s *ChatServer) streamEvents(req *structpb.Struct, stream grpc.ServerStream, action string, subscribe func(ctx context.Context, chatID, userID string) (<-chan *models.ChatEvent, error)) error {
+	ctx := stream.Context()
+	logger := s.loggerFor(ctx)
+
+	chatID, userID, err := streamTarget(req)
+	if err != nil {
+		return err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"chat_id": chatID,
+		"user_id": userID,
+	}).Info("Opening event stream via gRPC")
+
+	events, err := subscribe(ctx, chatID, userID)
+	if err != nil {
+		logger.WithError(err).Error("Failed to subscribe to chat events")
+		return s.toStatusError(err, action)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return status.Error(codes.ResourceExhausted, "stream fell behind; resubscribe")
+			}
+			msg, err := eventToProto(event)
+			if err != nil {
+				return s.toStatusError(err, action)
+			}
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// streamTarget reads the chat_id and optional user_id of a stream request.
+func streamTarget(req *structpb.Struct) (chatID, userID string, err error) {
+	for name, value := range req.GetFields() {
+		s, ok := value.GetKind().(*structpb.Value_StringValue)
+		switch {
+		case name != "chat_id" && name != "user_id":
+			return "", "", status.Errorf(codes.InvalidArgument, "unknown field %q", name)
+		case !ok:
+			return "", "", status.Errorf(codes.InvalidArgument, "%s must be a string", name)
+		case name == "chat_id":
+			chatID = s.StringValue
+		default:
+			userID = s.StringValue
+		}
+	}
+	return chatID, userID, nil
+}
+
+func eventToProto(event *models.ChatEvent) (*structpb.Struct, error) {
+	fields := map[string]interface{}{
+		"type":    string(event.Type),
+		"chat_id": event.ChatID,
+	}
+	if event.UserID != "" {
+		fields["user_id"] = event.UserID
+	}
+	if event.Emoji != "" {
+		fields["emoji"] = event.Emoji
+	}
+	if !event.ExpiresAt.IsZero() {
+		fields["expires_at"] = event.ExpiresAt.UTC().Format(time.RFC3339Nano)
+	}
+	if msg := event.Message; msg != nil {
+		message := map[string]interface{}{
+			"id":      msg.ID,
+			"chat_id": msg.ChatID,
+		}
+		if msg.SenderID != "" {
+			message["sender_id"] = msg.SenderID
+			message["content"] = msg.Content
+		}
+		if !msg.CreatedAt.IsZero() {
+			message["created_at"] = msg.CreatedAt.UTC().Format(time.RFC3339Nano)
+		}
+		if msg.EditedAt != nil {
+			message["edited_at"] = msg.EditedAt.UTC().Format(time.RFC3339Nano)
+		}
+		if msg.DeletedAt != nil {
+			message["deleted_at"] = msg.DeletedAt.UTC().Format(time.RFC3339Nano)
+		}
+		if msg.ReplyToID != "" {
+			message["reply_to_id"] = msg.ReplyToID
+		}
+		if msg.Announcement {
+			message["announcement"] = true
+		}
+		fields["message"] = message
+	}
+	return structpb.NewStruct(fields)
+}
@@ -0,0 +1,160 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/service"
+
+	pb "github.com/kegazani/metachat-proto/chat"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	testChatID  = "3d6f8a2e-1c4b-4e5f-9a7b-2c3d4e5f6a7b"
+	testUserID  = "6f1c2a9e-3b4d-4c5e-8f7a-1b2c3d4e5f60"
+	testOtherID = "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+)
+
+// stubService answers the streaming calls from a channel the test controls;
+// anything else panics through the nil embedded interface.
+type stubService struct {
+	service.ChatService
+	events chan *models.ChatEvent
+	err    error
+}
+
+func (s *stubService) SubscribeChatEvents(context.Context, string, string) (<-chan *models.ChatEvent, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.events, nil
+}
+
+// newTestClient serves srv over an in-memory listener and returns a client
+// connection to it, with the given server options.
+func newTestClient(t *testing.T, srv *ChatServer, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(opts...)
+	pb.RegisterChatServiceServer(s, srv)
+	s.RegisterService(&StreamServiceDesc, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newTestServer(svc service.ChatService) *ChatServer {
+	logger, _ := test.NewNullLogger()
+	return NewChatServer(svc, Options{}, logger)
+}
+
+func openChatEvents(t *testing.T, conn *grpc.ClientConn) grpc.ClientStream {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	stream, err := conn.NewStream(ctx, &StreamServiceDesc.Streams[0], "/chat.ChatStreamService/StreamChatEvents")
+	if err != nil {
+		t.Fatalf("NewStream() error = %v", err)
+	}
+	req, _ := structpb.NewStruct(map[string]interface{}{"chat_id": testChatID})
+	if err := stream.SendMsg(req); err != nil {
+		t.Fatalf("SendMsg() error = %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend() error = %v", err)
+	}
+	return stream
+}
+
+func TestStreamChatEventsForwardsEvents(t *testing.T) {
+	svc := &stubService{events: make(chan *models.ChatEvent, 2)}
+	stream := openChatEvents(t, newTestClient(t, newTestServer(svc)))
+
+	svc.events <- &models.ChatEvent{
+		Type:    models.ChatEventMessageCreated,
+		ChatID:  testChatID,
+		Message: &models.Message{ID: "m1", ChatID: testChatID, SenderID: testUserID, Content: "hi", CreatedAt: time.Now()},
+	}
+	svc.events <- &models.ChatEvent{
+		Type:    models.ChatEventReactionAdded,
+		ChatID:  testChatID,
+		Message: &models.Message{ID: "m1", ChatID: testChatID},
+		UserID:  testUserID,
+		Emoji:   "👍",
+	}
+
+	got := new(structpb.Struct)
+	if err := stream.RecvMsg(got); err != nil {
+		t.Fatalf("RecvMsg() error = %v", err)
+	}
+	fields := got.AsMap()
+	message, _ := fields["message"].(map[string]interface{})
+	if fields["type"] != "message_created" || message["content"] != "hi" {
+		t.Errorf("first event = %v, want the created message", fields)
+	}
+
+	if err := stream.RecvMsg(got); err != nil {
+		t.Fatalf("RecvMsg() error = %v", err)
+	}
+	fields = got.AsMap()
+	if fields["type"] != "reaction_added" || fields["emoji"] != "👍" || fields["user_id"] != testUserID {
+		t.Errorf("second event = %v, want the reaction", fields)
+	}
+}
+
+func TestStreamChatEventsEndsWhenSubscriberDropped(t *testing.T) {
+	svc := &stubService{events: make(chan *models.ChatEvent)}
+	stream := openChatEvents(t, newTestClient(t, newTestServer(svc)))
+
+	// The hub closes a slow subscriber's channel.
+	close(svc.events)
+
+	err := stream.RecvMsg(new(structpb.Struct))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("RecvMsg() error = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestStreamChatEventsMapsServiceErrors(t *testing.T) {
+	svc := &stubService{err: service.ErrNotParticipant}
+	stream := openChatEvents(t, newTestClient(t, newTestServer(svc)))
+
+	err := stream.RecvMsg(new(structpb.Struct))
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("RecvMsg() error = %v, want PermissionDenied", err)
+	}
+}
+
+func TestStreamAuthInterceptorRejectsMissingToken(t *testing.T) {
+	svc := &stubService{events: make(chan *models.ChatEvent)}
+	conn := newTestClient(t, newTestServer(svc), grpc.StreamInterceptor(StreamAuthInterceptor(nil, logrus.New())))
+	stream := openChatEvents(t, conn)
+
+	err := stream.RecvMsg(new(structpb.Struct))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("RecvMsg() error = %v, want Unauthenticated", err)
+	}
+}
@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryTracingInterceptor continues the caller's trace from the incoming
+// metadata and wraps each RPC in a server span tagged with the chat, sender
+// and user IDs found on the request.
+func UnaryTracingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+
+		ctx, span := tracing.Tracer().Start(ctx, info.FullMethod,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("rpc.system", "grpc"),
+				attribute.String("rpc.method", info.FullMethod),
+			),
+		)
+		defer span.End()
+
+		span.SetAttributes(requestAttributes(req)...)
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			st := status.Convert(err)
+			span.SetAttributes(attribute.String("rpc.grpc.status_code", st.Code().String()))
+			span.SetStatus(codes.Error, st.Message())
+		}
+		return resp, err
+	}
+}
+
+func requestAttributes(req interface{}) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if r, ok := req.(interface{ GetChatId() string }); ok && r.GetChatId() != "" {
+		attrs = append(attrs, tracing.ChatID(r.GetChatId()))
+	}
+	if r, ok := req.(interface{ GetSenderId() string }); ok && r.GetSenderId() != "" {
+		attrs = append(attrs, tracing.SenderID(r.GetSenderId()))
+	}
+	if r, ok := req.(interface{ GetUserId() string }); ok && r.GetUserId() != "" {
+		attrs = append(attrs, tracing.UserID(r.GetUserId()))
+	}
+	return attrs
+}
+
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"metachat/chat-service/internal/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Job is a unit of periodic background work.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Enabled  bool
+	Run      func(ctx context.Context) error
+}
+
+// Runner schedules jobs on their own intervals while capping how many run at
+// once. A job never overlaps with itself: a tick that arrives while the
+// previous run is still going is skipped.
+type Runner struct {
+	jobs   []Job
+	slots  chan struct{}
+	logger *logrus.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner(maxConcurrency int, logger *logrus.Logger) *Runner {
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+	return &Runner{
+		slots:  make(chan struct{}, maxConcurrency),
+		logger: logger,
+	}
+}
+
+// Register adds a job; disabled jobs and jobs without an interval are
+// skipped. Must be called before Start.
+func (r *Runner) Register(job Job) {
+	if !job.Enabled || job.Interval <= 0 {
+		r.logger.WithField("job", job.Name).Info("Background job disabled")
+		return
+	}
+	r.jobs = append(r.jobs, job)
+}
+
+func (r *Runner) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, job)
+		r.logger.WithFields(logrus.Fields{
+			"job":      job.Name,
+			"interval": job.Interval,
+		}).Info("Background job started")
+	}
+}
+
+// Stop cancels all jobs and waits for in-flight runs to return.
+func (r *Runner) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Runner) loop(ctx context.Context, job Job) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		select {
+		case r.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		r.run(ctx, job)
+		<-r.slots
+	}
+}
+
+func (r *Runner) run(ctx context.Context, job Job) {
+	start := time.Now()
+	err := job.Run(ctx)
+	duration := time.Since(start)
+
+	metrics.JobRunsTotal.WithLabelValues(job.Name).Inc()
+	metrics.JobDuration.WithLabelValues(job.Name).Observe(duration.Seconds())
+	metrics.JobLastRunTimestamp.WithLabelValues(job.Name).Set(float64(start.Unix()))
+
+	if err != nil && ctx.Err() == nil {
+		metrics.JobErrorsTotal.WithLabelValues(job.Name).Inc()
+		r.logger.WithError(err).WithField("job", job.Name).Error("Background job failed")
+	}
+}
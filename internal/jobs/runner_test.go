@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func newTestRunner(maxConcurrency int) *Runner {
+	logger, _ := test.NewNullLogger()
+	return NewRunner(maxConcurrency, logger)
+}
+
+// countingJob returns a job that counts its runs in runs.
+func countingJob(name string, interval time.Duration, enabled bool, runs *atomic.Int32) Job {
+	return Job{
+		Name:     name,
+		Interval: interval,
+		Enabled:  enabled,
+		Run: func(context.Context) error {
+			runs.Add(1)
+			return nil
+		},
+	}
+}
+
+func TestRunnerSkipsDisabledJobs(t *testing.T) {
+	var enabled, disabled, unscheduled atomic.Int32
+	runner := newTestRunner(2)
+	runner.Register(countingJob("enabled", 10*time.Millisecond, true, &enabled))
+	runner.Register(countingJob("disabled", 10*time.Millisecond, false, &disabled))
+	runner.Register(countingJob("unscheduled", 0, true, &unscheduled))
+
+	runner.Start(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	runner.Stop()
+
+	if enabled.Load() == 0 {
+		t.Error("enabled job never ran")
+	}
+	if n := disabled.Load(); n != 0 {
+		t.Errorf("disabled job ran %d times", n)
+	}
+	if n := unscheduled.Load(); n != 0 {
+		t.Errorf("job without an interval ran %d times", n)
+	}
+}
+
+func TestRunnerRespectsIntervals(t *testing.T) {
+	var fast, slow atomic.Int32
+	runner := newTestRunner(2)
+	runner.Register(countingJob("fast", 20*time.Millisecond, true, &fast))
+	runner.Register(countingJob("slow", time.Hour, true, &slow))
+
+	runner.Start(context.Background())
+	time.Sleep(210 * time.Millisecond)
+	runner.Stop()
+
+	// About ten ticks; allow for a loaded machine.
+	if n := fast.Load(); n < 3 || n > 11 {
+		t.Errorf("20ms job ran %d times in 210ms, want about 10", n)
+	}
+	// The first run waits a full interval.
+	if n := slow.Load(); n != 0 {
+		t.Errorf("hourly job ran %d times, want none yet", n)
+	}
+}
+
+func TestRunnerLimitsConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	job := func(name string) Job {
+		return Job{
+			Name:     name,
+			Interval: 5 * time.Millisecond,
+			Enabled:  true,
+			Run: func(context.Context) error {
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			},
+		}
+	}
+
+	runner := newTestRunner(1)
+	for _, name := range []string{"a", "b", "c"} {
+		runner.Register(job(name))
+	}
+	runner.Start(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	runner.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 1 {
+		t.Errorf("%d jobs ran at once, want at most 1", peak)
+	}
+}
+
+func TestRunnerStopWaitsForRunningJob(t *testing.T) {
+	var finished atomic.Bool
+	runner := newTestRunner(1)
+	runner.Register(Job{
+		Name:     "cancellable",
+		Interval: 5 * time.Millisecond,
+		Enabled:  true,
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			finished.Store(true)
+			return ctx.Err()
+		},
+	})
+
+	runner.Start(context.Background())
+	time.Sleep(20 * time.Millisecond)
+	runner.Stop()
+
+	if !finished.Load() {
+		t.Error("Stop returned before the running job did")
+	}
+}
+
+func TestRunnerCountsFailures(t *testing.T) {
+	const name = "failing"
+	errs := testutil.ToFloat64(metrics.JobErrorsTotal.WithLabelValues(name))
+	runs := testutil.ToFloat64(metrics.JobRunsTotal.WithLabelValues(name))
+
+	var attempts atomic.Int32
+	runner := newTestRunner(1)
+	runner.Register(Job{
+		Name:     name,
+		Interval: 10 * time.Millisecond,
+		Enabled:  true,
+		Run: func(context.Context) error {
+			// Fail every other run.
+			if attempts.Add(1)%2 == 1 {
+				return errors.New("boom")
+			}
+			return nil
+		},
+	})
+	runner.Start(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	runner.Stop()
+
+	n := float64(attempts.Load())
+	if got := testutil.ToFloat64(metrics.JobRunsTotal.WithLabelValues(name)) - runs; got != n {
+		t.Errorf("runs metric grew by %v, want %v", got, n)
+	}
+	if got := testutil.ToFloat64(metrics.JobErrorsTotal.WithLabelValues(name)) - errs; got != float64((attempts.Load()+1)/2) {
+		t.Errorf("errors metric grew by %v after %v runs, want every other run counted", got, n)
+	}
+}
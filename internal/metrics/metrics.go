@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	GRPCRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chat_service",
+		Name:      "grpc_requests_total",
+		Help:      "Total number of gRPC requests handled, by method and status code.",
+	}, []string{"method", "code"})
+
+	GRPCRequestErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chat_service",
+		Name:      "grpc_request_errors_total",
+		Help:      "Total number of gRPC requests that returned a non-OK status, by method and status code.",
+	}, []string{"method", "code"})
+
+	GRPCRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "chat_service",
+		Name:      "grpc_request_duration_seconds",
+		Help:      "Latency of gRPC requests, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	JobRunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chat_service",
+		Name:      "job_runs_total",
+		Help:      "Total number of background job runs, by job.",
+	}, []string{"job"})
+
+	JobErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chat_service",
+		Name:      "job_errors_total",
+		Help:      "Total number of background job runs that failed, by job.",
+	}, []string{"job"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "chat_service",
+		Name:      "job_duration_seconds",
+		Help:      "Duration of background job runs, by job.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"job"})
+
+	JobLastRunTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "chat_service",
+		Name:      "job_last_run_timestamp_seconds",
+		Help:      "Unix time at which each background job last started.",
+	}, []string{"job"})
+
+	CacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chat_service",
+		Name:      "cache_lookups_total",
+		Help:      "Total number of repository cache lookups, by entry and result (hit, miss, error or skipped while the breaker is open).",
+	}, []string{"entry", "result"})
+
+	CacheWriteErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "chat_service",
+		Name:      "cache_write_errors_total",
+		Help:      "Total number of failed repository cache writes, by operation (set or delete).",
+	}, []string{"op"})
+
+	StreamEventsDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "chat_service",
+		Name:      "stream_events_dropped_total",
+		Help:      "Total number of buffered stream events discarded to make room for newer ones under the drop_oldest slow-consumer policy.",
+	})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
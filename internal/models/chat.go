@@ -4,20 +4,320 @@ import (
 	"time"
 )
 
+type ChatType string
+
+const (
+	ChatTypeDirect ChatType = "direct"
+	ChatTypeGroup  ChatType = "group"
+)
+
 type Chat struct {
-	ID        string
-	UserID1   string
-	UserID2   string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             string
+	UserID1        string
+	UserID2        string
+	Name           string
+	Type           ChatType
+	ParticipantIDs []string
+	CreatedBy      string
+	IsCreator      bool
+	Source         string
+	MessageCount   int64
+	BytesUsed      int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	LastActivityAt time.Time
+	HasMessages    bool
+	UnreadCount    int
+	LastMessage    *Message
+	// Muted, MutedUntil and Archived are the caller's own settings; only set
+	// in chat lists.
+	Muted      bool
+	MutedUntil *time.Time
+	Archived   bool
+	// LastRead is the caller's own read position; only set for an
+	// authenticated participant.
+	LastRead *ReadPointer
+}
+
+// ArchiveFilter selects how a chat list treats chats the user has archived.
+type ArchiveFilter string
+
+const (
+	ArchiveFilterExclude ArchiveFilter = "exclude"
+	ArchiveFilterInclude ArchiveFilter = "include"
+	ArchiveFilterOnly    ArchiveFilter = "only"
+)
+
+func (c *Chat) HasParticipant(userID string) bool {
+	for _, id := range c.ParticipantIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
 }
 
 type Message struct {
+	ID          string
+	ChatID      string
+	SenderID    string
+	Content     string
+	ContentHash string
+	Language    string
+	CreatedAt   time.Time
+	ReadAt      *time.Time
+	DeliveredAt *time.Time
+	EditedAt    *time.Time
+	DeletedAt   *time.Time
+	Reactions   []*ReactionCount
+	Attachments []*Attachment
+	// ReplyToID is the message this one replies to, if any; ReplyPreview
+	// quotes the start of it and is only filled in for message pages.
+	ReplyToID    string
+	ReplyPreview string
+	// ForwardedFromID is the original message this one was forwarded from.
+	ForwardedFromID string
+	// Announcement marks a message the group's creator posted for every
+	// participant, which clients highlight.
+	Announcement bool
+	// RateLimit is the sender's standing against the rate limit, only set
+	// on the message a send returns while a limiter is configured.
+	RateLimit *RateLimitStatus
+}
+
+// RateLimitStatus is what a sender has left of the message rate limit after
+// a send. Warning is set once they are close enough to it that clients
+// should slow down before sends start being rejected.
+type RateLimitStatus struct {
+	Remaining int
+	Limit     int
+	Warning   bool
+}
+
+// Attachment describes a file stored in object storage; only the metadata
+// lives with the message.
+type Attachment struct {
+	URL       string
+	MimeType  string
+	SizeBytes int64
+	Filename  string
+}
+
+type MessageStatus string
+
+const (
+	MessageStatusSent      MessageStatus = "SENT"
+	MessageStatusDelivered MessageStatus = "DELIVERED"
+	MessageStatusRead      MessageStatus = "READ"
+)
+
+// Status derives the delivery status from the timestamps. Reading a message
+// also marks it delivered and neither is ever cleared, so the status only
+// moves forward.
+func (m *Message) Status() MessageStatus {
+	switch {
+	case m.ReadAt != nil:
+		return MessageStatusRead
+	case m.DeliveredAt != nil:
+		return MessageStatusDelivered
+	default:
+		return MessageStatusSent
+	}
+}
+
+type PinnedMessage struct {
+	Message  *Message
+	PinnedBy string
+	PinnedAt time.Time
+}
+
+// MessageEdit is an earlier version of a message: the content it had until
+// EditorID replaced it at EditedAt.
+type MessageEdit struct {
+	MessageID  string
+	OldContent string
+	EditorID   string
+	EditedAt   time.Time
+}
+
+// ReadPointer is a user's position in a chat: the newest message they have a
+// read receipt for.
+type ReadPointer struct {
+	MessageID        string
+	MessageCreatedAt time.Time
+	ReadAt           time.Time
+}
+
+type MessageRead struct {
+	UserID string
+	ReadAt time.Time
+}
+
+// Reaction is one user's emoji reaction on a message.
+type Reaction struct {
+	UserID string
+	Emoji  string
+}
+
+type ReactionCount struct {
+	Emoji string
+	Count int64
+	// Reacted is whether the user the counts were fetched for is among
+	// them.
+	Reacted bool
+}
+
+type MessageCursor struct {
+	CreatedAt time.Time
 	ID        string
+	// After pages towards newer messages, i.e. those after the position
+	// rather than before it.
+	After bool
+}
+
+// SearchHit is a matching message with the messages immediately before and
+// after it, both in chronological order.
+type SearchHit struct {
+	Message *Message
+	Before  []*Message
+	After   []*Message
+}
+
+// ChatSearchResults groups a user-wide search's hits from one chat, newest
+// first.
+type ChatSearchResults struct {
+	ChatID string
+	Hits   []*SearchSnippet
+}
+
+type SearchSnippet struct {
+	Message *Message
+	Snippet string
+}
+
+type SearchResultPage struct {
+	Chats      []*ChatSearchResults
+	NextCursor string
+}
+
+type Participant struct {
+	UserID   string
+	JoinedAt time.Time
+}
+
+type MessagePage struct {
+	Messages []*Message
+	// NextCursor continues with older messages and PrevCursor with newer
+	// ones; each is empty when there are none in that direction.
+	NextCursor string
+	PrevCursor string
+	// Joins lists participants who joined within the time span this page
+	// covers, so clients can interleave join markers with the messages.
+	Joins []*Participant
+}
+
+type DuplicateContent struct {
+	ContentHash   string
+	Occurrences   int
+	SampleContent string
+}
+
+type ContentSpread struct {
+	ContentHash string
+	Occurrences int
+	ChatCount   int
+	SenderCount int
+}
+
+type PairNormalizationReport struct {
+	ReversedDuplicates   int
+	NormalizedConstraint bool
+}
+
+type ChatList struct {
+	Chats     []*Chat
+	Truncated bool
+}
+
+// ChatPolicies is the set of limits in force for a chat. Zero values mean no
+// limit.
+type ChatPolicies struct {
+	ChatID           string
+	MaxMessageLength int
+	// LengthMode is how MaxMessageLength counts: runes, utf16 or
+	// graphemes. OversizePolicy says whether longer content is rejected
+	// or truncated.
+	LengthMode         string
+	OversizePolicy     string
+	MaxMessagesPerChat int64
+	MessagesRemaining  int64
+	AllowedLanguages   []string
+	WriteConcern       string
+	FastWritesAllowed  bool
+}
+
+type ChatStats struct {
+	ChatID       string
+	MessageCount int64
+	BytesUsed    int64
+}
+
+type HistogramBucket struct {
+	Start time.Time
+	Count int64
+}
+
+type ChatEventType string
+
+const (
+	ChatEventMessageCreated  ChatEventType = "message_created"
+	ChatEventMessageEdited   ChatEventType = "message_edited"
+	ChatEventMessageDeleted  ChatEventType = "message_deleted"
+	ChatEventReactionAdded   ChatEventType = "reaction_added"
+	ChatEventReactionRemoved ChatEventType = "reaction_removed"
+	ChatEventTyping          ChatEventType = "typing"
+	ChatEventChatDeleted     ChatEventType = "chat_deleted"
+)
+
+type ChatEvent struct {
+	Type    ChatEventType
+	ChatID  string
+	Message *Message
+	// UserID is who reacted or is typing; Emoji is set for reaction events
+	// and ExpiresAt for ephemeral ones such as typing.
+	UserID    string
+	Emoji     string
+	ExpiresAt time.Time
+}
+
+type AuditAction string
+
+const (
+	AuditActionCreateChat     AuditAction = "create_chat"
+	AuditActionDeleteChat     AuditAction = "delete_chat"
+	AuditActionSendMessage    AuditAction = "send_message"
+	AuditActionEditMessage    AuditAction = "edit_message"
+	AuditActionDeleteMessage  AuditAction = "delete_message"
+	AuditActionPinMessage     AuditAction = "pin_message"
+	AuditActionUnpinMessage   AuditAction = "unpin_message"
+	AuditActionBlockUser      AuditAction = "block_user"
+	AuditActionUnblockUser    AuditAction = "unblock_user"
+	AuditActionAddParticipant AuditAction = "add_participant"
+)
+
+type AuditEntry struct {
+	ID        int64
+	ActorID   string
+	Action    AuditAction
 	ChatID    string
-	SenderID  string
-	Content   string
+	TargetID  string
 	CreatedAt time.Time
-	ReadAt    *time.Time
 }
 
+type AuditFilter struct {
+	ActorID string
+	ChatID  string
+	From    *time.Time
+	To      *time.Time
+	Limit   int
+}
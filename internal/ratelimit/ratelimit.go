@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter decides whether an action keyed by key (e.g. a sender ID) may
+// proceed. Implementations must be safe for concurrent use; the in-memory one
+// below only limits per instance, a shared store is needed across replicas.
+type Limiter interface {
+	Take(ctx context.Context, key string) (Decision, error)
+}
+
+// Decision is the outcome of a Take: whether the action may proceed, and the
+// whole tokens left in the key's bucket afterwards out of Limit, so callers
+// can warn before the limit is hit.
+type Decision struct {
+	Allowed   bool
+	Remaining int
+	Limit     int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryLimiter is a token bucket per key holding up to perMinute tokens and
+// refilling continuously at perMinute per minute.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	capacity  float64
+	rate      float64
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter(perMinute int) *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets:   make(map[string]*bucket),
+		capacity:  float64(perMinute),
+		rate:      float64(perMinute) / time.Minute.Seconds(),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	decision, err := l.Take(ctx, key)
+	return decision.Allowed, err
+}
+
+func (l *MemoryLimiter) Take(_ context.Context, key string) (Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.capacity, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	decision := Decision{Limit: int(l.capacity)}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	}
+	decision.Remaining = int(b.tokens)
+	return decision, nil
+}
+
+func (l *MemoryLimiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.rate
+	if tokens > l.capacity {
+		tokens = l.capacity
+	}
+	return tokens
+}
+
+// sweep drops buckets that have refilled completely, since a fresh bucket
+// behaves the same, keeping memory bounded by recently active keys.
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= l.capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
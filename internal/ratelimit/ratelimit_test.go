@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(perMinute int) (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewMemoryLimiter(perMinute)
+	l.now = clock.Now
+	l.lastSweep = clock.now
+	return l, clock
+}
+
+func allowN(t *testing.T, l *MemoryLimiter, key string, n int) int {
+	t.Helper()
+	allowed := 0
+	for i := 0; i < n; i++ {
+		ok, err := l.Allow(context.Background(), key)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestMemoryLimiterWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute int
+		attempts  int
+		want      int
+	}{
+		{"under limit", 5, 3, 3},
+		{"at limit", 5, 5, 5},
+		{"burst over limit", 5, 8, 5},
+		{"single token", 1, 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := newTestLimiter(tt.perMinute)
+			if got := allowN(t, l, "sender", tt.attempts); got != tt.want {
+				t.Errorf("allowed %d of %d, want %d", got, tt.attempts, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    int
+	}{
+		{"no time passed", 0, 0},
+		{"less than one token", 11 * time.Second, 0},
+		{"one token", 12 * time.Second, 1},
+		{"half the window", 30 * time.Second, 2},
+		{"full window", time.Minute, 5},
+		{"capped at capacity", time.Hour, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 5 per minute refills one token every 12 seconds.
+			l, clock := newTestLimiter(5)
+			allowN(t, l, "sender", 5)
+
+			clock.Advance(tt.elapsed)
+			if got := allowN(t, l, "sender", 10); got != tt.want {
+				t.Errorf("allowed %d after %v, want %d", got, tt.elapsed, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryLimiterKeysAreIndependent(t *testing.T) {
+	l, _ := newTestLimiter(2)
+	allowN(t, l, "alice", 2)
+
+	if got := allowN(t, l, "alice", 1); got != 0 {
+		t.Errorf("alice allowed %d after exhausting her bucket, want 0", got)
+	}
+	if got := allowN(t, l, "bob", 2); got != 2 {
+		t.Errorf("bob allowed %d, want 2", got)
+	}
+}
+
+func TestMemoryLimiterSweepsFullBuckets(t *testing.T) {
+	l, clock := newTestLimiter(5)
+	allowN(t, l, "idle", 1)
+
+	clock.Advance(2 * time.Minute)
+	allowN(t, l, "active", 1)
+
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("refilled bucket for idle key was not swept")
+	}
+	if _, ok := l.buckets["active"]; !ok {
+		t.Error("bucket for active key is missing")
+	}
+}
+
+func TestMemoryLimiterTakeReportsRemaining(t *testing.T) {
+	l, clock := newTestLimiter(5)
+	ctx := context.Background()
+
+	for want := 4; want >= 0; want-- {
+		decision, err := l.Take(ctx, "sender")
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if !decision.Allowed || decision.Remaining != want || decision.Limit != 5 {
+			t.Fatalf("Take() = %+v, want allowed with %d of 5 left", decision, want)
+		}
+	}
+
+	decision, err := l.Take(ctx, "sender")
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("Take() over the limit = %+v, want rejected with nothing left", decision)
+	}
+
+	// A partly refilled token isn't counted until it is whole.
+	clock.Advance(18 * time.Second)
+	decision, _ = l.Take(ctx, "sender")
+	if !decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("Take() after 1.5 tokens refilled = %+v, want allowed with 0 left", decision)
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/lib/pq"
+)
+
+func insertAttachments(ctx context.Context, q queryer, msg *models.Message) error {
+	query := `
+	INSERT INTO attachments (message_id, url, mime_type, size_bytes, filename)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+
+	for _, a := range msg.Attachments {
+		if _, err := q.ExecContext(ctx, query, msg.ID, a.URL, a.MimeType, a.SizeBytes, a.Filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageBytes is what a message counts towards its chat's bytes_used: the
+// content plus the declared size of every attachment.
+func messageBytes(msg *models.Message) int64 {
+	total := int64(len(msg.Content))
+	for _, a := range msg.Attachments {
+		total += a.SizeBytes
+	}
+	return total
+}
+
+// GetAttachments returns attachment metadata keyed by message ID, in the
+// order the attachments were sent.
+func (r *chatRepository) GetAttachments(ctx context.Context, messageIDs []string) (map[string][]*models.Attachment, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	attachments := make(map[string][]*models.Attachment)
+	if len(messageIDs) == 0 {
+		return attachments, nil
+	}
+
+	query := `
+	SELECT message_id, url, mime_type, size_bytes, filename
+	FROM attachments
+	WHERE message_id = ANY($1::uuid[])
+	ORDER BY message_id, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(messageIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var a models.Attachment
+		if err := rows.Scan(&messageID, &a.URL, &a.MimeType, &a.SizeBytes, &a.Filename); err != nil {
+			return nil, err
+		}
+		attachments[messageID] = append(attachments[messageID], &a)
+	}
+
+	return attachments, rows.Err()
+}
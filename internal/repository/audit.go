@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"metachat/chat-service/internal/models"
+)
+
+func (r *chatRepository) writeAudit(ctx context.Context, q queryer, entry *models.AuditEntry) error {
+	if !r.options.AuditLog {
+		return nil
+	}
+
+	query := `
+	INSERT INTO audit_log (actor_id, action, chat_id, target_id)
+	VALUES ($1, $2, $3, $4)
+	`
+
+	chatID := sql.NullString{String: entry.ChatID, Valid: entry.ChatID != ""}
+	targetID := sql.NullString{String: entry.TargetID, Valid: entry.TargetID != ""}
+
+	_, err := q.ExecContext(ctx, query, entry.ActorID, string(entry.Action), chatID, targetID)
+	return err
+}
+
+func (r *chatRepository) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+
+	if filter.ActorID != "" {
+		args = append(args, filter.ActorID)
+		conditions = append(conditions, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	if filter.ChatID != "" {
+		args = append(args, filter.ChatID)
+		conditions = append(conditions, fmt.Sprintf("chat_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := `
+	SELECT id, actor_id, action, COALESCE(chat_id::text, ''), COALESCE(target_id::text, ''), created_at
+	FROM audit_log
+	`
+	if len(conditions) > 0 {
+		query += "WHERE " + strings.Join(conditions, " AND ") + "\n"
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf("ORDER BY created_at DESC, id DESC\nLIMIT $%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var action string
+		err := rows.Scan(&entry.ID, &entry.ActorID, &action, &entry.ChatID, &entry.TargetID, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Action = models.AuditAction(action)
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// newAuditedChat creates a direct chat with one message in an audited
+// repository.
+func newAuditedChat(t *testing.T, repo ChatRepository) (*models.Chat, *models.Message) {
+	t.Helper()
+	ctx := context.Background()
+
+	users := []string{uuid.NewString(), uuid.NewString()}
+	slices.Sort(users)
+	now := time.Now().UTC()
+	chat := &models.Chat{
+		ID:        uuid.NewString(),
+		UserID1:   users[0],
+		UserID2:   users[1],
+		CreatedBy: users[0],
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.CreateChat(ctx, chat); err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+
+	msg := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: users[0], Content: "hello"}
+	if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	return chat, msg
+}
+
+// auditActions returns the actions of the entries matching filter, oldest
+// first.
+func auditActions(t *testing.T, repo ChatRepository, filter models.AuditFilter) []models.AuditAction {
+	t.Helper()
+
+	filter.Limit = 100
+	entries, err := repo.GetAuditLog(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	var actions []models.AuditAction
+	for i := len(entries) - 1; i >= 0; i-- {
+		actions = append(actions, entries[i].Action)
+	}
+	return actions
+}
+
+func TestPinMessageWritesAudit(t *testing.T) {
+	repo := newTestRepository(t, Options{AuditLog: true})
+	ctx := context.Background()
+	chat, msg := newAuditedChat(t, repo)
+	pinner := chat.UserID2
+
+	// Repeats change nothing, so they leave no entries either.
+	for i := 0; i < 2; i++ {
+		if err := repo.PinMessage(ctx, msg, pinner, 0); err != nil {
+			t.Fatalf("PinMessage() error = %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := repo.UnpinMessage(ctx, msg.ID, pinner); err != nil {
+			t.Fatalf("UnpinMessage() error = %v", err)
+		}
+	}
+
+	entries, err := repo.GetAuditLog(ctx, models.AuditFilter{ActorID: pinner, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	want := []models.AuditAction{models.AuditActionPinMessage, models.AuditActionUnpinMessage}
+	if got := auditActions(t, repo, models.AuditFilter{ActorID: pinner}); !slices.Equal(got, want) {
+		t.Fatalf("audited %v, want %v", got, want)
+	}
+	for _, entry := range entries {
+		if entry.ChatID != chat.ID || entry.TargetID != msg.ID {
+			t.Errorf("%s entry = chat %q, target %q, want %q, %q", entry.Action, entry.ChatID, entry.TargetID, chat.ID, msg.ID)
+		}
+	}
+}
+
+func TestBlockUserWritesAudit(t *testing.T) {
+	repo := newTestRepository(t, Options{AuditLog: true})
+	ctx := context.Background()
+	blocker, blocked := uuid.NewString(), uuid.NewString()
+
+	for i := 0; i < 2; i++ {
+		if err := repo.BlockUser(ctx, blocker, blocked); err != nil {
+			t.Fatalf("BlockUser() error = %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := repo.UnblockUser(ctx, blocker, blocked); err != nil {
+			t.Fatalf("UnblockUser() error = %v", err)
+		}
+	}
+
+	entries, err := repo.GetAuditLog(ctx, models.AuditFilter{ActorID: blocker, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	want := []models.AuditAction{models.AuditActionBlockUser, models.AuditActionUnblockUser}
+	if got := auditActions(t, repo, models.AuditFilter{ActorID: blocker}); !slices.Equal(got, want) {
+		t.Fatalf("audited %v, want %v", got, want)
+	}
+	for _, entry := range entries {
+		if entry.TargetID != blocked || entry.ChatID != "" {
+			t.Errorf("%s entry = chat %q, target %q, want no chat and %q", entry.Action, entry.ChatID, entry.TargetID, blocked)
+		}
+	}
+}
+
+func TestChatMutationsWriteAudit(t *testing.T) {
+	repo := newTestRepository(t, Options{AuditLog: true})
+	ctx := context.Background()
+	chat, msg := newAuditedChat(t, repo)
+
+	msg.Content = "hello again"
+	if err := repo.UpdateMessageContent(ctx, msg); err != nil {
+		t.Fatalf("UpdateMessageContent() error = %v", err)
+	}
+	if _, _, err := repo.SoftDeleteMessage(ctx, msg.ID, false); err != nil {
+		t.Fatalf("SoftDeleteMessage() error = %v", err)
+	}
+	if err := repo.DeleteChat(ctx, chat.ID, chat.UserID2); err != nil {
+		t.Fatalf("DeleteChat() error = %v", err)
+	}
+
+	want := []models.AuditAction{
+		models.AuditActionCreateChat,
+		models.AuditActionAddParticipant,
+		models.AuditActionAddParticipant,
+		models.AuditActionSendMessage,
+		models.AuditActionEditMessage,
+		models.AuditActionDeleteMessage,
+		models.AuditActionDeleteChat,
+	}
+	if got := auditActions(t, repo, models.AuditFilter{ChatID: chat.ID}); !slices.Equal(got, want) {
+		t.Fatalf("audited %v, want %v", got, want)
+	}
+}
+
+func TestCreateGroupChatAuditsEachParticipant(t *testing.T) {
+	repo := newTestRepository(t, Options{AuditLog: true})
+	ctx := context.Background()
+
+	creator := uuid.NewString()
+	members := []string{creator, uuid.NewString(), uuid.NewString()}
+	chat := &models.Chat{ID: uuid.NewString(), Name: "team", CreatedBy: creator, ParticipantIDs: members}
+	if err := repo.CreateGroupChat(ctx, chat); err != nil {
+		t.Fatalf("CreateGroupChat() error = %v", err)
+	}
+
+	entries, err := repo.GetAuditLog(ctx, models.AuditFilter{ChatID: chat.ID, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	var added []string
+	for _, entry := range entries {
+		if entry.ActorID != creator {
+			t.Errorf("%s entry actor = %q, want the creator %q", entry.Action, entry.ActorID, creator)
+		}
+		if entry.Action == models.AuditActionAddParticipant {
+			added = append(added, entry.TargetID)
+		}
+	}
+	slices.Sort(added)
+	slices.Sort(members)
+	if !slices.Equal(added, members) {
+		t.Errorf("audited participants %v, want %v", added, members)
+	}
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// BlockUser records that blockerID has blocked blockedID and audits it.
+// Blocking twice is a no-op.
+func (r *chatRepository) BlockUser(ctx context.Context, blockerID, blockedID string) error {
+	query := `
+	INSERT INTO blocks (blocker_id, blocked_id)
+	VALUES ($1, $2)
+	ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`
+
+	return r.changeBlock(ctx, query, blockerID, blockedID, models.AuditActionBlockUser)
+}
+
+// UnblockUser lifts blockerID's block on blockedID and audits it. Unblocking
+// a user who isn't blocked is a no-op.
+func (r *chatRepository) UnblockUser(ctx context.Context, blockerID, blockedID string) error {
+	query := `
+	DELETE FROM blocks
+	WHERE blocker_id = $1 AND blocked_id = $2
+	`
+
+	return r.changeBlock(ctx, query, blockerID, blockedID, models.AuditActionUnblockUser)
+}
+
+// changeBlock runs query and, if it changed a row, writes the audit entry
+// in the same transaction.
+func (r *chatRepository) changeBlock(ctx context.Context, query, blockerID, blockedID string, action models.AuditAction) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return nil
+	}
+
+	err = r.writeAudit(ctx, tx, &models.AuditEntry{
+		ActorID:  blockerID,
+		Action:   action,
+		TargetID: blockedID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsBlockedBy reports whether any of blockerIDs has blocked blockedID.
+func (r *chatRepository) IsBlockedBy(ctx context.Context, blockedID string, blockerIDs []string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if len(blockerIDs) == 0 {
+		return false, nil
+	}
+
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM blocks
+		WHERE blocked_id = $1 AND blocker_id = ANY($2::uuid[])
+	)
+	`
+
+	var blocked bool
+	err := r.db.QueryRowContext(ctx, query, blockedID, pq.Array(blockerIDs)).Scan(&blocked)
+	return blocked, err
+}
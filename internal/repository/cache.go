@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"metachat/chat-service/internal/cache"
+	"metachat/chat-service/internal/metrics"
+	"metachat/chat-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cachedRepository serves GetChatByID and GetUserChats cache-aside from a
+// Store, and drops the affected entries after writes that change them. Cache
+// failures are counted, logged and otherwise ignored: reads fall through to
+// next and stale entries expire with the TTL. That includes invalidations
+// skipped while a Breaker around the store is open, which are only counted so
+// an outage logs no more than the failures that opened the breaker.
+type cachedRepository struct {
+	ChatRepository
+	store  cache.Store
+	ttl    time.Duration
+	logger *logrus.Logger
+}
+
+func NewCachedRepository(repo ChatRepository, store cache.Store, ttl time.Duration, logger *logrus.Logger) ChatRepository {
+	return &cachedRepository{ChatRepository: repo, store: store, ttl: ttl, logger: logger}
+}
+
+func chatKey(chatID string) string {
+	return "chat:" + chatID
+}
+
+func userChatsKey(userID string) string {
+	return "user_chats:" + userID
+}
+
+func (r *cachedRepository) GetChatByID(ctx context.Context, id string) (*models.Chat, error) {
+	var chat *models.Chat
+	if r.get(ctx, "chat", chatKey(id), "chat", &chat) {
+		return chat, nil
+	}
+
+	chat, err := r.ChatRepository.GetChatByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.set(ctx, chatKey(id), "chat", chat)
+	return chat, nil
+}
+
+func (r *cachedRepository) GetUserChats(ctx context.Context, userID string, filter UserChatsFilter) ([]*models.Chat, error) {
+	field := fmt.Sprintf("%t:%t:%s:%d", filter.IncludeEmpty, filter.ExcludeBlocked, filter.Archived, filter.Limit)
+
+	var chats []*models.Chat
+	if r.get(ctx, "user_chats", userChatsKey(userID), field, &chats) {
+		return chats, nil
+	}
+
+	chats, err := r.ChatRepository.GetUserChats(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	r.set(ctx, userChatsKey(userID), field, chats)
+	return chats, nil
+}
+
+func (r *cachedRepository) CreateChat(ctx context.Context, chat *models.Chat) error {
+	if err := r.ChatRepository.CreateChat(ctx, chat); err != nil {
+		return err
+	}
+	r.invalidateUsers(ctx, []string{chat.UserID1, chat.UserID2})
+	return nil
+}
+
+func (r *cachedRepository) CreateGroupChat(ctx context.Context, chat *models.Chat) error {
+	if err := r.ChatRepository.CreateGroupChat(ctx, chat); err != nil {
+		return err
+	}
+	r.invalidateUsers(ctx, chat.ParticipantIDs)
+	return nil
+}
+
+// UpdateChat drops the lists of both the previous and the updated
+// participants, so users added or removed by the update see the change.
+func (r *cachedRepository) UpdateChat(ctx context.Context, chat *models.Chat) error {
+	previous := r.participantIDs(ctx, chat.ID)
+	if err := r.ChatRepository.UpdateChat(ctx, chat); err != nil {
+		return err
+	}
+	keys := append(userChatsKeys(previous), userChatsKeys(chat.ParticipantIDs)...)
+	r.delete(ctx, append(keys, chatKey(chat.ID))...)
+	return nil
+}
+
+func (r *cachedRepository) DeleteChat(ctx context.Context, chatID, actorID string) error {
+	participants := r.participantIDs(ctx, chatID)
+	if err := r.ChatRepository.DeleteChat(ctx, chatID, actorID); err != nil {
+		return err
+	}
+	r.delete(ctx, append(userChatsKeys(participants), chatKey(chatID))...)
+	return nil
+}
+
+func (r *cachedRepository) HideChat(ctx context.Context, chatID, userID string) error {
+	if err := r.ChatRepository.HideChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+	r.delete(ctx, userChatsKey(userID))
+	return nil
+}
+
+func (r *cachedRepository) BlockUser(ctx context.Context, blockerID, blockedID string) error {
+	if err := r.ChatRepository.BlockUser(ctx, blockerID, blockedID); err != nil {
+		return err
+	}
+	r.delete(ctx, userChatsKey(blockerID))
+	return nil
+}
+
+func (r *cachedRepository) UnblockUser(ctx context.Context, blockerID, blockedID string) error {
+	if err := r.ChatRepository.UnblockUser(ctx, blockerID, blockedID); err != nil {
+		return err
+	}
+	r.delete(ctx, userChatsKey(blockerID))
+	return nil
+}
+
+func (r *cachedRepository) SetChatMute(ctx context.Context, chatID, userID string, muted bool, until *time.Time) error {
+	if err := r.ChatRepository.SetChatMute(ctx, chatID, userID, muted, until); err != nil {
+		return err
+	}
+	r.delete(ctx, userChatsKey(userID))
+	return nil
+}
+
+func (r *cachedRepository) SetChatArchived(ctx context.Context, chatID, userID string, archived bool) error {
+	if err := r.ChatRepository.SetChatArchived(ctx, chatID, userID, archived); err != nil {
+		return err
+	}
+	r.delete(ctx, userChatsKey(userID))
+	return nil
+}
+
+// CreateMessage and CreateMessageMarkingRead also unarchive the chat for the
+// recipients, so their invalidation must keep covering every participant's
+// chat list, not just the sender's.
+func (r *cachedRepository) CreateMessage(ctx context.Context, msg *models.Message, maxMessages int64) error {
+	if err := r.ChatRepository.CreateMessage(ctx, msg, maxMessages); err != nil {
+		return err
+	}
+	r.invalidateChat(ctx, msg.ChatID)
+	return nil
+}
+
+func (r *cachedRepository) CreateMessageMarkingRead(ctx context.Context, msg *models.Message, maxMessages int64) (int, error) {
+	count, err := r.ChatRepository.CreateMessageMarkingRead(ctx, msg, maxMessages)
+	if err != nil {
+		return 0, err
+	}
+	r.invalidateChat(ctx, msg.ChatID)
+	return count, nil
+}
+
+func (r *cachedRepository) UpdateMessageContent(ctx context.Context, msg *models.Message) error {
+	if err := r.ChatRepository.UpdateMessageContent(ctx, msg); err != nil {
+		return err
+	}
+	r.invalidateChat(ctx, msg.ChatID)
+	return nil
+}
+
+func (r *cachedRepository) SoftDeleteMessage(ctx context.Context, id string, clearReactions bool) (time.Time, []*models.Reaction, error) {
+	msg, err := r.ChatRepository.GetMessageByID(ctx, id)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	deletedAt, cleared, err := r.ChatRepository.SoftDeleteMessage(ctx, id, clearReactions)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	r.invalidateChat(ctx, msg.ChatID)
+	return deletedAt, cleared, nil
+}
+
+func (r *cachedRepository) MarkMessagesAsRead(ctx context.Context, chatID, userID string) (int, error) {
+	count, err := r.ChatRepository.MarkMessagesAsRead(ctx, chatID, userID)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		r.delete(ctx, userChatsKey(userID))
+	}
+	return count, nil
+}
+
+// invalidateChat drops the chat and every participant's chat lists, which
+// embed its last message and counters.
+func (r *cachedRepository) invalidateChat(ctx context.Context, chatID string) {
+	participants := r.participantIDs(ctx, chatID)
+	r.delete(ctx, append(userChatsKeys(participants), chatKey(chatID))...)
+}
+
+func (r *cachedRepository) invalidateUsers(ctx context.Context, userIDs []string) {
+	r.delete(ctx, userChatsKeys(userIDs)...)
+}
+
+// participantIDs reads the participants from the database: a cached copy may
+// be stale, and reading through the cache would refill the entry that is about
+// to be dropped.
+func (r *cachedRepository) participantIDs(ctx context.Context, chatID string) []string {
+	chat, err := r.ChatRepository.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil
+	}
+	return chat.ParticipantIDs
+}
+
+func userChatsKeys(userIDs []string) []string {
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = userChatsKey(id)
+	}
+	return keys
+}
+
+func (r *cachedRepository) get(ctx context.Context, entry, key, field string, dest interface{}) bool {
+	data, err := r.store.Get(ctx, key, field)
+	if err == nil {
+		err = json.Unmarshal(data, dest)
+	}
+
+	switch {
+	case err == nil:
+		metrics.CacheLookupsTotal.WithLabelValues(entry, "hit").Inc()
+		return true
+	case errors.Is(err, cache.ErrMiss):
+		metrics.CacheLookupsTotal.WithLabelValues(entry, "miss").Inc()
+	case errors.Is(err, cache.ErrBreakerOpen):
+		metrics.CacheLookupsTotal.WithLabelValues(entry, "skipped").Inc()
+	default:
+		metrics.CacheLookupsTotal.WithLabelValues(entry, "error").Inc()
+		r.logger.WithError(err).WithField("key", key).Warn("Cache read failed, falling through to the database")
+	}
+	return false
+}
+
+func (r *cachedRepository) set(ctx context.Context, key, field string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = r.store.Set(ctx, key, field, data, r.ttl)
+	}
+	if err != nil {
+		metrics.CacheWriteErrorsTotal.WithLabelValues("set").Inc()
+		if !errors.Is(err, cache.ErrBreakerOpen) {
+			r.logger.WithError(err).WithField("key", key).Warn("Cache write failed")
+		}
+	}
+}
+
+func (r *cachedRepository) delete(ctx context.Context, keys ...string) {
+	if err := r.store.Delete(ctx, keys...); err != nil {
+		metrics.CacheWriteErrorsTotal.WithLabelValues("delete").Inc()
+		if !errors.Is(err, cache.ErrBreakerOpen) {
+			r.logger.WithError(err).WithField("keys", keys).Warn("Cache invalidation failed, entries will expire with the TTL")
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/cache"
+	"metachat/chat-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+var errRedisDown = errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+
+// failingStore stands in for a Redis that can't be reached.
+type failingStore struct {
+	calls int
+}
+
+func (s *failingStore) Get(context.Context, string, string) ([]byte, error) {
+	s.calls++
+	return nil, errRedisDown
+}
+
+func (s *failingStore) Set(context.Context, string, string, []byte, time.Duration) error {
+	s.calls++
+	return errRedisDown
+}
+
+func (s *failingStore) Delete(context.Context, ...string) error {
+	s.calls++
+	return errRedisDown
+}
+
+// stubRepository answers the calls the cache makes with fixed data; any
+// other call panics through the nil embedded interface.
+type stubRepository struct {
+	ChatRepository
+	chat     *models.Chat
+	message  *models.Message
+	chatGets int
+}
+
+func (r *stubRepository) GetChatByID(_ context.Context, id string) (*models.Chat, error) {
+	r.chatGets++
+	if id != r.chat.ID {
+		return nil, ErrChatNotFound
+	}
+	chat := *r.chat
+	return &chat, nil
+}
+
+func (r *stubRepository) GetUserChats(context.Context, string, UserChatsFilter) ([]*models.Chat, error) {
+	chat := *r.chat
+	return []*models.Chat{&chat}, nil
+}
+
+func (r *stubRepository) GetMessageByID(context.Context, string) (*models.Message, error) {
+	msg := *r.message
+	return &msg, nil
+}
+
+func (r *stubRepository) CreateMessage(_ context.Context, msg *models.Message, _ int64) error {
+	msg.CreatedAt = time.Now().UTC()
+	return nil
+}
+
+func (r *stubRepository) UpdateMessageContent(_ context.Context, msg *models.Message) error {
+	editedAt := time.Now().UTC()
+	msg.EditedAt = &editedAt
+	return nil
+}
+
+func (r *stubRepository) SoftDeleteMessage(context.Context, string, bool) (time.Time, []*models.Reaction, error) {
+	return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), nil, nil
+}
+
+func (r *stubRepository) MarkMessagesAsRead(context.Context, string, string) (int, error) {
+	return 2, nil
+}
+
+func (r *stubRepository) DeleteChat(context.Context, string, string) error {
+	return nil
+}
+
+func newStubRepository() *stubRepository {
+	chat := &models.Chat{
+		ID:             "3d6f8a2e-1c4b-4e5f-9a7b-2c3d4e5f6a7b",
+		UserID1:        "6f1c2a9e-3b4d-4c5e-8f7a-1b2c3d4e5f60",
+		UserID2:        "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d",
+		ParticipantIDs: []string{"6f1c2a9e-3b4d-4c5e-8f7a-1b2c3d4e5f60", "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"},
+	}
+	return &stubRepository{
+		chat: chat,
+		message: &models.Message{
+			ID:       "9b8a7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d",
+			ChatID:   chat.ID,
+			SenderID: chat.UserID1,
+			Content:  "hello",
+		},
+	}
+}
+
+func TestCachedRepositoryFallsThroughWhenRedisFails(t *testing.T) {
+	db := newStubRepository()
+	logger, hook := test.NewNullLogger()
+	repo := NewCachedRepository(db, &failingStore{}, time.Minute, logger)
+	ctx := context.Background()
+
+	chat, err := repo.GetChatByID(ctx, db.chat.ID)
+	if err != nil {
+		t.Fatalf("GetChatByID() error = %v", err)
+	}
+	if chat.ID != db.chat.ID {
+		t.Errorf("GetChatByID() = %q, want %q", chat.ID, db.chat.ID)
+	}
+
+	if _, err := repo.GetChatByID(ctx, "missing"); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("GetChatByID(missing) error = %v, want ErrChatNotFound", err)
+	}
+
+	chats, err := repo.GetUserChats(ctx, db.chat.UserID1, UserChatsFilter{})
+	if err != nil {
+		t.Fatalf("GetUserChats() error = %v", err)
+	}
+	if len(chats) != 1 || chats[0].ID != db.chat.ID {
+		t.Errorf("GetUserChats() = %v, want the stored chat", chats)
+	}
+
+	msg := &models.Message{ID: db.message.ID, ChatID: db.chat.ID, SenderID: db.chat.UserID1, Content: "hi"}
+	if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	if msg.CreatedAt.IsZero() {
+		t.Error("CreateMessage() did not return the stored message")
+	}
+	if err := repo.UpdateMessageContent(ctx, msg); err != nil {
+		t.Fatalf("UpdateMessageContent() error = %v", err)
+	}
+	if deletedAt, _, err := repo.SoftDeleteMessage(ctx, msg.ID, false); err != nil || deletedAt.IsZero() {
+		t.Fatalf("SoftDeleteMessage() = %v, %v", deletedAt, err)
+	}
+	if count, err := repo.MarkMessagesAsRead(ctx, db.chat.ID, db.chat.UserID1); err != nil || count != 2 {
+		t.Fatalf("MarkMessagesAsRead() = %d, %v, want 2, nil", count, err)
+	}
+	if err := repo.DeleteChat(ctx, db.chat.ID, db.chat.UserID1); err != nil {
+		t.Fatalf("DeleteChat() error = %v", err)
+	}
+
+	if len(hook.AllEntries()) == 0 {
+		t.Fatal("no cache failures were logged")
+	}
+	for _, entry := range hook.AllEntries() {
+		if entry.Level != logrus.WarnLevel {
+			t.Errorf("logged %q at %s, want warn", entry.Message, entry.Level)
+		}
+	}
+}
+
+func TestCachedRepositoryStopsCallingRedisWhenBreakerOpens(t *testing.T) {
+	db := newStubRepository()
+	store := &failingStore{}
+	logger, hook := test.NewNullLogger()
+	repo := NewCachedRepository(db, cache.NewBreaker(store, 2, time.Minute), time.Minute, logger)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		if _, err := repo.GetChatByID(ctx, db.chat.ID); err != nil {
+			t.Fatalf("GetChatByID() error = %v", err)
+		}
+	}
+
+	// The read and the refill of the first call open the breaker; later
+	// calls skip Redis and log nothing more.
+	if store.calls != 2 {
+		t.Errorf("store calls = %d, want 2", store.calls)
+	}
+	if got := len(hook.AllEntries()); got != 2 {
+		t.Errorf("logged %d entries, want 2", got)
+	}
+	if db.chatGets != 5 {
+		t.Errorf("database reads = %d, want 5", db.chatGets)
+	}
+}
+
+func TestCachedRepositoryInvalidationReadsParticipantsFromDatabase(t *testing.T) {
+	db := newStubRepository()
+	store := &recordingStore{}
+	repo := NewCachedRepository(db, store, time.Minute, logrus.New())
+	ctx := context.Background()
+
+	// Warm the cache with the chat, then change its participants behind it.
+	if _, err := repo.GetChatByID(ctx, db.chat.ID); err != nil {
+		t.Fatalf("GetChatByID() error = %v", err)
+	}
+	newcomer := "5c4b3a29-1807-4f6e-9d5c-4b3a29180706"
+	db.chat.ParticipantIDs = append(db.chat.ParticipantIDs, newcomer)
+	sets := store.sets
+
+	msg := &models.Message{ID: db.message.ID, ChatID: db.chat.ID, SenderID: db.chat.UserID1, Content: "hi"}
+	if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	if store.sets != sets {
+		t.Errorf("invalidation refilled the cache: %d writes, want %d", store.sets, sets)
+	}
+	if !store.deleted[userChatsKey(newcomer)] {
+		t.Errorf("deleted %v, want the newcomer's chat list dropped too", store.deleted)
+	}
+}
+
+// recordingStore is an in-memory Store that records writes and deletions.
+type recordingStore struct {
+	values  map[string][]byte
+	sets    int
+	deleted map[string]bool
+}
+
+func (s *recordingStore) Get(_ context.Context, key, field string) ([]byte, error) {
+	value, ok := s.values[key+"/"+field]
+	if !ok {
+		return nil, cache.ErrMiss
+	}
+	return value, nil
+}
+
+func (s *recordingStore) Set(_ context.Context, key, field string, value []byte, _ time.Duration) error {
+	if s.values == nil {
+		s.values = make(map[string][]byte)
+	}
+	s.values[key+"/"+field] = value
+	s.sets++
+	return nil
+}
+
+func (s *recordingStore) Delete(_ context.Context, keys ...string) error {
+	if s.deleted == nil {
+		s.deleted = make(map[string]bool)
+	}
+	for _, key := range keys {
+		s.deleted[key] = true
+		for k := range s.values {
+			if strings.HasPrefix(k, key+"/") {
+				delete(s.values, k)
+			}
+		}
+	}
+	return nil
+}
@@ -7,73 +7,242 @@ import (
 	"time"
 
 	"metachat/chat-service/internal/models"
+
+	"github.com/lib/pq"
 )
 
 type ChatRepository interface {
 	CreateChat(ctx context.Context, chat *models.Chat) error
+	CreateGroupChat(ctx context.Context, chat *models.Chat) error
 	GetChatByID(ctx context.Context, id string) (*models.Chat, error)
+	GetChatsByIDs(ctx context.Context, userID string, ids []string) ([]*models.Chat, error)
 	GetChatByUsers(ctx context.Context, userID1, userID2 string) (*models.Chat, error)
-	GetUserChats(ctx context.Context, userID string) ([]*models.Chat, error)
+	GetUserChats(ctx context.Context, userID string, filter UserChatsFilter) ([]*models.Chat, error)
+	GetChatsWithPeers(ctx context.Context, userID string, peerIDs []string) ([]*models.Chat, error)
 	UpdateChat(ctx context.Context, chat *models.Chat) error
-	CreateMessage(ctx context.Context, msg *models.Message) error
-	GetChatMessages(ctx context.Context, chatID string, limit int, beforeMessageID string) ([]*models.Message, error)
+	GetChatParticipants(ctx context.Context, chatID string) ([]*models.Participant, error)
+	DeleteChat(ctx context.Context, chatID, actorID string) error
+	HideChat(ctx context.Context, chatID, userID string) error
+	BlockUser(ctx context.Context, blockerID, blockedID string) error
+	UnblockUser(ctx context.Context, blockerID, blockedID string) error
+	IsBlockedBy(ctx context.Context, blockedID string, blockerIDs []string) (bool, error)
+	SetChatMute(ctx context.Context, chatID, userID string, muted bool, until *time.Time) error
+	SetChatArchived(ctx context.Context, chatID, userID string, archived bool) error
+	GetMutedUserIDs(ctx context.Context, chatID string, userIDs []string) ([]string, error)
+	PinMessage(ctx context.Context, msg *models.Message, userID string, maxPins int) error
+	UnpinMessage(ctx context.Context, messageID, userID string) error
+	GetPinnedMessages(ctx context.Context, chatID string) ([]*models.PinnedMessage, error)
+	CreateMessage(ctx context.Context, msg *models.Message, maxMessages int64) error
+	CreateMessageMarkingRead(ctx context.Context, msg *models.Message, maxMessages int64) (int, error)
+	GetMessageByID(ctx context.Context, id string) (*models.Message, error)
+	GetMessagesByIDs(ctx context.Context, ids []string) ([]*models.Message, error)
+	GetReplies(ctx context.Context, parentID string) ([]*models.Message, error)
+	GetReplyDepth(ctx context.Context, messageID string, maxDepth int) (int, error)
+	UpdateMessageContent(ctx context.Context, msg *models.Message) error
+	GetMessageEdits(ctx context.Context, messageID string) ([]*models.MessageEdit, error)
+	SoftDeleteMessage(ctx context.Context, id string, clearReactions bool) (time.Time, []*models.Reaction, error)
+	GetChatMessages(ctx context.Context, chatID string, limit int, cursor *models.MessageCursor, includeDeleted bool) ([]*models.Message, error)
+	GetMessageContext(ctx context.Context, chatID string, messageIDs []string, radius int) ([]*models.Message, error)
+	SearchChatMessages(ctx context.Context, chatID, text string, limit int) ([]*models.Message, error)
+	SearchUserMessages(ctx context.Context, userID, text string, limit int, before *models.MessageCursor) ([]*models.Message, error)
 	MarkMessagesAsRead(ctx context.Context, chatID, userID string) (int, error)
-	InitializeTables() error
+	MarkMessagesAsDelivered(ctx context.Context, chatID, userID string) (int, error)
+	GetMessageReaders(ctx context.Context, messageID string) ([]*models.MessageRead, error)
+	GetLastRead(ctx context.Context, chatID, userID string) (*models.ReadPointer, error)
+	GetFirstUnreadMessage(ctx context.Context, chatID, userID string) (*models.Message, error)
+	AddReaction(ctx context.Context, messageID, userID, emoji string) error
+	RemoveReaction(ctx context.Context, messageID, userID, emoji string) error
+	ToggleReaction(ctx context.Context, messageID, userID, emoji string) (bool, error)
+	GetReactionCounts(ctx context.Context, messageIDs []string, viewerID string) (map[string][]*models.ReactionCount, error)
+	GetAttachments(ctx context.Context, messageIDs []string) (map[string][]*models.Attachment, error)
+	GetMessageHistogram(ctx context.Context, chatID, bucket string, from, to time.Time, fillGaps bool) ([]*models.HistogramBucket, error)
+	GetTopDuplicatedContent(ctx context.Context, limit int) ([]*models.DuplicateContent, error)
+	GetContentSpread(ctx context.Context, contentHash string) (*models.ContentSpread, error)
+	GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+	CheckPairNormalization(ctx context.Context) (*models.PairNormalizationReport, error)
+	EnsureSearchIndex() error
+}
+
+const messageColumns = `id, chat_id, sender_id, content, COALESCE(language, ''), created_at, read_at, edited_at, deleted_at, COALESCE(reply_to_message_id::text, ''), COALESCE(forwarded_from_message_id::text, ''), delivered_at, announcement`
+
+const prefixedMessageColumns = `m.id, m.chat_id, m.sender_id, m.content, COALESCE(m.language, ''), m.created_at, m.read_at, m.edited_at, m.deleted_at, COALESCE(m.reply_to_message_id::text, ''), COALESCE(m.forwarded_from_message_id::text, ''), m.delivered_at, m.announcement`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row rowScanner, extra ...interface{}) (*models.Message, error) {
+	var msg models.Message
+	var readAt, editedAt, deletedAt, deliveredAt sql.NullTime
+	err := row.Scan(append([]interface{}{
+		&msg.ID, &msg.ChatID, &msg.SenderID, &msg.Content, &msg.Language, &msg.CreatedAt, &readAt, &editedAt, &deletedAt, &msg.ReplyToID, &msg.ForwardedFromID, &deliveredAt, &msg.Announcement,
+	}, extra...)...)
+	if err != nil {
+		return nil, err
+	}
+	if deliveredAt.Valid {
+		msg.DeliveredAt = &deliveredAt.Time
+	}
+	if readAt.Valid {
+		msg.ReadAt = &readAt.Time
+	}
+	if editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		msg.DeletedAt = &deletedAt.Time
+	}
+	return &msg, nil
+}
+
+const chatColumns = `c.id, COALESCE(c.user_id1::text, ''), COALESCE(c.user_id2::text, ''), c.created_by,
+	COALESCE(c.source, ''), COALESCE(c.name, ''), c.type, c.message_count, c.bytes_used,
+	c.created_at, c.updated_at,
+	ARRAY(SELECT p.user_id::text FROM chat_participants p WHERE p.chat_id = c.id ORDER BY p.joined_at, p.user_id)`
+
+func scanChat(row rowScanner, extra ...interface{}) (*models.Chat, error) {
+	var chat models.Chat
+	dest := []interface{}{
+		&chat.ID, &chat.UserID1, &chat.UserID2, &chat.CreatedBy,
+		&chat.Source, &chat.Name, &chat.Type, &chat.MessageCount, &chat.BytesUsed,
+		&chat.CreatedAt, &chat.UpdatedAt,
+		pq.Array(&chat.ParticipantIDs),
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+type UserChatsFilter struct {
+	IncludeEmpty bool
+	// ExcludeBlocked drops direct chats with users the caller has blocked.
+	ExcludeBlocked bool
+	// Archived defaults to leaving archived chats out.
+	Archived models.ArchiveFilter
+	Limit    int
+}
+
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type Options struct {
+	Schema   string
+	AuditLog bool
+	// QueryTimeout bounds each repository call; zero leaves the caller's
+	// deadline, if any, as the only limit.
+	QueryTimeout time.Duration
+	// FullTextSearch matches searches with a tsvector GIN index instead of
+	// ILIKE substring scans.
+	FullTextSearch bool
 }
 
 type chatRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	options Options
 }
 
-func NewChatRepository(db *sql.DB) ChatRepository {
+func NewChatRepository(db *sql.DB, opts Options) ChatRepository {
+	if opts.Schema == "" {
+		opts.Schema = "public"
+	}
 	return &chatRepository{
-		db: db,
+		db:      db,
+		options: opts,
 	}
 }
 
-func (r *chatRepository) InitializeTables() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS chats (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		user_id1 UUID NOT NULL,
-		user_id2 UUID NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		UNIQUE(user_id1, user_id2)
-	);
-
-	CREATE TABLE IF NOT EXISTS messages (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
-		sender_id UUID NOT NULL,
-		content TEXT NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		read_at TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
-	CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at);
-	CREATE INDEX IF NOT EXISTS idx_chats_user1 ON chats(user_id1);
-	CREATE INDEX IF NOT EXISTS idx_chats_user2 ON chats(user_id2);
-	`
+// withTimeout applies the configured query timeout to ctx. When it expires
+// lib/pq cancels the running statement on the server and the call returns
+// context.DeadlineExceeded.
+func (r *chatRepository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.options.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, r.options.QueryTimeout)
+}
 
-	_, err := r.db.Exec(query)
+// EnsureSearchIndex creates the full-text index when FullTextSearch is
+// enabled. It depends on configuration, so it isn't part of the migrations.
+func (r *chatRepository) EnsureSearchIndex() error {
+	if !r.options.FullTextSearch {
+		return nil
+	}
+	_, err := r.db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_content_fts ON messages USING GIN (to_tsvector('simple', content))`)
 	return err
 }
 
+// CheckPairNormalization counts direct chats whose pair is also stored
+// reversed, and reports whether the normalized pair index is in place. Such
+// duplicates predate pair normalization and stop its migration until merged.
+func (r *chatRepository) CheckPairNormalization(ctx context.Context) (*models.PairNormalizationReport, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	duplicatesQuery := `
+	SELECT COUNT(*)
+	FROM chats a
+	JOIN chats b ON a.user_id1 = b.user_id2 AND a.user_id2 = b.user_id1 AND a.id <> b.id
+	`
+
+	constraintQuery := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM pg_indexes
+		WHERE tablename = 'chats'
+		  AND schemaname = current_schema()
+		  AND indexdef ILIKE 'CREATE UNIQUE INDEX%'
+		  AND indexdef ILIKE '%LEAST%user_id1%user_id2%'
+		  AND indexdef ILIKE '%GREATEST%user_id1%user_id2%'
+	)
+	`
+
+	var report models.PairNormalizationReport
+	if err := r.db.QueryRowContext(ctx, duplicatesQuery).Scan(&report.ReversedDuplicates); err != nil {
+		return nil, err
+	}
+	if err := r.db.QueryRowContext(ctx, constraintQuery).Scan(&report.NormalizedConstraint); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
 func (r *chatRepository) CreateChat(ctx context.Context, chat *models.Chat) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-	INSERT INTO chats (id, user_id1, user_id2, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO chats (id, user_id1, user_id2, created_by, source, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
 	ON CONFLICT (user_id1, user_id2) DO UPDATE SET updated_at = NOW()
-	RETURNING id, created_at, updated_at
+	RETURNING id, created_at, updated_at, (xmax = 0) AS inserted
 	`
 
+	source := sql.NullString{String: chat.Source, Valid: chat.Source != ""}
+
+	// Pairs are stored smaller ID first, matching the normalized unique
+	// index, so the reversed pair conflicts with the stored one.
+	userID1, userID2 := chat.UserID1, chat.UserID2
+	if userID2 < userID1 {
+		userID1, userID2 = userID2, userID1
+	}
+
 	var id string
 	var createdAt, updatedAt time.Time
-	err := r.db.QueryRowContext(ctx, query,
-		chat.ID, chat.UserID1, chat.UserID2, chat.CreatedAt, chat.UpdatedAt,
-	).Scan(&id, &createdAt, &updatedAt)
+	var inserted bool
+	err = tx.QueryRowContext(ctx, query,
+		chat.ID, userID1, userID2, chat.CreatedBy, source, chat.CreatedAt, chat.UpdatedAt,
+	).Scan(&id, &createdAt, &updatedAt, &inserted)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -82,66 +251,248 @@ func (r *chatRepository) CreateChat(ctx context.Context, chat *models.Chat) erro
 		return err
 	}
 
+	if inserted {
+		if err := r.addParticipants(ctx, tx, id, chat.CreatedBy, []string{userID1, userID2}); err != nil {
+			return err
+		}
+
+		err = r.writeAudit(ctx, tx, &models.AuditEntry{
+			ActorID: chat.CreatedBy,
+			Action:  models.AuditActionCreateChat,
+			ChatID:  id,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	chat.ID = id
+	chat.UserID1, chat.UserID2 = userID1, userID2
 	chat.CreatedAt = createdAt
 	chat.UpdatedAt = updatedAt
+	chat.Type = models.ChatTypeDirect
+	chat.ParticipantIDs = []string{userID1, userID2}
 	return nil
 }
 
-func (r *chatRepository) GetChatByID(ctx context.Context, id string) (*models.Chat, error) {
+func (r *chatRepository) CreateGroupChat(ctx context.Context, chat *models.Chat) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-	SELECT id, user_id1, user_id2, created_at, updated_at
-	FROM chats
-	WHERE id = $1
+	INSERT INTO chats (id, created_by, name, type, source, created_at, updated_at)
+	VALUES ($1, $2, $3, 'group', $4, NOW(), NOW())
+	RETURNING created_at, updated_at
 	`
 
-	var chat models.Chat
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&chat.ID, &chat.UserID1, &chat.UserID2, &chat.CreatedAt, &chat.UpdatedAt,
-	)
+	source := sql.NullString{String: chat.Source, Valid: chat.Source != ""}
+
+	err = tx.QueryRowContext(ctx, query,
+		chat.ID, chat.CreatedBy, chat.Name, source,
+	).Scan(&chat.CreatedAt, &chat.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := r.addParticipants(ctx, tx, chat.ID, chat.CreatedBy, chat.ParticipantIDs); err != nil {
+		return err
+	}
 
+	err = r.writeAudit(ctx, tx, &models.AuditEntry{
+		ActorID: chat.CreatedBy,
+		Action:  models.AuditActionCreateChat,
+		ChatID:  chat.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	chat.Type = models.ChatTypeGroup
+	return tx.Commit()
+}
+
+// addParticipants adds userIDs to the chat and audits each one that wasn't
+// already a participant as added by actorID.
+func (r *chatRepository) addParticipants(ctx context.Context, q queryer, chatID, actorID string, userIDs []string) error {
+	query := `
+	INSERT INTO chat_participants (chat_id, user_id)
+	SELECT $1, unnest($2::uuid[])
+	ON CONFLICT (chat_id, user_id) DO NOTHING
+	RETURNING user_id
+	`
+
+	rows, err := q.QueryContext(ctx, query, chatID, pq.Array(userIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var added []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return err
+		}
+		added = append(added, userID)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// The connection is busy until the result set is closed.
+	rows.Close()
+
+	for _, userID := range added {
+		err := r.writeAudit(ctx, q, &models.AuditEntry{
+			ActorID:  actorID,
+			Action:   models.AuditActionAddParticipant,
+			ChatID:   chatID,
+			TargetID: userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *chatRepository) GetChatByID(ctx context.Context, id string) (*models.Chat, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT ` + chatColumns + `
+	FROM chats c
+	WHERE c.id = $1
+	`
+
+	chat, err := scanChat(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("chat not found")
+			return nil, ErrChatNotFound
 		}
 		return nil, err
 	}
 
-	return &chat, nil
+	return chat, nil
+}
+
+// GetChatsByIDs returns the chats with the given IDs that userID takes part
+// in, in the order requested; other IDs are left out.
+func (r *chatRepository) GetChatsByIDs(ctx context.Context, userID string, ids []string) ([]*models.Chat, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+	SELECT ` + chatColumns + `
+	FROM unnest($1::uuid[]) WITH ORDINALITY AS req(id, ord)
+	JOIN chats c ON c.id = req.id
+	JOIN chat_participants cp ON cp.chat_id = c.id AND cp.user_id = $2
+	ORDER BY req.ord
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids), userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []*models.Chat
+	for rows.Next() {
+		chat, err := scanChat(rows)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	return chats, rows.Err()
 }
 
 func (r *chatRepository) GetChatByUsers(ctx context.Context, userID1, userID2 string) (*models.Chat, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-	SELECT id, user_id1, user_id2, created_at, updated_at
-	FROM chats
-	WHERE (user_id1 = $1 AND user_id2 = $2) OR (user_id1 = $2 AND user_id2 = $1)
+	SELECT ` + chatColumns + `
+	FROM chats c
+	WHERE (c.user_id1 = $1 AND c.user_id2 = $2) OR (c.user_id1 = $2 AND c.user_id2 = $1)
 	LIMIT 1
 	`
 
-	var chat models.Chat
-	err := r.db.QueryRowContext(ctx, query, userID1, userID2).Scan(
-		&chat.ID, &chat.UserID1, &chat.UserID2, &chat.CreatedAt, &chat.UpdatedAt,
-	)
-
+	chat, err := scanChat(r.db.QueryRowContext(ctx, query, userID1, userID2))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("chat not found")
+			return nil, ErrChatNotFound
 		}
 		return nil, err
 	}
 
-	return &chat, nil
+	return chat, nil
 }
 
-func (r *chatRepository) GetUserChats(ctx context.Context, userID string) ([]*models.Chat, error) {
+func (r *chatRepository) GetUserChats(ctx context.Context, userID string, filter UserChatsFilter) ([]*models.Chat, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-	SELECT id, user_id1, user_id2, created_at, updated_at
-	FROM chats
-	WHERE user_id1 = $1 OR user_id2 = $1
-	ORDER BY updated_at DESC
+	SELECT ` + chatColumns + `,
+		GREATEST(c.created_at, lm.created_at) AS last_activity_at,
+		lm.id IS NOT NULL AS has_messages,
+		(
+			SELECT COUNT(*)
+			FROM messages um
+			WHERE um.chat_id = c.id AND um.sender_id != $1 AND um.deleted_at IS NULL
+				AND NOT EXISTS (
+					SELECT 1 FROM message_reads mr WHERE mr.message_id = um.id AND mr.user_id = $1
+				)
+		) AS unread_count,
+		lm.id, lm.sender_id, lm.content, lm.created_at,
+		COALESCE(` + mutedCondition + `, FALSE) AS muted, s.muted_until,
+		COALESCE(s.archived, FALSE) AS archived
+	FROM chats c
+	JOIN chat_participants cp ON cp.chat_id = c.id AND cp.user_id = $1
+	LEFT JOIN chat_settings s ON s.chat_id = c.id AND s.user_id = $1
+	LEFT JOIN LATERAL (
+		SELECT id, sender_id, content, created_at
+		FROM messages
+		WHERE chat_id = c.id AND deleted_at IS NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	) lm ON TRUE
+	WHERE ($2 OR lm.id IS NOT NULL)
+		AND (cp.hidden_at IS NULL OR lm.created_at > cp.hidden_at)
+		AND NOT ($4 AND c.type = 'direct' AND EXISTS (
+			SELECT 1
+			FROM chat_participants bp
+			JOIN blocks b ON b.blocker_id = $1 AND b.blocked_id = bp.user_id
+			WHERE bp.chat_id = c.id
+		))
+		AND ($5 = 'include' OR COALESCE(s.archived, FALSE) = ($5 = 'only'))
+	ORDER BY COALESCE(lm.created_at, c.created_at) DESC, c.id DESC
+	LIMIT NULLIF($3, 0)
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+	archived := filter.Archived
+	if archived == "" {
+		archived = models.ArchiveFilterExclude
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, userID, filter.IncludeEmpty, filter.Limit, filter.ExcludeBlocked, string(archived))
 	if err != nil {
 		return nil, err
 	}
@@ -149,20 +500,76 @@ func (r *chatRepository) GetUserChats(ctx context.Context, userID string) ([]*mo
 
 	var chats []*models.Chat
 	for rows.Next() {
-		var chat models.Chat
-		err := rows.Scan(
-			&chat.ID, &chat.UserID1, &chat.UserID2, &chat.CreatedAt, &chat.UpdatedAt,
+		var lastActivityAt time.Time
+		var hasMessages bool
+		var unreadCount int
+		var lastID, lastSenderID, lastContent sql.NullString
+		var muted, archived bool
+		var lastCreatedAt, mutedUntil sql.NullTime
+		chat, err := scanChat(rows,
+			&lastActivityAt, &hasMessages, &unreadCount,
+			&lastID, &lastSenderID, &lastContent, &lastCreatedAt,
+			&muted, &mutedUntil, &archived,
 		)
 		if err != nil {
 			return nil, err
 		}
-		chats = append(chats, &chat)
+		chat.LastActivityAt = lastActivityAt
+		chat.HasMessages = hasMessages
+		chat.UnreadCount = unreadCount
+		chat.Muted = muted
+		chat.Archived = archived
+		if muted && mutedUntil.Valid {
+			chat.MutedUntil = &mutedUntil.Time
+		}
+		if lastID.Valid {
+			chat.LastMessage = &models.Message{
+				ID:        lastID.String,
+				ChatID:    chat.ID,
+				SenderID:  lastSenderID.String,
+				Content:   lastContent.String,
+				CreatedAt: lastCreatedAt.Time,
+			}
+		}
+		chats = append(chats, chat)
+	}
+
+	return chats, rows.Err()
+}
+
+func (r *chatRepository) GetChatsWithPeers(ctx context.Context, userID string, peerIDs []string) ([]*models.Chat, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT ` + chatColumns + `
+	FROM chats c
+	WHERE (c.user_id1 = $1 AND c.user_id2 = ANY($2::uuid[]))
+	   OR (c.user_id2 = $1 AND c.user_id1 = ANY($2::uuid[]))
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, pq.Array(peerIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []*models.Chat
+	for rows.Next() {
+		chat, err := scanChat(rows)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
 	}
 
 	return chats, rows.Err()
 }
 
 func (r *chatRepository) UpdateChat(ctx context.Context, chat *models.Chat) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 	UPDATE chats
 	SET updated_at = NOW()
@@ -180,60 +587,444 @@ func (r *chatRepository) UpdateChat(ctx context.Context, chat *models.Chat) erro
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("chat not found")
+		return ErrChatNotFound
 	}
 
 	return nil
 }
 
-func (r *chatRepository) CreateMessage(ctx context.Context, msg *models.Message) error {
+func (r *chatRepository) GetChatParticipants(ctx context.Context, chatID string) ([]*models.Participant, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-	INSERT INTO messages (id, chat_id, sender_id, content, created_at)
-	VALUES ($1, $2, $3, $4, $5)
+	SELECT user_id, joined_at
+	FROM chat_participants
+	WHERE chat_id = $1
+	ORDER BY joined_at, user_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []*models.Participant
+	for rows.Next() {
+		var p models.Participant
+		if err := rows.Scan(&p.UserID, &p.JoinedAt); err != nil {
+			return nil, err
+		}
+		participants = append(participants, &p)
+	}
+
+	return participants, rows.Err()
+}
+
+// DeleteChat removes the chat; messages, participants and reactions go with
+// it through ON DELETE CASCADE.
+func (r *chatRepository) DeleteChat(ctx context.Context, chatID, actorID string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM chats WHERE id = $1`, chatID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrChatNotFound
+	}
+
+	if err := r.writeAudit(ctx, tx, &models.AuditEntry{
+		ActorID: actorID,
+		Action:  models.AuditActionDeleteChat,
+		ChatID:  chatID,
+	}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// HideChat hides the chat from userID's chat list until a newer message
+// arrives. Other participants are unaffected.
+func (r *chatRepository) HideChat(ctx context.Context, chatID, userID string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	UPDATE chat_participants
+	SET hidden_at = NOW()
+	WHERE chat_id = $1 AND user_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrChatNotFound
+	}
+
+	return nil
+}
+
+// CreateMessage stores msg. With maxMessages set, a chat already holding that
+// many messages rejects it with ErrChatQuotaExceeded.
+func (r *chatRepository) CreateMessage(ctx context.Context, msg *models.Message, maxMessages int64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.insertMessage(ctx, tx, msg, maxMessages); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *chatRepository) CreateMessageMarkingRead(ctx context.Context, msg *models.Message, maxMessages int64) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	count, err := r.markMessagesAsRead(ctx, tx, msg.ChatID, msg.SenderID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.insertMessage(ctx, tx, msg, maxMessages); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *chatRepository) insertMessage(ctx context.Context, q queryer, msg *models.Message, maxMessages int64) error {
+	query := `
+	INSERT INTO messages (id, chat_id, sender_id, content, content_hash, language, created_at, reply_to_message_id, forwarded_from_message_id, announcement)
+	VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, NOW()), $8, $9, $10)
 	RETURNING id, created_at
 	`
 
+	contentHash := sql.NullString{String: msg.ContentHash, Valid: msg.ContentHash != ""}
+	language := sql.NullString{String: msg.Language, Valid: msg.Language != ""}
+	createdAt := sql.NullTime{Time: msg.CreatedAt, Valid: !msg.CreatedAt.IsZero()}
+	replyTo := sql.NullString{String: msg.ReplyToID, Valid: msg.ReplyToID != ""}
+	forwardedFrom := sql.NullString{String: msg.ForwardedFromID, Valid: msg.ForwardedFromID != ""}
+
 	var id string
-	var createdAt time.Time
-	err := r.db.QueryRowContext(ctx, query,
-		msg.ID, msg.ChatID, msg.SenderID, msg.Content, msg.CreatedAt,
-	).Scan(&id, &createdAt)
+	err := q.QueryRowContext(ctx, query,
+		msg.ID, msg.ChatID, msg.SenderID, msg.Content, contentHash, language, createdAt, replyTo, forwardedFrom, msg.Announcement,
+	).Scan(&id, &createdAt.Time)
 
 	if err != nil {
+		if isUniqueViolation(err, "messages_pkey") {
+			return ErrDuplicateMessageID
+		}
 		return err
 	}
 
 	msg.ID = id
-	msg.CreatedAt = createdAt
+	msg.CreatedAt = createdAt.Time
+
+	if err := insertAttachments(ctx, q, msg); err != nil {
+		return err
+	}
+
+	// The quota is checked under the chat's row lock, so concurrent sends
+	// can't push the count past it.
+	updateChatQuery := `
+	UPDATE chats
+	SET updated_at = $3, message_count = message_count + 1, bytes_used = bytes_used + $2
+	WHERE id = $1 AND ($4 = 0 OR message_count < $4)
+	`
+
+	result, err := q.ExecContext(ctx, updateChatQuery, msg.ChatID, messageBytes(msg), msg.CreatedAt, maxMessages)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		if maxMessages > 0 {
+			return ErrChatQuotaExceeded
+		}
+		return ErrChatNotFound
+	}
+
+	// A new message brings an archived chat back for its recipients.
+	unarchiveQuery := `
+	UPDATE chat_settings
+	SET archived = FALSE, updated_at = NOW()
+	WHERE chat_id = $1 AND user_id <> $2 AND archived
+	`
+
+	if _, err := q.ExecContext(ctx, unarchiveQuery, msg.ChatID, msg.SenderID); err != nil {
+		return err
+	}
+
+	return r.writeAudit(ctx, q, &models.AuditEntry{
+		ActorID:  msg.SenderID,
+		Action:   models.AuditActionSendMessage,
+		ChatID:   msg.ChatID,
+		TargetID: msg.ID,
+	})
+}
+
+func (r *chatRepository) GetMessageByID(ctx context.Context, id string) (*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE id = $1
+	`
+
+	msg, err := scanMessage(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	return msg, nil
+}
 
-	updateChatQuery := `UPDATE chats SET updated_at = NOW() WHERE id = $1`
-	r.db.ExecContext(ctx, updateChatQuery, msg.ChatID)
+// UpdateMessageContent replaces the message's content and keeps the previous
+// version in message_edits, with msg.SenderID as the editor.
+func (r *chatRepository) UpdateMessageContent(ctx context.Context, msg *models.Message) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	var oldContent string
+	err = tx.QueryRowContext(ctx,
+		`SELECT content FROM messages WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, msg.ID,
+	).Scan(&oldContent)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrMessageNotFound
+		}
+		return err
+	}
+
+	query := `
+	UPDATE messages
+	SET content = $2, content_hash = $3, language = $4, edited_at = NOW()
+	WHERE id = $1
+	RETURNING edited_at
+	`
+
+	contentHash := sql.NullString{String: msg.ContentHash, Valid: msg.ContentHash != ""}
+	language := sql.NullString{String: msg.Language, Valid: msg.Language != ""}
+
+	var editedAt time.Time
+	err = tx.QueryRowContext(ctx, query, msg.ID, msg.Content, contentHash, language).Scan(&editedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO message_edits (message_id, old_content, editor_id, edited_at) VALUES ($1, $2, $3, $4)`,
+		msg.ID, oldContent, msg.SenderID, editedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE chats SET bytes_used = bytes_used + $2 WHERE id = $1`,
+		msg.ChatID, len(msg.Content)-len(oldContent),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = r.writeAudit(ctx, tx, &models.AuditEntry{
+		ActorID:  msg.SenderID,
+		Action:   models.AuditActionEditMessage,
+		ChatID:   msg.ChatID,
+		TargetID: msg.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	msg.EditedAt = &editedAt
 	return nil
 }
 
-func (r *chatRepository) GetChatMessages(ctx context.Context, chatID string, limit int, beforeMessageID string) ([]*models.Message, error) {
+// SoftDeleteMessage marks the message deleted and returns when it was. With
+// clearReactions its reactions are removed in the same transaction and
+// returned.
+func (r *chatRepository) SoftDeleteMessage(ctx context.Context, id string, clearReactions bool) (time.Time, []*models.Reaction, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE messages
+	SET deleted_at = NOW()
+	WHERE id = $1 AND deleted_at IS NULL
+	RETURNING chat_id, sender_id, deleted_at, octet_length(content) + COALESCE(
+		(SELECT SUM(size_bytes) FROM attachments WHERE message_id = messages.id), 0
+	)
+	`
+
+	var chatID, senderID string
+	var deletedAt time.Time
+	var bytes int64
+	err = tx.QueryRowContext(ctx, query, id).Scan(&chatID, &senderID, &deletedAt, &bytes)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil, ErrMessageNotFound
+		}
+		return time.Time{}, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE chats SET message_count = message_count - 1, bytes_used = bytes_used - $2 WHERE id = $1`,
+		chatID, bytes,
+	)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	var cleared []*models.Reaction
+	if clearReactions {
+		cleared, err = deleteReactions(ctx, tx, id)
+		if err != nil {
+			return time.Time{}, nil, err
+		}
+	}
+
+	err = r.writeAudit(ctx, tx, &models.AuditEntry{
+		ActorID:  senderID,
+		Action:   models.AuditActionDeleteMessage,
+		ChatID:   chatID,
+		TargetID: id,
+	})
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, nil, err
+	}
+
+	return deletedAt, cleared, nil
+}
+
+func deleteReactions(ctx context.Context, q queryer, messageID string) ([]*models.Reaction, error) {
+	rows, err := q.QueryContext(ctx,
+		`DELETE FROM message_reactions WHERE message_id = $1 RETURNING user_id, emoji`, messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactions []*models.Reaction
+	for rows.Next() {
+		var reaction models.Reaction
+		if err := rows.Scan(&reaction.UserID, &reaction.Emoji); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, &reaction)
+	}
+
+	return reactions, rows.Err()
+}
+
+// GetChatMessages returns, oldest first, up to limit messages before cursor,
+// or after it if cursor.After is set, nearest the cursor. Without a cursor
+// it returns the newest messages.
+func (r *chatRepository) GetChatMessages(ctx context.Context, chatID string, limit int, cursor *models.MessageCursor, includeDeleted bool) ([]*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	var query string
 	var args []interface{}
 
-	if beforeMessageID != "" {
+	switch {
+	case cursor != nil && cursor.After:
 		query = `
-		SELECT id, chat_id, sender_id, content, created_at, read_at
+		SELECT ` + messageColumns + `
 		FROM messages
-		WHERE chat_id = $1 AND id < $2
-		ORDER BY created_at DESC
-		LIMIT $3
+		WHERE chat_id = $1 AND (created_at, id) > ($2, $3) AND ($5 OR deleted_at IS NULL)
+		ORDER BY created_at, id
+		LIMIT $4
 		`
-		args = []interface{}{chatID, beforeMessageID, limit}
-	} else {
+		args = []interface{}{chatID, cursor.CreatedAt, cursor.ID, limit, includeDeleted}
+	case cursor != nil:
 		query = `
-		SELECT id, chat_id, sender_id, content, created_at, read_at
+		SELECT ` + messageColumns + `
 		FROM messages
-		WHERE chat_id = $1
-		ORDER BY created_at DESC
+		WHERE chat_id = $1 AND (created_at, id) < ($2, $3) AND ($5 OR deleted_at IS NULL)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4
+		`
+		args = []interface{}{chatID, cursor.CreatedAt, cursor.ID, limit, includeDeleted}
+	default:
+		query = `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE chat_id = $1 AND ($3 OR deleted_at IS NULL)
+		ORDER BY created_at DESC, id DESC
 		LIMIT $2
 		`
-		args = []interface{}{chatID, limit}
+		args = []interface{}{chatID, limit, includeDeleted}
 	}
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
@@ -244,46 +1035,267 @@ func (r *chatRepository) GetChatMessages(ctx context.Context, chatID string, lim
 
 	var messages []*models.Message
 	for rows.Next() {
-		var msg models.Message
-		var readAt sql.NullTime
-		err := rows.Scan(
-			&msg.ID, &msg.ChatID, &msg.SenderID, &msg.Content, &msg.CreatedAt, &readAt,
-		)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
-		if readAt.Valid {
-			msg.ReadAt = &readAt.Time
-		}
-		messages = append(messages, &msg)
+		messages = append(messages, msg)
 	}
 
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	if cursor == nil || !cursor.After {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
 	}
 
 	return messages, rows.Err()
 }
 
 func (r *chatRepository) MarkMessagesAsRead(ctx context.Context, chatID, userID string) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	return r.markMessagesAsRead(ctx, r.db, chatID, userID)
+}
+
+// markMessagesAsRead records a read receipt for userID on every message in the
+// chat they haven't read yet and returns how many were newly marked. The
+// message-level read_at keeps the time of the first read by anyone, and a
+// read message always counts as delivered.
+func (r *chatRepository) markMessagesAsRead(ctx context.Context, q queryer, chatID, userID string) (int, error) {
+	query := `
+	WITH new_reads AS (
+		INSERT INTO message_reads (message_id, user_id)
+		SELECT m.id, $2
+		FROM messages m
+		WHERE m.chat_id = $1 AND m.sender_id != $2
+			AND NOT EXISTS (
+				SELECT 1 FROM message_reads mr WHERE mr.message_id = m.id AND mr.user_id = $2
+			)
+		ON CONFLICT (message_id, user_id) DO NOTHING
+		RETURNING message_id
+	), first_reads AS (
+		UPDATE messages
+		SET read_at = NOW(), delivered_at = COALESCE(delivered_at, NOW())
+		WHERE id IN (SELECT message_id FROM new_reads) AND read_at IS NULL
+	)
+	SELECT COUNT(*) FROM new_reads
+	`
+
+	var count int
+	if err := q.QueryRowContext(ctx, query, chatID, userID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkMessagesAsDelivered stamps delivered_at on the messages in the chat
+// that userID didn't send and that haven't been delivered yet, returning how
+// many were marked. Like read_at it records the first delivery to anyone, and
+// it is never moved once set.
+func (r *chatRepository) MarkMessagesAsDelivered(ctx context.Context, chatID, userID string) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 	UPDATE messages
-	SET read_at = NOW()
-	WHERE chat_id = $1 AND sender_id != $2 AND read_at IS NULL
-	RETURNING id
+	SET delivered_at = NOW()
+	WHERE chat_id = $1 AND sender_id != $2 AND delivered_at IS NULL AND deleted_at IS NULL
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, chatID, userID)
+	result, err := r.db.ExecContext(ctx, query, chatID, userID)
 	if err != nil {
 		return 0, err
 	}
+
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+func (r *chatRepository) GetMessageReaders(ctx context.Context, messageID string) ([]*models.MessageRead, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT user_id, read_at
+	FROM message_reads
+	WHERE message_id = $1
+	ORDER BY read_at, user_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readers []*models.MessageRead
+	for rows.Next() {
+		var read models.MessageRead
+		if err := rows.Scan(&read.UserID, &read.ReadAt); err != nil {
+			return nil, err
+		}
+		readers = append(readers, &read)
+	}
+
+	return readers, rows.Err()
+}
+
+// GetLastRead returns the newest message in the chat that userID has a read
+// receipt for, or nil if they haven't read anything yet.
+func (r *chatRepository) GetLastRead(ctx context.Context, chatID, userID string) (*models.ReadPointer, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT m.id, m.created_at, mr.read_at
+	FROM message_reads mr
+	JOIN messages m ON m.id = mr.message_id
+	WHERE m.chat_id = $1 AND mr.user_id = $2
+	ORDER BY m.created_at DESC, m.id DESC
+	LIMIT 1
+	`
+
+	var pointer models.ReadPointer
+	err := r.db.QueryRowContext(ctx, query, chatID, userID).Scan(
+		&pointer.MessageID, &pointer.MessageCreatedAt, &pointer.ReadAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &pointer, nil
+}
+
+func (r *chatRepository) GetFirstUnreadMessage(ctx context.Context, chatID, userID string) (*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT ` + prefixedMessageColumns + `
+	FROM messages m
+	WHERE m.chat_id = $1 AND m.sender_id != $2 AND m.deleted_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM message_reads mr WHERE mr.message_id = m.id AND mr.user_id = $2
+		)
+	ORDER BY m.created_at ASC, m.id ASC
+	LIMIT 1
+	`
+
+	msg, err := scanMessage(r.db.QueryRowContext(ctx, query, chatID, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// GetMessageHistogram counts non-deleted messages in [from, to) per
+// date_trunc bucket, with bucket boundaries in UTC. With fillGaps every bucket
+// in the range is returned, including those without messages.
+func (r *chatRepository) GetMessageHistogram(ctx context.Context, chatID, bucket string, from, to time.Time, fillGaps bool) ([]*models.HistogramBucket, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	WITH counts AS (
+		SELECT date_trunc($2, created_at) AS bucket, COUNT(*) AS count
+		FROM messages
+		WHERE chat_id = $1 AND created_at >= $3 AND created_at < $4 AND deleted_at IS NULL
+		GROUP BY 1
+	)
+	`
+	if fillGaps {
+		query += `
+	SELECT s.bucket, COALESCE(c.count, 0)
+	FROM generate_series(
+		date_trunc($2, $3::timestamptz),
+		$4::timestamptz - INTERVAL '1 microsecond',
+		('1 ' || $2)::interval
+	) AS s(bucket)
+	LEFT JOIN counts c ON c.bucket = s.bucket
+	ORDER BY s.bucket
+	`
+	} else {
+		query += `
+	SELECT bucket, count FROM counts ORDER BY bucket
+	`
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, bucket, from, to)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	count := 0
+	var buckets []*models.HistogramBucket
 	for rows.Next() {
-		count++
+		var b models.HistogramBucket
+		if err := rows.Scan(&b.Start, &b.Count); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, &b)
 	}
 
-	return count, rows.Err()
+	return buckets, rows.Err()
 }
 
+func (r *chatRepository) GetTopDuplicatedContent(ctx context.Context, limit int) ([]*models.DuplicateContent, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT content_hash, COUNT(*) AS occurrences, MIN(content)
+	FROM messages
+	WHERE content_hash IS NOT NULL
+	GROUP BY content_hash
+	HAVING COUNT(*) > 1
+	ORDER BY occurrences DESC, content_hash
+	LIMIT $1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var duplicates []*models.DuplicateContent
+	for rows.Next() {
+		var dup models.DuplicateContent
+		if err := rows.Scan(&dup.ContentHash, &dup.Occurrences, &dup.SampleContent); err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, &dup)
+	}
+
+	return duplicates, rows.Err()
+}
+
+func (r *chatRepository) GetContentSpread(ctx context.Context, contentHash string) (*models.ContentSpread, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT COUNT(*), COUNT(DISTINCT chat_id), COUNT(DISTINCT sender_id)
+	FROM messages
+	WHERE content_hash = $1
+	`
+
+	spread := models.ContentSpread{ContentHash: contentHash}
+	err := r.db.QueryRowContext(ctx, query, contentHash).Scan(
+		&spread.Occurrences, &spread.ChatCount, &spread.SenderCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spread, nil
+}
@@ -0,0 +1,833 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+// newTestDB opens the database named by TEST_DATABASE_URL with its search
+// path set to a throwaway schema, dropped when the test ends. Tests using it
+// are skipped when the variable is unset.
+func newTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	schema := "chat_service_test_" + uuid.NewString()[:8]
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("parse TEST_DATABASE_URL: %v", err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	q.Set("timezone", "UTC")
+	u.RawQuery = q.Encode()
+
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE`, pq.QuoteIdentifier(schema)))
+		db.Close()
+	})
+
+	return db, schema
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, schema string) *goose.Provider {
+	t.Helper()
+
+	migrator, err := NewMigrator(context.Background(), db, schema)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	return migrator
+}
+
+// newTestRepository migrates a throwaway schema and returns a repository over
+// it, with opts.Schema filled in.
+func newTestRepository(t *testing.T, opts Options) ChatRepository {
+	t.Helper()
+
+	db, schema := newTestDB(t)
+	if _, err := newTestMigrator(t, db, schema).Up(context.Background()); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+
+	opts.Schema = schema
+	return NewChatRepository(db, opts)
+}
+
+func TestGetChatMessagesOrdersTiesByID(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+
+	users := []string{uuid.NewString(), uuid.NewString()}
+	slices.Sort(users)
+	now := time.Now().UTC()
+	chat := &models.Chat{
+		ID:        uuid.NewString(),
+		UserID1:   users[0],
+		UserID2:   users[1],
+		CreatedBy: users[0],
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.CreateChat(ctx, chat); err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+
+	// Every message shares one created_at, so only the id tiebreaker orders
+	// them.
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var want []string
+	for i := 0; i < 7; i++ {
+		msg := &models.Message{
+			ID:        uuid.NewString(),
+			ChatID:    chat.ID,
+			SenderID:  users[0],
+			Content:   fmt.Sprintf("message %d", i),
+			CreatedAt: createdAt,
+		}
+		if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+		want = append(want, msg.ID)
+	}
+	// Postgres orders UUIDs bytewise, the same as their lowercase hex form.
+	slices.Sort(want)
+	slices.Reverse(want)
+
+	tests := []struct {
+		name     string
+		pageSize int
+	}{
+		{"one per page", 1},
+		{"uneven pages", 3},
+		{"exact fit", 7},
+		{"single oversized page", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Repeated reads must agree with each other, not just once.
+			for read := 0; read < 3; read++ {
+				if got := readAllPages(t, repo, chat.ID, tt.pageSize); !slices.Equal(got, want) {
+					t.Fatalf("read %d: got %v, want %v", read, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetChatMessagesPagesForwardAfterCursor(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, first := newAuditedChat(t, repo)
+
+	want := []string{first.ID}
+	for i := 1; i < 5; i++ {
+		msg := &models.Message{
+			ID:        uuid.NewString(),
+			ChatID:    chat.ID,
+			SenderID:  chat.UserID1,
+			Content:   fmt.Sprintf("message %d", i),
+			CreatedAt: first.CreatedAt.Add(time.Duration(i) * time.Second),
+		}
+		if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+		want = append(want, msg.ID)
+	}
+
+	cursor := &models.MessageCursor{CreatedAt: first.CreatedAt, ID: first.ID, After: true}
+	page, err := repo.GetChatMessages(ctx, chat.ID, 2, cursor, false)
+	if err != nil {
+		t.Fatalf("GetChatMessages() error = %v", err)
+	}
+	var got []string
+	for _, msg := range page {
+		got = append(got, msg.ID)
+	}
+	// The two messages nearest the cursor, oldest first.
+	if !slices.Equal(got, want[1:3]) {
+		t.Errorf("page after the first message = %v, want %v", got, want[1:3])
+	}
+}
+
+func TestCreateMessageReportsDuplicateID(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	chat, first := newAuditedChat(t, repo)
+
+	msg := &models.Message{ID: first.ID, ChatID: chat.ID, SenderID: chat.UserID2, Content: "again"}
+	if err := repo.CreateMessage(context.Background(), msg, 0); !errors.Is(err, ErrDuplicateMessageID) {
+		t.Fatalf("CreateMessage() with a stored ID error = %v, want ErrDuplicateMessageID", err)
+	}
+}
+
+func TestCreateMessageMarkingReadIsAtomic(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, first := newAuditedChat(t, repo)
+	replier := chat.UserID2
+
+	// The chat already holds its one allowed message, so the insert fails
+	// after the earlier message has been marked read.
+	reply := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: replier, Content: "reply"}
+	if _, err := repo.CreateMessageMarkingRead(ctx, reply, 1); !errors.Is(err, ErrChatQuotaExceeded) {
+		t.Fatalf("CreateMessageMarkingRead() over the quota error = %v, want ErrChatQuotaExceeded", err)
+	}
+	unread, err := repo.GetFirstUnreadMessage(ctx, chat.ID, replier)
+	if err != nil {
+		t.Fatalf("GetFirstUnreadMessage() error = %v", err)
+	}
+	if unread == nil || unread.ID != first.ID {
+		t.Fatalf("first unread after the failed send = %v, want %s still unread", unread, first.ID)
+	}
+
+	count, err := repo.CreateMessageMarkingRead(ctx, reply, 0)
+	if err != nil {
+		t.Fatalf("CreateMessageMarkingRead() error = %v", err)
+	}
+	if count != 1 {
+		t.Errorf("marked %d messages read, want 1", count)
+	}
+	if unread, err := repo.GetFirstUnreadMessage(ctx, chat.ID, replier); err != nil || unread != nil {
+		t.Errorf("first unread after the send = %v, %v; want none", unread, err)
+	}
+	if _, err := repo.GetMessageByID(ctx, reply.ID); err != nil {
+		t.Errorf("GetMessageByID() for the reply error = %v", err)
+	}
+}
+
+func TestGetMessageHistogramAcrossDayBoundary(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, _ := newAuditedChat(t, repo)
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	for _, createdAt := range []time.Time{at(1, 22, 30), at(1, 23, 10), at(2, 0, 5), at(2, 2, 40)} {
+		msg := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: chat.UserID1, Content: "hi", CreatedAt: createdAt}
+		if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+	}
+
+	type bucket struct {
+		start time.Time
+		count int64
+	}
+	tests := []struct {
+		name     string
+		bucket   string
+		fillGaps bool
+		want     []bucket
+	}{
+		{"hours with gaps", "hour", true, []bucket{
+			{at(1, 22, 0), 1}, {at(1, 23, 0), 1}, {at(2, 0, 0), 1}, {at(2, 1, 0), 0}, {at(2, 2, 0), 1},
+		}},
+		{"hours without gaps", "hour", false, []bucket{
+			{at(1, 22, 0), 1}, {at(1, 23, 0), 1}, {at(2, 0, 0), 1}, {at(2, 2, 0), 1},
+		}},
+		{"days", "day", true, []bucket{{at(1, 0, 0), 2}, {at(2, 0, 0), 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := at(1, 22, 0), at(2, 3, 0)
+			if tt.bucket == "day" {
+				from, to = at(1, 0, 0), at(3, 0, 0)
+			}
+			buckets, err := repo.GetMessageHistogram(ctx, chat.ID, tt.bucket, from, to, tt.fillGaps)
+			if err != nil {
+				t.Fatalf("GetMessageHistogram() error = %v", err)
+			}
+			got := make([]bucket, len(buckets))
+			for i, b := range buckets {
+				got[i] = bucket{b.Start.UTC(), b.Count}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("buckets = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateMessageRollsBackWhenChatUpdateFails(t *testing.T) {
+	db, schema := newTestDB(t)
+	ctx := context.Background()
+	if _, err := newTestMigrator(t, db, schema).Up(ctx); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	repo := NewChatRepository(db, Options{Schema: schema})
+	chat, _ := newAuditedChat(t, repo)
+
+	chatState := func() (updatedAt time.Time, count int64) {
+		t.Helper()
+		err := db.QueryRowContext(ctx, `SELECT updated_at, message_count FROM chats WHERE id = $1`, chat.ID).Scan(&updatedAt, &count)
+		if err != nil {
+			t.Fatalf("read chat: %v", err)
+		}
+		return updatedAt, count
+	}
+	beforeUpdatedAt, beforeCount := chatState()
+
+	// Fail the chat update that follows the message insert.
+	_, err := db.ExecContext(ctx, `
+	CREATE FUNCTION fail_chat_update() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'chat update failed';
+	END $$ LANGUAGE plpgsql;
+	CREATE TRIGGER fail_chat_update BEFORE UPDATE ON chats FOR EACH ROW EXECUTE FUNCTION fail_chat_update();
+	`)
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	msg := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: chat.UserID2, Content: "lost", CreatedAt: time.Now().UTC().Add(time.Minute)}
+	if err := repo.CreateMessage(ctx, msg, 0); err == nil {
+		t.Fatal("CreateMessage() succeeded with a failing chat update")
+	}
+	if _, err := repo.GetMessageByID(ctx, msg.ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("GetMessageByID() after the failed send error = %v, want ErrMessageNotFound", err)
+	}
+	if updatedAt, count := chatState(); !updatedAt.Equal(beforeUpdatedAt) || count != beforeCount {
+		t.Errorf("chat after the failed send = %v, %d messages; want %v, %d", updatedAt, count, beforeUpdatedAt, beforeCount)
+	}
+
+	if _, err := db.ExecContext(ctx, `DROP TRIGGER fail_chat_update ON chats`); err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	if updatedAt, count := chatState(); !updatedAt.Equal(msg.CreatedAt) || count != beforeCount+1 {
+		t.Errorf("chat after the send = %v, %d messages; want %v, %d", updatedAt, count, msg.CreatedAt, beforeCount+1)
+	}
+}
+
+func TestMigrationsRollBackAndReapply(t *testing.T) {
+	db, schema := newTestDB(t)
+	migrator := newTestMigrator(t, db, schema)
+	ctx := context.Background()
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	// The initial schema has no down migration, so roll back to it.
+	if _, err := migrator.DownTo(ctx, 1); err != nil {
+		t.Fatalf("roll back migrations: %v", err)
+	}
+	version, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		t.Fatalf("GetDBVersion() error = %v", err)
+	}
+	if version != 1 {
+		t.Fatalf("version after rolling back = %d, want 1", version)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("reapply migrations: %v", err)
+	}
+
+	// The reapplied schema works end to end.
+	newAuditedChat(t, NewChatRepository(db, Options{Schema: schema}))
+}
+
+func TestGetChatsWithPeers(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+
+	user := uuid.NewString()
+	withChat := []string{uuid.NewString(), uuid.NewString()}
+	want := map[string]string{}
+	for _, peer := range withChat {
+		// Pairs are stored normalized, so the user may be either side.
+		pair := []string{user, peer}
+		slices.Sort(pair)
+		chat := &models.Chat{ID: uuid.NewString(), UserID1: pair[0], UserID2: pair[1], CreatedBy: user}
+		if err := repo.CreateChat(ctx, chat); err != nil {
+			t.Fatalf("CreateChat() error = %v", err)
+		}
+		want[peer] = chat.ID
+	}
+	// A chat between two of the peers is not the user's.
+	other := &models.Chat{ID: uuid.NewString(), UserID1: withChat[0], UserID2: withChat[1], CreatedBy: withChat[0]}
+	if err := repo.CreateChat(ctx, other); err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+
+	peers := append([]string{uuid.NewString()}, withChat...)
+	chats, err := repo.GetChatsWithPeers(ctx, user, append(peers, uuid.NewString()))
+	if err != nil {
+		t.Fatalf("GetChatsWithPeers() error = %v", err)
+	}
+	got := map[string]string{}
+	for _, chat := range chats {
+		peer := chat.UserID1
+		if peer == user {
+			peer = chat.UserID2
+		}
+		got[peer] = chat.ID
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("chats by peer = %v, want %v", got, want)
+	}
+}
+
+func TestGetUserChatsLastActivity(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+
+	user := uuid.NewString()
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Microsecond)
+	newChat := func(createdAt time.Time) *models.Chat {
+		t.Helper()
+		pair := []string{user, uuid.NewString()}
+		slices.Sort(pair)
+		chat := &models.Chat{ID: uuid.NewString(), UserID1: pair[0], UserID2: pair[1], CreatedBy: user, CreatedAt: createdAt, UpdatedAt: createdAt}
+		if err := repo.CreateChat(ctx, chat); err != nil {
+			t.Fatalf("CreateChat() error = %v", err)
+		}
+		return chat
+	}
+
+	empty := newChat(base.Add(10 * time.Minute))
+	active := newChat(base)
+	msg := &models.Message{ID: uuid.NewString(), ChatID: active.ID, SenderID: user, Content: "hi", CreatedAt: base.Add(20 * time.Minute)}
+	if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+
+	chats, err := repo.GetUserChats(ctx, user, UserChatsFilter{IncludeEmpty: true})
+	if err != nil {
+		t.Fatalf("GetUserChats() error = %v", err)
+	}
+	if len(chats) != 2 {
+		t.Fatalf("got %d chats, want 2", len(chats))
+	}
+	// The active chat's message is newer than the empty chat.
+	if chats[0].ID != active.ID || !chats[0].HasMessages || !chats[0].LastActivityAt.Equal(msg.CreatedAt) {
+		t.Errorf("first chat = %s, has messages %v, last activity %v; want %s, true, %v",
+			chats[0].ID, chats[0].HasMessages, chats[0].LastActivityAt, active.ID, msg.CreatedAt)
+	}
+	if chats[1].ID != empty.ID || chats[1].HasMessages || !chats[1].LastActivityAt.Equal(empty.CreatedAt) {
+		t.Errorf("second chat = %s, has messages %v, last activity %v; want %s, false, %v",
+			chats[1].ID, chats[1].HasMessages, chats[1].LastActivityAt, empty.ID, empty.CreatedAt)
+	}
+}
+
+func TestGetContentSpread(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+
+	spam := "spam-" + uuid.NewString()
+	var chats []*models.Chat
+	for i := 0; i < 3; i++ {
+		chat, _ := newAuditedChat(t, repo)
+		chats = append(chats, chat)
+	}
+	// The same text lands in three chats, twice from one sender.
+	sends := []struct {
+		chat   *models.Chat
+		sender string
+		hash   string
+	}{
+		{chats[0], chats[0].UserID1, spam},
+		{chats[0], chats[0].UserID1, spam},
+		{chats[1], chats[1].UserID1, spam},
+		{chats[2], chats[2].UserID1, spam},
+		{chats[2], chats[2].UserID2, "other-" + uuid.NewString()},
+	}
+	for _, send := range sends {
+		msg := &models.Message{ID: uuid.NewString(), ChatID: send.chat.ID, SenderID: send.sender, Content: "buy now", ContentHash: send.hash}
+		if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+	}
+
+	spread, err := repo.GetContentSpread(ctx, spam)
+	if err != nil {
+		t.Fatalf("GetContentSpread() error = %v", err)
+	}
+	want := models.ContentSpread{ContentHash: spam, Occurrences: 4, ChatCount: 3, SenderCount: 3}
+	if *spread != want {
+		t.Errorf("GetContentSpread() = %+v, want %+v", *spread, want)
+	}
+
+	spread, err = repo.GetContentSpread(ctx, "unseen")
+	if err != nil {
+		t.Fatalf("GetContentSpread() error = %v", err)
+	}
+	if want := (models.ContentSpread{ContentHash: "unseen"}); *spread != want {
+		t.Errorf("GetContentSpread() for an unseen hash = %+v, want %+v", *spread, want)
+	}
+}
+
+func TestGetFirstUnreadMessage(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, first := newAuditedChat(t, repo)
+	reader := chat.UserID2
+
+	firstUnread := func() *models.Message {
+		t.Helper()
+		msg, err := repo.GetFirstUnreadMessage(ctx, chat.ID, reader)
+		if err != nil {
+			t.Fatalf("GetFirstUnreadMessage() error = %v", err)
+		}
+		return msg
+	}
+	send := func(senderID string, offset time.Duration) *models.Message {
+		t.Helper()
+		msg := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: senderID, Content: "hi", CreatedAt: first.CreatedAt.Add(offset)}
+		if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+		return msg
+	}
+
+	if msg := firstUnread(); msg == nil || msg.ID != first.ID {
+		t.Errorf("first unread in an unread chat = %v, want %s", msg, first.ID)
+	}
+
+	if _, err := repo.MarkMessagesAsRead(ctx, chat.ID, reader); err != nil {
+		t.Fatalf("MarkMessagesAsRead() error = %v", err)
+	}
+	// The reader's own message doesn't count as unread.
+	send(reader, time.Second)
+	if msg := firstUnread(); msg != nil {
+		t.Errorf("first unread in a fully read chat = %s, want none", msg.ID)
+	}
+
+	deleted := send(chat.UserID1, 2*time.Second)
+	if _, _, err := repo.SoftDeleteMessage(ctx, deleted.ID, false); err != nil {
+		t.Fatalf("SoftDeleteMessage() error = %v", err)
+	}
+	anchor := send(chat.UserID1, 3*time.Second)
+	send(chat.UserID1, 4*time.Second)
+	if msg := firstUnread(); msg == nil || msg.ID != anchor.ID {
+		t.Errorf("first unread in a partly read chat = %v, want %s", msg, anchor.ID)
+	}
+
+	empty := &models.Chat{ID: uuid.NewString(), UserID1: chat.UserID1, UserID2: uuid.NewString(), CreatedBy: chat.UserID1}
+	if err := repo.CreateChat(ctx, empty); err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+	if msg, err := repo.GetFirstUnreadMessage(ctx, empty.ID, chat.UserID1); err != nil || msg != nil {
+		t.Errorf("first unread in a chat without messages = %v, %v; want none", msg, err)
+	}
+}
+
+func TestGetLastReadFollowsMarkMessagesAsRead(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, first := newAuditedChat(t, repo)
+	reader := chat.UserID2
+
+	lastRead := func(userID string) *models.ReadPointer {
+		t.Helper()
+		pointer, err := repo.GetLastRead(ctx, chat.ID, userID)
+		if err != nil {
+			t.Fatalf("GetLastRead() error = %v", err)
+		}
+		return pointer
+	}
+	markRead := func() {
+		t.Helper()
+		if _, err := repo.MarkMessagesAsRead(ctx, chat.ID, reader); err != nil {
+			t.Fatalf("MarkMessagesAsRead() error = %v", err)
+		}
+	}
+
+	if pointer := lastRead(reader); pointer != nil {
+		t.Fatalf("GetLastRead() before reading = %+v, want none", pointer)
+	}
+
+	markRead()
+	pointer := lastRead(reader)
+	if pointer == nil || pointer.MessageID != first.ID || pointer.ReadAt.IsZero() {
+		t.Fatalf("GetLastRead() after reading = %+v, want %s", pointer, first.ID)
+	}
+	if other := lastRead(chat.UserID1); other != nil {
+		t.Errorf("sender's GetLastRead() = %+v, want none", other)
+	}
+
+	second := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: chat.UserID1, Content: "hi", CreatedAt: first.CreatedAt.Add(time.Second)}
+	if err := repo.CreateMessage(ctx, second, 0); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	if got := lastRead(reader); got == nil || got.MessageID != first.ID {
+		t.Errorf("GetLastRead() after a new message = %+v, want it still at %s", got, first.ID)
+	}
+	markRead()
+	if got := lastRead(reader); got == nil || got.MessageID != second.ID || !got.MessageCreatedAt.Equal(second.CreatedAt) {
+		t.Errorf("GetLastRead() after reading again = %+v, want %s", got, second.ID)
+	}
+}
+
+func TestMessageCountTracksSendsAndDeletes(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, first := newAuditedChat(t, repo)
+
+	count := func() int64 {
+		t.Helper()
+		stored, err := repo.GetChatByID(ctx, chat.ID)
+		if err != nil {
+			t.Fatalf("GetChatByID() error = %v", err)
+		}
+		return stored.MessageCount
+	}
+
+	for i := 0; i < 2; i++ {
+		msg := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: chat.UserID2, Content: "hi"}
+		if err := repo.CreateMessage(ctx, msg, 3); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+	}
+	if n := count(); n != 3 {
+		t.Errorf("count after three sends = %d, want 3", n)
+	}
+
+	over := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: chat.UserID2, Content: "hi"}
+	if err := repo.CreateMessage(ctx, over, 3); !errors.Is(err, ErrChatQuotaExceeded) {
+		t.Fatalf("CreateMessage() over the quota error = %v, want ErrChatQuotaExceeded", err)
+	}
+	if n := count(); n != 3 {
+		t.Errorf("count after the rejected send = %d, want 3", n)
+	}
+
+	if _, _, err := repo.SoftDeleteMessage(ctx, first.ID, false); err != nil {
+		t.Fatalf("SoftDeleteMessage() error = %v", err)
+	}
+	// A repeated delete must not count twice.
+	if _, _, err := repo.SoftDeleteMessage(ctx, first.ID, false); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("second SoftDeleteMessage() error = %v, want ErrMessageNotFound", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("count after deleting = %d, want 2", n)
+	}
+
+	if err := repo.CreateMessage(ctx, over, 3); err != nil {
+		t.Fatalf("CreateMessage() into the freed slot error = %v", err)
+	}
+	if n := count(); n != 3 {
+		t.Errorf("count after refilling = %d, want 3", n)
+	}
+}
+
+func TestGetUserChatsIncludeEmpty(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	active, _ := newAuditedChat(t, repo)
+	user := active.UserID1
+
+	empty := &models.Chat{ID: uuid.NewString(), UserID1: user, UserID2: uuid.NewString(), CreatedBy: user}
+	if err := repo.CreateChat(ctx, empty); err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+
+	tests := []struct {
+		includeEmpty bool
+		want         []string
+	}{
+		{true, []string{active.ID, empty.ID}},
+		{false, []string{active.ID}},
+	}
+	for _, tt := range tests {
+		chats, err := repo.GetUserChats(ctx, user, UserChatsFilter{IncludeEmpty: tt.includeEmpty})
+		if err != nil {
+			t.Fatalf("GetUserChats() error = %v", err)
+		}
+		var got []string
+		for _, chat := range chats {
+			got = append(got, chat.ID)
+		}
+		slices.Sort(got)
+		slices.Sort(tt.want)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("IncludeEmpty %v: chats = %v, want %v", tt.includeEmpty, got, tt.want)
+		}
+	}
+}
+
+func TestBytesUsedCountsMultibyteContent(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, _ := newAuditedChat(t, repo)
+
+	bytesUsed := func() int64 {
+		t.Helper()
+		stored, err := repo.GetChatByID(ctx, chat.ID)
+		if err != nil {
+			t.Fatalf("GetChatByID() error = %v", err)
+		}
+		return stored.BytesUsed
+	}
+	base := bytesUsed()
+
+	// 6 Cyrillic letters at 2 bytes, a space and a 4-byte emoji: 8 runes,
+	// 17 bytes.
+	msg := &models.Message{
+		ID:          uuid.NewString(),
+		ChatID:      chat.ID,
+		SenderID:    chat.UserID2,
+		Content:     "привет 👋",
+		Attachments: []*models.Attachment{{URL: "https://cdn.example/a.png", MimeType: "image/png", SizeBytes: 1000}},
+	}
+	if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+		t.Fatalf("CreateMessage() error = %v", err)
+	}
+	if got, want := bytesUsed()-base, int64(17+1000); got != want {
+		t.Errorf("bytes added by the send = %d, want %d", got, want)
+	}
+
+	msg.Content = "ок"
+	if err := repo.UpdateMessageContent(ctx, msg); err != nil {
+		t.Fatalf("UpdateMessageContent() error = %v", err)
+	}
+	if got, want := bytesUsed()-base, int64(4+1000); got != want {
+		t.Errorf("bytes after the edit = %d, want %d", got, want)
+	}
+
+	if _, _, err := repo.SoftDeleteMessage(ctx, msg.ID, false); err != nil {
+		t.Fatalf("SoftDeleteMessage() error = %v", err)
+	}
+	if got := bytesUsed(); got != base {
+		t.Errorf("bytes after deleting = %d, want %d", got, base)
+	}
+}
+
+func TestChatTypeIsStored(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	direct, _ := newAuditedChat(t, repo)
+
+	group := &models.Chat{ID: uuid.NewString(), Name: "pair", CreatedBy: direct.UserID1, ParticipantIDs: []string{direct.UserID1, direct.UserID2}}
+	if err := repo.CreateGroupChat(ctx, group); err != nil {
+		t.Fatalf("CreateGroupChat() error = %v", err)
+	}
+
+	// A group of two stays a group however many participants it has.
+	for id, want := range map[string]models.ChatType{direct.ID: models.ChatTypeDirect, group.ID: models.ChatTypeGroup} {
+		stored, err := repo.GetChatByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetChatByID() error = %v", err)
+		}
+		if stored.Type != want {
+			t.Errorf("chat %s type = %q, want %q", id, stored.Type, want)
+		}
+	}
+}
+
+// readAllPages pages back through the chat and returns its message IDs,
+// newest first.
+func readAllPages(t *testing.T, repo ChatRepository, chatID string, pageSize int) []string {
+	t.Helper()
+
+	var ids []string
+	var before *models.MessageCursor
+	for {
+		page, err := repo.GetChatMessages(context.Background(), chatID, pageSize, before, false)
+		if err != nil {
+			t.Fatalf("GetChatMessages() error = %v", err)
+		}
+		// Pages are oldest first.
+		for i := len(page) - 1; i >= 0; i-- {
+			ids = append(ids, page[i].ID)
+		}
+		if len(page) < pageSize {
+			return ids
+		}
+		oldest := page[0]
+		before = &models.MessageCursor{CreatedAt: oldest.CreatedAt, ID: oldest.ID}
+	}
+}
+
+func TestCheckPairNormalizationFindsReversedDuplicates(t *testing.T) {
+	db, schema := newTestDB(t)
+	migrator := newTestMigrator(t, db, schema)
+	ctx := context.Background()
+
+	// Seed legacy data as it could exist before pairs were normalized.
+	if _, err := migrator.UpTo(ctx, 10); err != nil {
+		t.Fatalf("apply migrations up to 10: %v", err)
+	}
+
+	a, b, c := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	reversed := uuid.NewString()
+	seed := []struct{ id, user1, user2 string }{
+		{uuid.NewString(), a, b},
+		{reversed, b, a},
+		{uuid.NewString(), a, c},
+	}
+	for _, chat := range seed {
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO chats (id, user_id1, user_id2, created_by) VALUES ($1, $2, $3, $2)`,
+			chat.id, chat.user1, chat.user2,
+		)
+		if err != nil {
+			t.Fatalf("seed chat: %v", err)
+		}
+	}
+
+	repo := NewChatRepository(db, Options{Schema: schema})
+	report, err := repo.CheckPairNormalization(ctx)
+	if err != nil {
+		t.Fatalf("CheckPairNormalization() error = %v", err)
+	}
+	if report.ReversedDuplicates != 2 || report.NormalizedConstraint {
+		t.Errorf("CheckPairNormalization() = %+v, want 2 offending rows and no normalized constraint", report)
+	}
+
+	if _, err := migrator.Up(ctx); err == nil {
+		t.Fatal("pair normalization migrated over reversed duplicates")
+	}
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM chats WHERE id = $1`, reversed); err != nil {
+		t.Fatalf("delete duplicate: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+
+	report, err = repo.CheckPairNormalization(ctx)
+	if err != nil {
+		t.Fatalf("CheckPairNormalization() error = %v", err)
+	}
+	if report.ReversedDuplicates != 0 || !report.NormalizedConstraint {
+		t.Errorf("CheckPairNormalization() after migrating = %+v, want no duplicates and the normalized constraint", report)
+	}
+
+	// The reversed pair now finds the stored chat instead of adding one.
+	chat := &models.Chat{ID: uuid.NewString(), UserID1: b, UserID2: a, CreatedBy: b}
+	if err := repo.CreateChat(ctx, chat); err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM chats`).Scan(&count); err != nil {
+		t.Fatalf("count chats: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("stored %d chats, want 2", count)
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type retryingConnector struct {
+	driver.Connector
+	attempts  int
+	baseDelay time.Duration
+}
+
+// NewRetryingConnector retries opening connections that fail for transient
+// reasons, such as Postgres restarting or refusing connections while it
+// starts up. Each retry waits twice as long as the one before, and there are
+// at most attempts tries in total. Every repository method needs a pooled
+// connection, so they all ride out a short outage this way. database/sql
+// already replaces pooled connections that turn out to be dead. Statements
+// that fail partway are never retried, because writes aren't idempotent.
+func NewRetryingConnector(connector driver.Connector, attempts int, baseDelay time.Duration) driver.Connector {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return &retryingConnector{Connector: connector, attempts: attempts, baseDelay: baseDelay}
+}
+
+func (c *retryingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	delay := c.baseDelay
+	for attempt := 1; ; attempt++ {
+		conn, err := c.Connector.Connect(ctx)
+		if err == nil || attempt >= c.attempts || !isTransientConnError(err) {
+			return conn, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+const (
+	adminShutdown    = "57P01"
+	cannotConnectNow = "57P03"
+)
+
+func isTransientConnError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection exceptions.
+		return pqErr.Code.Class() == "08" || pqErr.Code == adminShutdown || pqErr.Code == cannotConnectNow
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF)
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/models"
+)
+
+// GetMessageEdits returns the message's earlier versions, oldest first.
+func (r *chatRepository) GetMessageEdits(ctx context.Context, messageID string) ([]*models.MessageEdit, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT message_id, old_content, editor_id, edited_at
+	FROM message_edits
+	WHERE message_id = $1
+	ORDER BY edited_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edits []*models.MessageEdit
+	for rows.Next() {
+		var edit models.MessageEdit
+		if err := rows.Scan(&edit.MessageID, &edit.OldContent, &edit.EditorID, &edit.EditedAt); err != nil {
+			return nil, err
+		}
+		edits = append(edits, &edit)
+	}
+
+	return edits, rows.Err()
+}
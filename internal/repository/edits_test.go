@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/models"
+)
+
+func TestUpdateMessageContentRecordsEdits(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	_, msg := newAuditedChat(t, repo)
+
+	var editedAt []time.Time
+	for _, content := range []string{"second", "third"} {
+		msg.Content = content
+		if err := repo.UpdateMessageContent(ctx, msg); err != nil {
+			t.Fatalf("UpdateMessageContent() error = %v", err)
+		}
+		editedAt = append(editedAt, *msg.EditedAt)
+	}
+
+	edits, err := repo.GetMessageEdits(ctx, msg.ID)
+	if err != nil {
+		t.Fatalf("GetMessageEdits() error = %v", err)
+	}
+	want := []models.MessageEdit{
+		{MessageID: msg.ID, OldContent: "hello", EditorID: msg.SenderID},
+		{MessageID: msg.ID, OldContent: "second", EditorID: msg.SenderID},
+	}
+	if len(edits) != len(want) {
+		t.Fatalf("got %d edits, want %d", len(edits), len(want))
+	}
+	for i, edit := range edits {
+		got := *edit
+		got.EditedAt = want[i].EditedAt
+		if got != want[i] {
+			t.Errorf("edit %d = %+v, want %+v", i, got, want[i])
+		}
+		if !edit.EditedAt.Equal(editedAt[i]) {
+			t.Errorf("edit %d edited at %v, want the edit time %v", i, edit.EditedAt, editedAt[i])
+		}
+	}
+}
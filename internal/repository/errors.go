@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrChatNotFound       = errors.New("chat not found")
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrDuplicateMessageID = errors.New("message id already exists")
+	ErrPinLimitReached    = errors.New("chat pin limit reached")
+	ErrChatQuotaExceeded  = errors.New("chat message quota exceeded")
+)
+
+const (
+	uniqueViolation = "23505"
+	queryCanceled   = "57014"
+)
+
+// IsQueryCanceled reports whether Postgres aborted the statement, which is
+// how a context cancellation or timeout surfaces once the query has started.
+func IsQueryCanceled(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == queryCanceled
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == uniqueViolation && pqErr.Constraint == constraint
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"metachat/chat-service/migrations"
+
+	"github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+// NewMigrator returns a goose provider over the embedded migrations. The
+// version table lives in schema, alongside the tables it tracks.
+func NewMigrator(ctx context.Context, db *sql.DB, schema string) (*goose.Provider, error) {
+	if schema == "" {
+		schema = "public"
+	}
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, pq.QuoteIdentifier(schema))); err != nil {
+		return nil, err
+	}
+
+	return goose.NewProvider(goose.DialectPostgres, db, migrations.FS)
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"metachat/chat-service/internal/models"
+)
+
+// PinMessage pins msg in its chat on behalf of userID and audits the pin.
+// Pinning an already pinned message is a no-op; otherwise, with maxPins > 0,
+// the chat may hold at most maxPins pins. The chat row is locked so
+// concurrent pins can't overshoot the limit.
+func (r *chatRepository) PinMessage(ctx context.Context, msg *models.Message, userID string, maxPins int) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var pinned, count int
+	err = tx.QueryRowContext(ctx, `
+	SELECT
+		COUNT(*) FILTER (WHERE p.message_id = $2),
+		COUNT(p.message_id)
+	FROM (SELECT id FROM chats WHERE id = $1 FOR UPDATE) c
+	LEFT JOIN pinned_messages p ON p.chat_id = c.id
+	`, msg.ChatID, msg.ID).Scan(&pinned, &count)
+	if err != nil {
+		return err
+	}
+
+	if pinned > 0 {
+		return nil
+	}
+	if maxPins > 0 && count >= maxPins {
+		return ErrPinLimitReached
+	}
+
+	query := `
+	INSERT INTO pinned_messages (message_id, chat_id, pinned_by)
+	VALUES ($1, $2, $3)
+	`
+
+	if _, err := tx.ExecContext(ctx, query, msg.ID, msg.ChatID, userID); err != nil {
+		return err
+	}
+
+	err = r.writeAudit(ctx, tx, &models.AuditEntry{
+		ActorID:  userID,
+		Action:   models.AuditActionPinMessage,
+		ChatID:   msg.ChatID,
+		TargetID: msg.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UnpinMessage removes the message's pin on behalf of userID and audits it.
+// Unpinning a message that isn't pinned is a no-op.
+func (r *chatRepository) UnpinMessage(ctx context.Context, messageID, userID string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var chatID string
+	err = tx.QueryRowContext(ctx,
+		`DELETE FROM pinned_messages WHERE message_id = $1 RETURNING chat_id`, messageID,
+	).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = r.writeAudit(ctx, tx, &models.AuditEntry{
+		ActorID:  userID,
+		Action:   models.AuditActionUnpinMessage,
+		ChatID:   chatID,
+		TargetID: messageID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPinnedMessages returns the chat's pinned messages that haven't been
+// deleted, most recently pinned first.
+func (r *chatRepository) GetPinnedMessages(ctx context.Context, chatID string) ([]*models.PinnedMessage, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT ` + prefixedMessageColumns + `, p.pinned_by, p.pinned_at
+	FROM pinned_messages p
+	JOIN messages m ON m.id = p.message_id
+	WHERE p.chat_id = $1 AND m.deleted_at IS NULL
+	ORDER BY p.pinned_at DESC, p.message_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pins []*models.PinnedMessage
+	for rows.Next() {
+		var pin models.PinnedMessage
+		msg, err := scanMessage(rows, &pin.PinnedBy, &pin.PinnedAt)
+		if err != nil {
+			return nil, err
+		}
+		pin.Message = msg
+		pins = append(pins, &pin)
+	}
+
+	return pins, rows.Err()
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// AddReaction records a user's emoji reaction on a message. Reacting twice
+// with the same emoji is a no-op.
+func (r *chatRepository) AddReaction(ctx context.Context, messageID, userID, emoji string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	INSERT INTO message_reactions (message_id, user_id, emoji)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+	`
+
+	_, err := r.db.ExecContext(ctx, query, messageID, userID, emoji)
+	return err
+}
+
+func (r *chatRepository) RemoveReaction(ctx context.Context, messageID, userID, emoji string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	DELETE FROM message_reactions
+	WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+	`
+
+	_, err := r.db.ExecContext(ctx, query, messageID, userID, emoji)
+	return err
+}
+
+// ToggleReaction adds the user's emoji reaction if it is absent and removes
+// it if present, reporting whether the user has reacted afterwards. The
+// message row is locked so concurrent toggles apply one after another and
+// never both add.
+func (r *chatRepository) ToggleReaction(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM messages WHERE id = $1 FOR UPDATE`, messageID).Scan(&locked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, ErrMessageNotFound
+		}
+		return false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+	DELETE FROM message_reactions
+	WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+	`, messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if removed == 0 {
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO message_reactions (message_id, user_id, emoji)
+		VALUES ($1, $2, $3)
+		`, messageID, userID, emoji)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return removed == 0, nil
+}
+
+// GetReactionCounts returns per-emoji reaction counts keyed by message ID,
+// most used emoji first, in one query for all the messages. Each count is
+// flagged Reacted if viewerID is among its users; an empty viewerID flags
+// none.
+func (r *chatRepository) GetReactionCounts(ctx context.Context, messageIDs []string, viewerID string) (map[string][]*models.ReactionCount, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	counts := make(map[string][]*models.ReactionCount)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+
+	query := `
+	SELECT message_id, emoji, COUNT(*), BOOL_OR(user_id::text = $2)
+	FROM message_reactions
+	WHERE message_id = ANY($1::uuid[])
+	GROUP BY message_id, emoji
+	ORDER BY message_id, COUNT(*) DESC, emoji
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(messageIDs), viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID string
+		var rc models.ReactionCount
+		if err := rows.Scan(&messageID, &rc.Emoji, &rc.Count, &rc.Reacted); err != nil {
+			return nil, err
+		}
+		counts[messageID] = append(counts[messageID], &rc)
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"metachat/chat-service/internal/models"
+)
+
+func TestToggleReaction(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, msg := newAuditedChat(t, repo)
+
+	for i, want := range []bool{true, false} {
+		reacted, err := repo.ToggleReaction(ctx, msg.ID, chat.UserID2, "👍")
+		if err != nil {
+			t.Fatalf("toggle %d: error = %v", i+1, err)
+		}
+		if reacted != want {
+			t.Errorf("toggle %d: reacted = %v, want %v", i+1, reacted, want)
+		}
+	}
+
+	counts, err := repo.GetReactionCounts(ctx, []string{msg.ID}, "")
+	if err != nil {
+		t.Fatalf("GetReactionCounts() error = %v", err)
+	}
+	if len(counts[msg.ID]) != 0 {
+		t.Errorf("reactions after two toggles = %v, want none", counts[msg.ID])
+	}
+}
+
+func TestToggleReactionConcurrently(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, msg := newAuditedChat(t, repo)
+
+	const toggles = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	added := 0
+	for i := 0; i < toggles; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reacted, err := repo.ToggleReaction(ctx, msg.ID, chat.UserID2, "👍")
+			if err != nil {
+				t.Errorf("ToggleReaction() error = %v", err)
+				return
+			}
+			if reacted {
+				mu.Lock()
+				added++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Applied one at a time, the toggles alternate between adding and
+	// removing, so an even number of them leaves no reaction.
+	if added != toggles/2 {
+		t.Errorf("%d of %d toggles added the reaction, want %d", added, toggles, toggles/2)
+	}
+	counts, err := repo.GetReactionCounts(ctx, []string{msg.ID}, "")
+	if err != nil {
+		t.Fatalf("GetReactionCounts() error = %v", err)
+	}
+	if len(counts[msg.ID]) != 0 {
+		t.Errorf("reactions after %d toggles = %v, want none", toggles, counts[msg.ID])
+	}
+}
+
+func TestSoftDeleteMessageReactions(t *testing.T) {
+	for _, clearReactions := range []bool{true, false} {
+		t.Run(fmt.Sprintf("clear=%v", clearReactions), func(t *testing.T) {
+			repo := newTestRepository(t, Options{})
+			ctx := context.Background()
+			chat, msg := newAuditedChat(t, repo)
+
+			if err := repo.AddReaction(ctx, msg.ID, chat.UserID2, "👍"); err != nil {
+				t.Fatalf("AddReaction() error = %v", err)
+			}
+			_, cleared, err := repo.SoftDeleteMessage(ctx, msg.ID, clearReactions)
+			if err != nil {
+				t.Fatalf("SoftDeleteMessage() error = %v", err)
+			}
+
+			counts, err := repo.GetReactionCounts(ctx, []string{msg.ID}, "")
+			if err != nil {
+				t.Fatalf("GetReactionCounts() error = %v", err)
+			}
+			if clearReactions {
+				if len(cleared) != 1 || cleared[0].UserID != chat.UserID2 || cleared[0].Emoji != "👍" {
+					t.Errorf("cleared = %v, want the one reaction", cleared)
+				}
+				if len(counts[msg.ID]) != 0 {
+					t.Errorf("reactions after delete = %v, want none", counts[msg.ID])
+				}
+			} else {
+				if len(cleared) != 0 {
+					t.Errorf("cleared = %v, want none", cleared)
+				}
+				if len(counts[msg.ID]) != 1 {
+					t.Errorf("reactions after delete = %v, want the one kept", counts[msg.ID])
+				}
+			}
+		})
+	}
+}
+
+func TestGetReactionCountsFlagsViewer(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, msg := newAuditedChat(t, repo)
+
+	for _, r := range []struct{ userID, emoji string }{
+		{chat.UserID1, "👍"},
+		{chat.UserID2, "👍"},
+		{chat.UserID2, "🎉"},
+	} {
+		if err := repo.AddReaction(ctx, msg.ID, r.userID, r.emoji); err != nil {
+			t.Fatalf("AddReaction() error = %v", err)
+		}
+	}
+
+	counts, err := repo.GetReactionCounts(ctx, []string{msg.ID}, chat.UserID1)
+	if err != nil {
+		t.Fatalf("GetReactionCounts() error = %v", err)
+	}
+	want := []models.ReactionCount{{Emoji: "👍", Count: 2, Reacted: true}, {Emoji: "🎉", Count: 1}}
+	var got []models.ReactionCount
+	for _, rc := range counts[msg.ID] {
+		got = append(got, *rc)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("counts = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/lib/pq"
+)
+
+func (r *chatRepository) GetMessagesByIDs(ctx context.Context, ids []string) ([]*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE id = ANY($1::uuid[])
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return collectMessages(rows)
+}
+
+// GetReplies returns the undeleted replies to parentID, oldest first.
+func (r *chatRepository) GetReplies(ctx context.Context, parentID string) ([]*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE reply_to_message_id = $1 AND deleted_at IS NULL
+	ORDER BY created_at, id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, parentID)
+	if err != nil {
+		return nil, err
+	}
+	return collectMessages(rows)
+}
+
+// GetReplyDepth returns how many replies deep messageID is, zero for a
+// message that replies to nothing. The walk up its chain stops after
+// maxDepth steps, so the result is at most maxDepth however long, or
+// circular, the chain is.
+func (r *chatRepository) GetReplyDepth(ctx context.Context, messageID string, maxDepth int) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	WITH RECURSIVE chain (parent_id, depth) AS (
+		SELECT reply_to_message_id, 0
+		FROM messages
+		WHERE id = $1
+		UNION ALL
+		SELECT m.reply_to_message_id, c.depth + 1
+		FROM messages m
+		JOIN chain c ON m.id = c.parent_id
+		WHERE c.depth < $2
+	)
+	SELECT COALESCE(MAX(depth), 0) FROM chain
+	`
+
+	var depth int
+	if err := r.db.QueryRowContext(ctx, query, messageID, maxDepth).Scan(&depth); err != nil {
+		return 0, err
+	}
+	return depth, nil
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestGetReplyDepth(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	ctx := context.Background()
+	chat, root := newAuditedChat(t, repo)
+
+	// root <- 1 <- 2 <- 3 <- 4
+	chain := []string{root.ID}
+	for i := 0; i < 4; i++ {
+		reply := &models.Message{
+			ID:        uuid.NewString(),
+			ChatID:    chat.ID,
+			SenderID:  chat.UserID1,
+			Content:   "reply",
+			ReplyToID: chain[len(chain)-1],
+		}
+		if err := repo.CreateMessage(ctx, reply, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+		chain = append(chain, reply.ID)
+	}
+
+	tests := []struct {
+		name      string
+		messageID string
+		maxDepth  int
+		want      int
+	}{
+		{"root", chain[0], 10, 0},
+		{"direct reply", chain[1], 10, 1},
+		{"end of chain", chain[4], 10, 4},
+		{"at the bound", chain[4], 4, 4},
+		{"walk stops at the bound", chain[4], 2, 2},
+		{"unknown message", uuid.NewString(), 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetReplyDepth(ctx, tt.messageID, tt.maxDepth)
+			if err != nil {
+				t.Fatalf("GetReplyDepth() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetReplyDepth(max %d) = %d, want %d", tt.maxDepth, got, tt.want)
+			}
+		})
+	}
+}
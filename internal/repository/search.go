@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// contentMatch returns the WHERE fragment matching the content column against
+// the search text bound at placeholder. By default this is a case-insensitive
+// substring match; with Options.FullTextSearch it switches to a tsquery
+// backed by the idx_messages_content_fts GIN index, and the argument to bind
+// is the raw text rather than the escaped pattern.
+func (r *chatRepository) contentMatch(column, placeholder, text string) (string, interface{}) {
+	if r.options.FullTextSearch {
+		return `to_tsvector('simple', ` + column + `) @@ plainto_tsquery('simple', ` + placeholder + `)`, text
+	}
+	return column + ` ILIKE ` + placeholder + ` ESCAPE '\'`, "%" + likeEscaper.Replace(text) + "%"
+}
+
+// SearchChatMessages returns the newest non-deleted messages in the chat
+// whose content matches text.
+func (r *chatRepository) SearchChatMessages(ctx context.Context, chatID, text string, limit int) ([]*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	match, arg := r.contentMatch("content", "$2", text)
+	query := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE chat_id = $1 AND deleted_at IS NULL AND ` + match + `
+	ORDER BY created_at DESC, id DESC
+	LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, arg, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectMessages(rows)
+}
+
+// SearchUserMessages searches every chat userID participates in, newest
+// first, paging back from before when set. Messages in chats the user has
+// hidden are skipped unless they arrived after the chat was hidden.
+func (r *chatRepository) SearchUserMessages(ctx context.Context, userID, text string, limit int, before *models.MessageCursor) ([]*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	match, arg := r.contentMatch("m.content", "$2", text)
+	args := []interface{}{userID, arg, limit}
+
+	cursor := ""
+	if before != nil {
+		cursor = "AND (m.created_at, m.id) < ($4, $5)"
+		args = append(args, before.CreatedAt, before.ID)
+	}
+
+	query := `
+	SELECT ` + prefixedMessageColumns + `
+	FROM messages m
+	JOIN chat_participants cp ON cp.chat_id = m.chat_id AND cp.user_id = $1
+	WHERE m.deleted_at IS NULL
+		AND (cp.hidden_at IS NULL OR m.created_at > cp.hidden_at)
+		AND ` + match + `
+		` + cursor + `
+	ORDER BY m.created_at DESC, m.id DESC
+	LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectMessages(rows)
+}
+
+// GetMessageContext returns, oldest first, every non-deleted message in the
+// chat that is at most radius positions away from one of messageIDs,
+// including those messages themselves.
+func (r *chatRepository) GetMessageContext(ctx context.Context, chatID string, messageIDs []string, radius int) ([]*models.Message, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	WITH numbered AS (
+		SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS pos
+		FROM messages
+		WHERE chat_id = $1 AND deleted_at IS NULL
+	), hits AS (
+		SELECT pos FROM numbered WHERE id = ANY($2::uuid[])
+	)
+	SELECT ` + prefixedMessageColumns + `
+	FROM numbered n
+	JOIN messages m ON m.id = n.id
+	WHERE EXISTS (SELECT 1 FROM hits h WHERE n.pos BETWEEN h.pos - $3 AND h.pos + $3)
+	ORDER BY n.pos
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, pq.Array(messageIDs), radius)
+	if err != nil {
+		return nil, err
+	}
+	return collectMessages(rows)
+}
+
+func collectMessages(rows *sql.Rows) ([]*models.Message, error) {
+	defer rows.Close()
+
+	var messages []*models.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
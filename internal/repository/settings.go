@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// mutedCondition is true for a chat_settings row aliased s whose mute is in
+// effect; a lapsed muted_until counts as unmuted without needing a cleanup.
+const mutedCondition = `s.muted AND (s.muted_until IS NULL OR s.muted_until > NOW())`
+
+// SetChatMute mutes or unmutes the chat for userID. until bounds a temporary
+// mute and is ignored when unmuting.
+func (r *chatRepository) SetChatMute(ctx context.Context, chatID, userID string, muted bool, until *time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	var mutedUntil sql.NullTime
+	if muted && until != nil {
+		mutedUntil = sql.NullTime{Time: until.UTC(), Valid: true}
+	}
+
+	query := `
+	INSERT INTO chat_settings (chat_id, user_id, muted, muted_until, updated_at)
+	VALUES ($1, $2, $3, $4, NOW())
+	ON CONFLICT (chat_id, user_id) DO UPDATE
+	SET muted = EXCLUDED.muted, muted_until = EXCLUDED.muted_until, updated_at = NOW()
+	`
+
+	_, err := r.db.ExecContext(ctx, query, chatID, userID, muted, mutedUntil)
+	return err
+}
+
+func (r *chatRepository) SetChatArchived(ctx context.Context, chatID, userID string, archived bool) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	INSERT INTO chat_settings (chat_id, user_id, archived, updated_at)
+	VALUES ($1, $2, $3, NOW())
+	ON CONFLICT (chat_id, user_id) DO UPDATE
+	SET archived = EXCLUDED.archived, updated_at = NOW()
+	`
+
+	_, err := r.db.ExecContext(ctx, query, chatID, userID, archived)
+	return err
+}
+
+// GetMutedUserIDs returns those of userIDs who currently have the chat muted.
+func (r *chatRepository) GetMutedUserIDs(ctx context.Context, chatID string, userIDs []string) ([]string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+	SELECT s.user_id
+	FROM chat_settings s
+	WHERE s.chat_id = $1 AND s.user_id = ANY($2::uuid[]) AND ` + mutedCondition
+
+	rows, err := r.db.QueryContext(ctx, query, chatID, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var muted []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		muted = append(muted, userID)
+	}
+
+	return muted, rows.Err()
+}
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedRepository struct {
+	next ChatRepository
+}
+
+// NewTracedRepository wraps repo so every call runs in a child span named
+// after the repository method.
+func NewTracedRepository(repo ChatRepository) ChatRepository {
+	return &tracedRepository{next: repo}
+}
+
+func (r *tracedRepository) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, "ChatRepository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, attribute.String("db.system", "postgresql"))...),
+	)
+}
+
+func (r *tracedRepository) CreateChat(ctx context.Context, chat *models.Chat) (err error) {
+	ctx, span := r.start(ctx, "CreateChat")
+	defer func() { span.SetAttributes(tracing.ChatID(chat.ID)); tracing.End(span, err) }()
+	return r.next.CreateChat(ctx, chat)
+}
+
+func (r *tracedRepository) CreateGroupChat(ctx context.Context, chat *models.Chat) (err error) {
+	ctx, span := r.start(ctx, "CreateGroupChat", tracing.ChatID(chat.ID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.CreateGroupChat(ctx, chat)
+}
+
+func (r *tracedRepository) GetChatByID(ctx context.Context, id string) (_ *models.Chat, err error) {
+	ctx, span := r.start(ctx, "GetChatByID", tracing.ChatID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetChatByID(ctx, id)
+}
+
+func (r *tracedRepository) GetChatsByIDs(ctx context.Context, userID string, ids []string) (_ []*models.Chat, err error) {
+	ctx, span := r.start(ctx, "GetChatsByIDs", tracing.UserID(userID), attribute.Int("chat_count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetChatsByIDs(ctx, userID, ids)
+}
+
+func (r *tracedRepository) GetChatByUsers(ctx context.Context, userID1, userID2 string) (_ *models.Chat, err error) {
+	ctx, span := r.start(ctx, "GetChatByUsers")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetChatByUsers(ctx, userID1, userID2)
+}
+
+func (r *tracedRepository) GetUserChats(ctx context.Context, userID string, filter UserChatsFilter) (_ []*models.Chat, err error) {
+	ctx, span := r.start(ctx, "GetUserChats", tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetUserChats(ctx, userID, filter)
+}
+
+func (r *tracedRepository) GetChatsWithPeers(ctx context.Context, userID string, peerIDs []string) (_ []*models.Chat, err error) {
+	ctx, span := r.start(ctx, "GetChatsWithPeers", tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetChatsWithPeers(ctx, userID, peerIDs)
+}
+
+func (r *tracedRepository) UpdateChat(ctx context.Context, chat *models.Chat) (err error) {
+	ctx, span := r.start(ctx, "UpdateChat", tracing.ChatID(chat.ID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.UpdateChat(ctx, chat)
+}
+
+func (r *tracedRepository) GetChatParticipants(ctx context.Context, chatID string) (_ []*models.Participant, err error) {
+	ctx, span := r.start(ctx, "GetChatParticipants", tracing.ChatID(chatID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetChatParticipants(ctx, chatID)
+}
+
+func (r *tracedRepository) DeleteChat(ctx context.Context, chatID, actorID string) (err error) {
+	ctx, span := r.start(ctx, "DeleteChat", tracing.ChatID(chatID), tracing.UserID(actorID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.DeleteChat(ctx, chatID, actorID)
+}
+
+func (r *tracedRepository) HideChat(ctx context.Context, chatID, userID string) (err error) {
+	ctx, span := r.start(ctx, "HideChat", tracing.ChatID(chatID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.HideChat(ctx, chatID, userID)
+}
+
+func (r *tracedRepository) BlockUser(ctx context.Context, blockerID, blockedID string) (err error) {
+	ctx, span := r.start(ctx, "BlockUser", tracing.UserID(blockerID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.BlockUser(ctx, blockerID, blockedID)
+}
+
+func (r *tracedRepository) UnblockUser(ctx context.Context, blockerID, blockedID string) (err error) {
+	ctx, span := r.start(ctx, "UnblockUser", tracing.UserID(blockerID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.UnblockUser(ctx, blockerID, blockedID)
+}
+
+func (r *tracedRepository) IsBlockedBy(ctx context.Context, blockedID string, blockerIDs []string) (_ bool, err error) {
+	ctx, span := r.start(ctx, "IsBlockedBy", tracing.UserID(blockedID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.IsBlockedBy(ctx, blockedID, blockerIDs)
+}
+
+func (r *tracedRepository) SetChatMute(ctx context.Context, chatID, userID string, muted bool, until *time.Time) (err error) {
+	ctx, span := r.start(ctx, "SetChatMute", tracing.ChatID(chatID), tracing.UserID(userID), attribute.Bool("muted", muted))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SetChatMute(ctx, chatID, userID, muted, until)
+}
+
+func (r *tracedRepository) SetChatArchived(ctx context.Context, chatID, userID string, archived bool) (err error) {
+	ctx, span := r.start(ctx, "SetChatArchived", tracing.ChatID(chatID), tracing.UserID(userID), attribute.Bool("archived", archived))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SetChatArchived(ctx, chatID, userID, archived)
+}
+
+func (r *tracedRepository) GetMutedUserIDs(ctx context.Context, chatID string, userIDs []string) (_ []string, err error) {
+	ctx, span := r.start(ctx, "GetMutedUserIDs", tracing.ChatID(chatID), attribute.Int("user_count", len(userIDs)))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMutedUserIDs(ctx, chatID, userIDs)
+}
+
+func (r *tracedRepository) PinMessage(ctx context.Context, msg *models.Message, userID string, maxPins int) (err error) {
+	ctx, span := r.start(ctx, "PinMessage", tracing.ChatID(msg.ChatID), tracing.MessageID(msg.ID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.PinMessage(ctx, msg, userID, maxPins)
+}
+
+func (r *tracedRepository) UnpinMessage(ctx context.Context, messageID, userID string) (err error) {
+	ctx, span := r.start(ctx, "UnpinMessage", tracing.MessageID(messageID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.UnpinMessage(ctx, messageID, userID)
+}
+
+func (r *tracedRepository) GetPinnedMessages(ctx context.Context, chatID string) (_ []*models.PinnedMessage, err error) {
+	ctx, span := r.start(ctx, "GetPinnedMessages", tracing.ChatID(chatID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetPinnedMessages(ctx, chatID)
+}
+
+func (r *tracedRepository) CreateMessage(ctx context.Context, msg *models.Message, maxMessages int64) (err error) {
+	ctx, span := r.start(ctx, "CreateMessage", tracing.ChatID(msg.ChatID), tracing.SenderID(msg.SenderID))
+	defer func() { span.SetAttributes(tracing.MessageID(msg.ID)); tracing.End(span, err) }()
+	return r.next.CreateMessage(ctx, msg, maxMessages)
+}
+
+func (r *tracedRepository) CreateMessageMarkingRead(ctx context.Context, msg *models.Message, maxMessages int64) (_ int, err error) {
+	ctx, span := r.start(ctx, "CreateMessageMarkingRead", tracing.ChatID(msg.ChatID), tracing.SenderID(msg.SenderID))
+	defer func() { span.SetAttributes(tracing.MessageID(msg.ID)); tracing.End(span, err) }()
+	return r.next.CreateMessageMarkingRead(ctx, msg, maxMessages)
+}
+
+func (r *tracedRepository) GetMessageByID(ctx context.Context, id string) (_ *models.Message, err error) {
+	ctx, span := r.start(ctx, "GetMessageByID", tracing.MessageID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMessageByID(ctx, id)
+}
+
+func (r *tracedRepository) GetMessagesByIDs(ctx context.Context, ids []string) (_ []*models.Message, err error) {
+	ctx, span := r.start(ctx, "GetMessagesByIDs", attribute.Int("message_count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMessagesByIDs(ctx, ids)
+}
+
+func (r *tracedRepository) GetReplies(ctx context.Context, parentID string) (_ []*models.Message, err error) {
+	ctx, span := r.start(ctx, "GetReplies", tracing.MessageID(parentID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetReplies(ctx, parentID)
+}
+
+func (r *tracedRepository) GetReplyDepth(ctx context.Context, messageID string, maxDepth int) (_ int, err error) {
+	ctx, span := r.start(ctx, "GetReplyDepth", tracing.MessageID(messageID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetReplyDepth(ctx, messageID, maxDepth)
+}
+
+func (r *tracedRepository) UpdateMessageContent(ctx context.Context, msg *models.Message) (err error) {
+	ctx, span := r.start(ctx, "UpdateMessageContent", tracing.ChatID(msg.ChatID), tracing.SenderID(msg.SenderID), tracing.MessageID(msg.ID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.UpdateMessageContent(ctx, msg)
+}
+
+func (r *tracedRepository) GetMessageEdits(ctx context.Context, messageID string) (_ []*models.MessageEdit, err error) {
+	ctx, span := r.start(ctx, "GetMessageEdits", tracing.MessageID(messageID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMessageEdits(ctx, messageID)
+}
+
+func (r *tracedRepository) SoftDeleteMessage(ctx context.Context, id string, clearReactions bool) (_ time.Time, _ []*models.Reaction, err error) {
+	ctx, span := r.start(ctx, "SoftDeleteMessage", tracing.MessageID(id))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SoftDeleteMessage(ctx, id, clearReactions)
+}
+
+func (r *tracedRepository) GetChatMessages(ctx context.Context, chatID string, limit int, cursor *models.MessageCursor, includeDeleted bool) (_ []*models.Message, err error) {
+	ctx, span := r.start(ctx, "GetChatMessages", tracing.ChatID(chatID), attribute.Int("limit", limit))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetChatMessages(ctx, chatID, limit, cursor, includeDeleted)
+}
+
+func (r *tracedRepository) GetMessageContext(ctx context.Context, chatID string, messageIDs []string, radius int) (_ []*models.Message, err error) {
+	ctx, span := r.start(ctx, "GetMessageContext", tracing.ChatID(chatID), attribute.Int("messages", len(messageIDs)), attribute.Int("radius", radius))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMessageContext(ctx, chatID, messageIDs, radius)
+}
+
+func (r *tracedRepository) SearchChatMessages(ctx context.Context, chatID, text string, limit int) (_ []*models.Message, err error) {
+	ctx, span := r.start(ctx, "SearchChatMessages", tracing.ChatID(chatID), attribute.Int("limit", limit))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SearchChatMessages(ctx, chatID, text, limit)
+}
+
+func (r *tracedRepository) SearchUserMessages(ctx context.Context, userID, text string, limit int, before *models.MessageCursor) (_ []*models.Message, err error) {
+	ctx, span := r.start(ctx, "SearchUserMessages", tracing.UserID(userID), attribute.Int("limit", limit))
+	defer func() { tracing.End(span, err) }()
+	return r.next.SearchUserMessages(ctx, userID, text, limit, before)
+}
+
+func (r *tracedRepository) MarkMessagesAsRead(ctx context.Context, chatID, userID string) (_ int, err error) {
+	ctx, span := r.start(ctx, "MarkMessagesAsRead", tracing.ChatID(chatID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.MarkMessagesAsRead(ctx, chatID, userID)
+}
+
+func (r *tracedRepository) MarkMessagesAsDelivered(ctx context.Context, chatID, userID string) (_ int, err error) {
+	ctx, span := r.start(ctx, "MarkMessagesAsDelivered", tracing.ChatID(chatID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.MarkMessagesAsDelivered(ctx, chatID, userID)
+}
+
+func (r *tracedRepository) GetMessageReaders(ctx context.Context, messageID string) (_ []*models.MessageRead, err error) {
+	ctx, span := r.start(ctx, "GetMessageReaders", tracing.MessageID(messageID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMessageReaders(ctx, messageID)
+}
+
+func (r *tracedRepository) GetLastRead(ctx context.Context, chatID, userID string) (_ *models.ReadPointer, err error) {
+	ctx, span := r.start(ctx, "GetLastRead", tracing.ChatID(chatID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetLastRead(ctx, chatID, userID)
+}
+
+func (r *tracedRepository) GetFirstUnreadMessage(ctx context.Context, chatID, userID string) (_ *models.Message, err error) {
+	ctx, span := r.start(ctx, "GetFirstUnreadMessage", tracing.ChatID(chatID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetFirstUnreadMessage(ctx, chatID, userID)
+}
+
+func (r *tracedRepository) AddReaction(ctx context.Context, messageID, userID, emoji string) (err error) {
+	ctx, span := r.start(ctx, "AddReaction", tracing.MessageID(messageID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.AddReaction(ctx, messageID, userID, emoji)
+}
+
+func (r *tracedRepository) RemoveReaction(ctx context.Context, messageID, userID, emoji string) (err error) {
+	ctx, span := r.start(ctx, "RemoveReaction", tracing.MessageID(messageID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.RemoveReaction(ctx, messageID, userID, emoji)
+}
+
+func (r *tracedRepository) ToggleReaction(ctx context.Context, messageID, userID, emoji string) (_ bool, err error) {
+	ctx, span := r.start(ctx, "ToggleReaction", tracing.MessageID(messageID), tracing.UserID(userID))
+	defer func() { tracing.End(span, err) }()
+	return r.next.ToggleReaction(ctx, messageID, userID, emoji)
+}
+
+func (r *tracedRepository) GetReactionCounts(ctx context.Context, messageIDs []string, viewerID string) (_ map[string][]*models.ReactionCount, err error) {
+	ctx, span := r.start(ctx, "GetReactionCounts", attribute.Int("message_count", len(messageIDs)))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetReactionCounts(ctx, messageIDs, viewerID)
+}
+
+func (r *tracedRepository) GetAttachments(ctx context.Context, messageIDs []string) (_ map[string][]*models.Attachment, err error) {
+	ctx, span := r.start(ctx, "GetAttachments", attribute.Int("message_count", len(messageIDs)))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetAttachments(ctx, messageIDs)
+}
+
+func (r *tracedRepository) GetMessageHistogram(ctx context.Context, chatID, bucket string, from, to time.Time, fillGaps bool) (_ []*models.HistogramBucket, err error) {
+	ctx, span := r.start(ctx, "GetMessageHistogram", tracing.ChatID(chatID), attribute.String("bucket", bucket))
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetMessageHistogram(ctx, chatID, bucket, from, to, fillGaps)
+}
+
+func (r *tracedRepository) GetTopDuplicatedContent(ctx context.Context, limit int) (_ []*models.DuplicateContent, err error) {
+	ctx, span := r.start(ctx, "GetTopDuplicatedContent")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetTopDuplicatedContent(ctx, limit)
+}
+
+func (r *tracedRepository) GetContentSpread(ctx context.Context, contentHash string) (_ *models.ContentSpread, err error) {
+	ctx, span := r.start(ctx, "GetContentSpread")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetContentSpread(ctx, contentHash)
+}
+
+func (r *tracedRepository) GetAuditLog(ctx context.Context, filter models.AuditFilter) (_ []*models.AuditEntry, err error) {
+	ctx, span := r.start(ctx, "GetAuditLog")
+	defer func() { tracing.End(span, err) }()
+	return r.next.GetAuditLog(ctx, filter)
+}
+
+func (r *tracedRepository) CheckPairNormalization(ctx context.Context) (_ *models.PairNormalizationReport, err error) {
+	ctx, span := r.start(ctx, "CheckPairNormalization")
+	defer func() { tracing.End(span, err) }()
+	return r.next.CheckPairNormalization(ctx)
+}
+
+func (r *tracedRepository) EnsureSearchIndex() error {
+	return r.next.EnsureSearchIndex()
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"metachat/chat-service/internal/auth"
+)
+
+func TestAnnouncementsNotifyMutedParticipants(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addGroupChat(testChatID, testUserID, testOtherID, testStrangerID)
+	repo.muted[testChatID] = map[string]bool{testOtherID: true}
+	publisher := &recordingPublisher{}
+	svc, _ := newTestService(repo, Config{Publisher: publisher, AnnouncementsNotifyMuted: true})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	if _, err := svc.SendMessage(ctx, testChatID, "", "regular", SendMessageOptions{}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	msg, err := svc.SendMessage(ctx, testChatID, "", "announcement", SendMessageOptions{Announcement: true})
+	if err != nil {
+		t.Fatalf("SendMessage(announcement) error = %v", err)
+	}
+	if !msg.Announcement {
+		t.Error("announcement not marked as one")
+	}
+
+	if len(publisher.created) != 2 {
+		t.Fatalf("published %d events, want 2", len(publisher.created))
+	}
+	regular, announcement := publisher.created[0], publisher.created[1]
+	if want := []string{testStrangerID}; !slices.Equal(regular.RecipientIDs, want) || !slices.Equal(regular.MutedRecipientIDs, []string{testOtherID}) {
+		t.Errorf("regular message notified %v, muted %v; want %v, muted %v", regular.RecipientIDs, regular.MutedRecipientIDs, want, []string{testOtherID})
+	}
+	if want := []string{testOtherID, testStrangerID}; !slices.Equal(announcement.RecipientIDs, want) || len(announcement.MutedRecipientIDs) != 0 {
+		t.Errorf("announcement notified %v, muted %v; want %v and nobody muted", announcement.RecipientIDs, announcement.MutedRecipientIDs, want)
+	}
+	if regular.Announcement || !announcement.Announcement {
+		t.Errorf("announcement flags = %v, %v; want false, true", regular.Announcement, announcement.Announcement)
+	}
+}
+
+func TestAnnouncementsRespectMutesUnlessConfigured(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addGroupChat(testChatID, testUserID, testOtherID, testStrangerID)
+	repo.muted[testChatID] = map[string]bool{testOtherID: true}
+	publisher := &recordingPublisher{}
+	svc, _ := newTestService(repo, Config{Publisher: publisher})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	if _, err := svc.SendMessage(ctx, testChatID, "", "announcement", SendMessageOptions{Announcement: true}); err != nil {
+		t.Fatalf("SendMessage(announcement) error = %v", err)
+	}
+	if got := publisher.created[0].RecipientIDs; !slices.Equal(got, []string{testStrangerID}) {
+		t.Errorf("announcement notified %v, want only %s", got, testStrangerID)
+	}
+}
+
+func TestAnnouncementsRestrictedToGroupCreator(t *testing.T) {
+	const directChatID = "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+	repo := newFakeRepository()
+	repo.addGroupChat(testChatID, testUserID, testOtherID)
+	repo.addChat(directChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+
+	tests := []struct {
+		name   string
+		chatID string
+		ctx    context.Context
+		want   error
+	}{
+		{"creator", testChatID, auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID}), nil},
+		{"other member", testChatID, auth.WithPrincipal(context.Background(), auth.Principal{UserID: testOtherID}), ErrNotChatCreator},
+		{"direct chat", directChatID, auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID}), ErrInvalidAnnouncement},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SendMessage(tt.ctx, tt.chatID, "", "announcement", SendMessageOptions{Announcement: true})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("SendMessage() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"metachat/chat-service/internal/models"
+)
+
+var ErrInvalidArchiveFilter = errors.New("archive filter must be exclude, include or only")
+
+type UserChatsOptions struct {
+	// Archived defaults to leaving archived chats out of the list.
+	Archived models.ArchiveFilter
+}
+
+// ArchiveChat hides the chat from userID's default chat list without
+// affecting other participants. The next message someone else sends brings
+// it back.
+func (s *chatService) ArchiveChat(ctx context.Context, chatID, userID string) error {
+	return s.setChatArchived(ctx, chatID, userID, true)
+}
+
+func (s *chatService) UnarchiveChat(ctx context.Context, chatID, userID string) error {
+	return s.setChatArchived(ctx, chatID, userID, false)
+}
+
+func (s *chatService) setChatArchived(ctx context.Context, chatID, userID string, archived bool) error {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repository.SetChatArchived(ctx, chatID, userID, archived); err != nil {
+		s.logger.WithError(err).Error("Failed to update chat archive state")
+		return err
+	}
+
+	return nil
+}
+
+func validateArchiveFilter(filter models.ArchiveFilter) error {
+	switch filter {
+	case "", models.ArchiveFilterExclude, models.ArchiveFilterInclude, models.ArchiveFilterOnly:
+		return nil
+	}
+	return ErrInvalidArchiveFilter
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"metachat/chat-service/internal/models"
+)
+
+func TestValidateArchiveFilter(t *testing.T) {
+	tests := []struct {
+		filter  models.ArchiveFilter
+		wantErr bool
+	}{
+		{"", false},
+		{models.ArchiveFilterExclude, false},
+		{models.ArchiveFilterInclude, false},
+		{models.ArchiveFilterOnly, false},
+		{"all", true},
+		{"EXCLUDE", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.filter), func(t *testing.T) {
+			err := validateArchiveFilter(tt.filter)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validateArchiveFilter(%q) error = %v, wantErr %v", tt.filter, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidArchiveFilter) {
+				t.Errorf("validateArchiveFilter(%q) error = %v, want ErrInvalidArchiveFilter", tt.filter, err)
+			}
+		})
+	}
+}
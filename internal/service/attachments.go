@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"metachat/chat-service/internal/models"
+)
+
+var ErrInvalidAttachment = errors.New("invalid attachment")
+
+const (
+	defaultMaxAttachmentSize = 25 << 20
+	maxAttachmentsPerMessage = 10
+	maxAttachmentFilename    = 255
+)
+
+var DefaultAttachmentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"}
+
+// validateAttachments checks descriptors against the MIME allowlist and size
+// limit. Blobs live in object storage, so only the metadata is checked here.
+func (s *chatService) validateAttachments(attachments []*models.Attachment) error {
+	if len(attachments) > maxAttachmentsPerMessage {
+		return fmt.Errorf("%w: at most %d per message", ErrInvalidAttachment, maxAttachmentsPerMessage)
+	}
+
+	maxSize := s.config.MaxAttachmentSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxAttachmentSize
+	}
+
+	for _, a := range attachments {
+		if a == nil {
+			return fmt.Errorf("%w: descriptor is empty", ErrInvalidAttachment)
+		}
+		if u, err := url.Parse(a.URL); err != nil || !u.IsAbs() || u.Host == "" {
+			return fmt.Errorf("%w: url must be absolute", ErrInvalidAttachment)
+		}
+		if !s.attachmentTypeAllowed(a.MimeType) {
+			return fmt.Errorf("%w: mime type %q is not allowed", ErrInvalidAttachment, a.MimeType)
+		}
+		if a.SizeBytes <= 0 || a.SizeBytes > maxSize {
+			return fmt.Errorf("%w: size must be between 1 and %d bytes", ErrInvalidAttachment, maxSize)
+		}
+		if len(a.Filename) > maxAttachmentFilename {
+			return fmt.Errorf("%w: filename is too long", ErrInvalidAttachment)
+		}
+	}
+	return nil
+}
+
+func (s *chatService) attachmentTypeAllowed(mimeType string) bool {
+	allowed := s.config.AllowedAttachmentTypes
+	if len(allowed) == 0 {
+		allowed = DefaultAttachmentTypes
+	}
+
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	for _, t := range allowed {
+		if strings.EqualFold(t, mimeType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *chatService) attachAttachments(ctx context.Context, messages []*models.Message) error {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	attachments, err := s.repository.GetAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		msg.Attachments = attachments[msg.ID]
+	}
+	return nil
+}
@@ -2,9 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
+	"metachat/chat-service/internal/auth"
+	"metachat/chat-service/internal/events"
 	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/ratelimit"
 	"metachat/chat-service/internal/repository"
 
 	"github.com/google/uuid"
@@ -12,40 +18,273 @@ import (
 )
 
 type ChatService interface {
-	CreateChat(ctx context.Context, userID1, userID2 string) (*models.Chat, error)
+	CreateChat(ctx context.Context, userID1, userID2 string, opts CreateChatOptions) (*models.Chat, error)
+	CreateGroupChat(ctx context.Context, creatorID string, participantIDs []string, name string, opts CreateChatOptions) (*models.Chat, error)
 	GetChat(ctx context.Context, chatID string) (*models.Chat, error)
-	GetUserChats(ctx context.Context, userID string) ([]*models.Chat, error)
-	SendMessage(ctx context.Context, chatID, senderID, content string) (*models.Message, error)
-	GetChatMessages(ctx context.Context, chatID string, limit int, beforeMessageID string) ([]*models.Message, error)
+	GetChatsByIDs(ctx context.Context, userID string, ids []string) ([]*models.Chat, error)
+	GetChatMessageCount(ctx context.Context, chatID, userID string) (int64, error)
+	GetUserChats(ctx context.Context, userID string, opts UserChatsOptions) (*models.ChatList, error)
+	ArchiveChat(ctx context.Context, chatID, userID string) error
+	UnarchiveChat(ctx context.Context, chatID, userID string) error
+	GetDirectChatsForPeers(ctx context.Context, userID string, peerIDs []string) (map[string]*models.Chat, error)
+	DeleteChat(ctx context.Context, chatID, userID string, mode DeleteChatMode) error
+	SendMessage(ctx context.Context, chatID, senderID, content string, opts SendMessageOptions) (*models.Message, error)
+	ForwardMessage(ctx context.Context, messageID, fromChatID, toChatID, senderID string) (*models.Message, error)
+	EditMessage(ctx context.Context, messageID, senderID, newContent string) (*models.Message, error)
+	GetMessageEditHistory(ctx context.Context, messageID, userID string) ([]*models.MessageEdit, error)
+	DeleteMessage(ctx context.Context, messageID, senderID string) error
+	AddReaction(ctx context.Context, messageID, userID, emoji string) error
+	RemoveReaction(ctx context.Context, messageID, userID, emoji string) error
+	ToggleReaction(ctx context.Context, messageID, userID, emoji string) (bool, error)
+	BlockUser(ctx context.Context, blockerID, blockedID string) error
+	UnblockUser(ctx context.Context, blockerID, blockedID string) error
+	IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error)
+	MuteChat(ctx context.Context, chatID, userID string, until *time.Time) error
+	PinMessage(ctx context.Context, messageID, userID string) error
+	UnpinMessage(ctx context.Context, messageID, userID string) error
+	GetPinnedMessages(ctx context.Context, chatID, userID string) ([]*models.PinnedMessage, error)
+	UnmuteChat(ctx context.Context, chatID, userID string) error
+	GetChatMessages(ctx context.Context, chatID, userID string, limit int, cursor string, includeDeleted bool) (*models.MessagePage, error)
+	GetMessage(ctx context.Context, messageID, userID string) (*models.Message, error)
+	GetThreadReplies(ctx context.Context, parentMessageID, userID string) ([]*models.Message, error)
+	SearchMessages(ctx context.Context, chatID, userID, query string, limit int) ([]*models.SearchHit, error)
+	SearchAllMessages(ctx context.Context, userID, query string, limit int, cursor string) (*models.SearchResultPage, error)
 	MarkMessagesAsRead(ctx context.Context, chatID, userID string) (int, error)
+	MarkMessagesAsDelivered(ctx context.Context, chatID, userID string) (int, error)
+	SubscribeChatEvents(ctx context.Context, chatID, userID string) (<-chan *models.ChatEvent, error)
+	SendTypingEvent(ctx context.Context, chatID, userID string) error
+	StreamTypingEvents(ctx context.Context, chatID, userID string) (<-chan *models.ChatEvent, error)
+	GetMessageReaders(ctx context.Context, messageID, userID string) ([]*models.MessageRead, error)
+	GetFirstUnreadMessage(ctx context.Context, chatID, userID string) (*models.Message, error)
+	GetChatStats(ctx context.Context, chatID, userID string) (*models.ChatStats, error)
+	GetChatPolicies(ctx context.Context, chatID, userID string) (*models.ChatPolicies, error)
+	GetMessageHistogram(ctx context.Context, chatID, userID, bucket string, from, to time.Time, fillGaps bool) ([]*models.HistogramBucket, error)
+	GetMostDuplicatedContent(ctx context.Context, limit int) ([]*models.DuplicateContent, error)
+	GetContentSpread(ctx context.Context, contentHash string) (*models.ContentSpread, error)
+	GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+	WaitForPendingWrites(ctx context.Context) error
+}
+
+const (
+	maxPeerLookup         = 200
+	defaultChatListMaxLen = 500
+	maxHistogramBuckets   = 1000
+
+	defaultMaxChatBatchSize = 100
+
+	defaultRateLimitWarnRatio = 0.2
+)
+
+const (
+	DuplicateIDPolicyRetry  = "retry"
+	DuplicateIDPolicyReject = "reject"
+)
+
+var (
+	ErrNotParticipant      = errors.New("user is not a participant in this chat")
+	ErrNotMessageSender    = errors.New("user is not the sender of this message")
+	ErrNotChatCreator      = errors.New("user is not the creator of this chat")
+	ErrInvalidAnnouncement = errors.New("invalid announcement")
+	ErrLanguageNotAllowed  = errors.New("message language is not allowed")
+	ErrUnknownChatSource   = errors.New("unknown chat source")
+	ErrChatQuotaExceeded   = repository.ErrChatQuotaExceeded
+	ErrInvalidChatType     = errors.New("invalid chat type for participants")
+	ErrInvalidBucket       = errors.New("histogram bucket must be hour, day or week")
+	ErrInvalidTimeRange    = errors.New("invalid time range")
+	ErrInvalidDeleteMode   = errors.New("invalid chat delete mode")
+	ErrRateLimited         = errors.New("message rate limit exceeded")
+	ErrTooFewParticipants  = errors.New("a chat needs at least two distinct participants")
+	ErrBatchTooLarge       = errors.New("batch is too large")
+	ErrServiceOnly         = errors.New("only a trusted service may do this")
+)
+
+var histogramBuckets = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+	"week": 7 * 24 * time.Hour,
+}
+
+const (
+	ChatSourceUnknown = "unknown"
+
+	UnknownSourcePolicyReject = "reject"
+	UnknownSourcePolicyStore  = "store_unknown"
+)
+
+var DefaultChatSources = []string{"from_profile", "from_group", "support"}
+
+type Config struct {
+	ContentHashing     bool
+	DuplicateIDPolicy  string
+	LanguageDetection  string
+	AllowedLanguages   []string
+	MaxMessagesPerChat int64
+	MaxMessageLength   int
+	JoinMarkers        bool
+	// LengthMode is how content length is counted against
+	// MaxMessageLength; see the LengthMode constants. Runes by default.
+	LengthMode string
+	// OversizePolicy is what happens to content over MaxMessageLength; see
+	// the OversizePolicy constants. Over-long content is rejected by
+	// default. TruncationMarker ends truncated content, "…" if empty.
+	OversizePolicy   string
+	TruncationMarker string
+	// MaxPinsPerChat caps pinned messages per chat; zero means unlimited.
+	MaxPinsPerChat int
+	// MaxReplyDepth caps how deep reply chains go, a reply to a message
+	// that replies to nothing being one deep; zero means unlimited.
+	MaxReplyDepth int
+	// AnnouncementsNotifyMuted sends announcements to participants who
+	// muted the chat as well.
+	AnnouncementsNotifyMuted bool
+	// EditHistoryVisibility is who may read a message's earlier versions:
+	// EditHistoryParticipants (the default) or EditHistoryAdmin.
+	EditHistoryVisibility string
+	// ReactionsOnDelete is what deleting a message does to its reactions:
+	// ReactionsOnDeleteClear (the default) removes them along with the
+	// message, ReactionsOnDeleteKeep leaves them in place.
+	ReactionsOnDelete string
+
+	AllowedAttachmentTypes []string
+	MaxAttachmentSize      int64
+
+	// DefaultWriteConcern applies when a send doesn't ask for one; fast
+	// acks are only honoured when AllowFastWrites is set and otherwise
+	// fall back to durable.
+	DefaultWriteConcern WriteConcern
+	AllowFastWrites     bool
+
+	AllowedChatSources  []string
+	UnknownSourcePolicy string
+	IncludeEmptyChats   bool
+	HideBlockedChats    bool
+	ChatListHardCap     int
+	MaxChatBatchSize    int
+
+	StreamBufferSize int
+	TypingTTL        time.Duration
+	// SlowConsumerPolicy is what happens when a stream subscriber's buffer
+	// is full; see the SlowConsumer constants. Such subscribers are
+	// disconnected by default. SlowConsumerTimeout bounds the wait under
+	// SlowConsumerBlock, one second if unset.
+	SlowConsumerPolicy  string
+	SlowConsumerTimeout time.Duration
+	// FanOutWorkers is how many workers deliver stream events, four if
+	// unset. Events of one chat always go through the same worker.
+	FanOutWorkers int
+
+	// RateLimiter throttles SendMessage per sender; nil disables limiting.
+	// Sends that leave the sender with RateLimitWarnRatio or less of the
+	// limit carry a warning; zero means the default of 20%.
+	RateLimiter        ratelimit.Limiter
+	RateLimitWarnRatio float64
+	// Publisher forwards committed messages to other services; nil means
+	// events stay in-process.
+	Publisher events.Publisher
+}
+
+type CreateChatOptions struct {
+	Source string
+	// Type overrides the chat type otherwise inferred from the number of
+	// distinct participants (two for direct, more for group).
+	Type models.ChatType
+}
+
+// DeleteChatMode selects between removing a chat for everyone and hiding it
+// only from the caller's chat list.
+type DeleteChatMode string
+
+const (
+	DeleteChatForMe DeleteChatMode = "for_me"
+	DeleteChatHard  DeleteChatMode = "hard"
+)
+
+// WriteConcern controls when SendMessage acknowledges: WriteConcernDurable
+// returns once the insert has committed and the event is published,
+// WriteConcernFast returns as soon as the message is validated and persists
+// it in the background, so a failed write is only logged.
+type WriteConcern string
+
+const (
+	WriteConcernDurable WriteConcern = "durable"
+	WriteConcernFast    WriteConcern = "fast"
+)
+
+type SendMessageOptions struct {
+	MarkRead     bool
+	WriteConcern WriteConcern
+	Attachments  []*models.Attachment
+	ReplyToID    string
+	// Announcement posts the message as an announcement, which only the
+	// creator of a group chat, or a trusted service, may do.
+	Announcement bool
 }
 
 type chatService struct {
 	repository repository.ChatRepository
+	config     Config
+	hub        *eventHub
 	logger     *logrus.Logger
+
+	// pendingWrites tracks fast-write-concern messages that were acknowledged
+	// before being stored.
+	pendingWrites sync.WaitGroup
 }
 
-func NewChatService(repo repository.ChatRepository, logger *logrus.Logger) ChatService {
+func NewChatService(repo repository.ChatRepository, cfg Config, logger *logrus.Logger) ChatService {
 	return &chatService{
 		repository: repo,
+		config:     cfg,
+		hub:        newEventHub(cfg, logger),
 		logger:     logger,
 	}
 }
 
-func (s *chatService) CreateChat(ctx context.Context, userID1, userID2 string) (*models.Chat, error) {
+func (s *chatService) CreateChat(ctx context.Context, userID1, userID2 string, opts CreateChatOptions) (*models.Chat, error) {
+	userID1 = identity(ctx, userID1)
+
+	if err := validateIDs(idField{"user_id1", userID1}, idField{"user_id2", userID2}); err != nil {
+		return nil, err
+	}
+
+	// Differently cased spellings of one UUID are the same user, and must
+	// find the same stored pair.
+	userID1, userID2 = canonicalID(userID1), canonicalID(userID2)
 	if userID1 == userID2 {
-		return nil, fmt.Errorf("cannot create chat with yourself")
+		return nil, ErrTooFewParticipants
+	}
+
+	if opts.Type == models.ChatTypeGroup {
+		return s.createGroupChat(ctx, userID1, []string{userID1, userID2}, "", opts)
+	}
+	if opts.Type != "" && opts.Type != models.ChatTypeDirect {
+		return nil, ErrInvalidChatType
+	}
+
+	if err := s.checkNotBlocked(ctx, &models.Chat{ParticipantIDs: []string{userID1, userID2}}, userID1); err != nil {
+		return nil, err
+	}
+
+	source, err := s.resolveChatSource(opts.Source)
+	if err != nil {
+		return nil, err
 	}
 
 	existingChat, err := s.repository.GetChatByUsers(ctx, userID1, userID2)
 	if err == nil && existingChat != nil {
 		return existingChat, nil
 	}
+	if err != nil && !errors.Is(err, repository.ErrChatNotFound) {
+		s.logger.WithError(err).Error("Failed to look up existing chat")
+		return nil, err
+	}
 
 	chat := &models.Chat{
-		ID:      uuid.New().String(),
-		UserID1: userID1,
-		UserID2: userID2,
+		ID:        uuid.New().String(),
+		UserID1:   userID1,
+		UserID2:   userID2,
+		CreatedBy: userID1,
+		Source:    source,
 	}
 
 	err = s.repository.CreateChat(ctx, chat)
@@ -63,91 +302,1194 @@ func (s *chatService) CreateChat(ctx context.Context, userID1, userID2 string) (
 	return chat, nil
 }
 
+func (s *chatService) CreateGroupChat(ctx context.Context, creatorID string, participantIDs []string, name string, opts CreateChatOptions) (*models.Chat, error) {
+	creatorID = identity(ctx, creatorID)
+
+	if err := validateIDs(idField{"creator_id", creatorID}); err != nil {
+		return nil, err
+	}
+	if err := validateIDList("participant_id", participantIDs); err != nil {
+		return nil, err
+	}
+
+	// The creator always comes first and appears once, however often (or
+	// whether) the request lists them.
+	participants := distinctIDs(append([]string{creatorID}, participantIDs...))
+	if len(participants) < 2 {
+		return nil, ErrTooFewParticipants
+	}
+
+	chatType := opts.Type
+	if chatType == "" {
+		chatType = inferChatType(participants)
+	}
+
+	switch chatType {
+	case models.ChatTypeDirect:
+		if len(participants) != 2 {
+			return nil, ErrInvalidChatType
+		}
+		return s.CreateChat(ctx, participants[0], participants[1], CreateChatOptions{Source: opts.Source})
+	case models.ChatTypeGroup:
+		return s.createGroupChat(ctx, participants[0], participants, name, opts)
+	default:
+		return nil, ErrInvalidChatType
+	}
+}
+
+func (s *chatService) createGroupChat(ctx context.Context, creatorID string, participants []string, name string, opts CreateChatOptions) (*models.Chat, error) {
+	source, err := s.resolveChatSource(opts.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	chat := &models.Chat{
+		ID:             uuid.New().String(),
+		Name:           name,
+		CreatedBy:      creatorID,
+		Source:         source,
+		ParticipantIDs: participants,
+	}
+
+	if err := s.repository.CreateGroupChat(ctx, chat); err != nil {
+		s.logger.WithError(err).Error("Failed to create group chat")
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"chat_id":      chat.ID,
+		"creator_id":   creatorID,
+		"participants": len(participants),
+	}).Info("Group chat created")
+
+	return chat, nil
+}
+
+func inferChatType(participants []string) models.ChatType {
+	if len(participants) == 2 {
+		return models.ChatTypeDirect
+	}
+	return models.ChatTypeGroup
+}
+
+// distinctIDs drops repeated IDs, keeping first-seen order. IDs are compared
+// in canonical UUID form so differently cased spellings collapse too; callers
+// validate the IDs first.
+func distinctIDs(ids []string) []string {
+	seen := make(map[string]struct{}, len(ids))
+	distinct := make([]string, 0, len(ids))
+	for _, id := range ids {
+		id = canonicalID(id)
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		distinct = append(distinct, id)
+	}
+	return distinct
+}
+
 func (s *chatService) GetChat(ctx context.Context, chatID string) (*models.Chat, error) {
+	if err := validateIDs(idField{"chat_id", chatID}); err != nil {
+		return nil, err
+	}
+
 	chat, err := s.repository.GetChatByID(ctx, chatID)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to get chat")
 		return nil, err
 	}
 
+	// Authenticated users only see chats they take part in; trusted services
+	// and unauthenticated deployments can read any chat.
+	if userID := viewerID(ctx, ""); userID != "" {
+		if !chat.HasParticipant(userID) {
+			return nil, ErrNotParticipant
+		}
+
+		chat.IsCreator = chat.CreatedBy == userID
+		if chat.LastRead, err = s.repository.GetLastRead(ctx, chatID, userID); err != nil {
+			s.logger.WithError(err).Error("Failed to get last read position")
+			return nil, err
+		}
+	}
+
 	return chat, nil
 }
 
-func (s *chatService) GetUserChats(ctx context.Context, userID string) ([]*models.Chat, error) {
-	chats, err := s.repository.GetUserChats(ctx, userID)
+// GetChatMessageCount returns how many undeleted messages the chat has. It
+// reads the chat's message_count, which sends and deletes keep current, so
+// busy chats are never counted row by row.
+func (s *chatService) GetChatMessageCount(ctx context.Context, chatID, userID string) (int64, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return 0, err
+	}
+
+	chat, err := s.getParticipantChat(ctx, chatID, userID)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get user chats")
+		return 0, err
+	}
+
+	return chat.MessageCount, nil
+}
+
+// GetChatsByIDs fetches several of the caller's chats in one query, in the
+// order requested. Unknown IDs and chats the caller isn't in are left out
+// rather than failing the batch. The requested GetChats RPC is blocked on a
+// metachat-proto release: v0.2.2 has no such method.
+func (s *chatService) GetChatsByIDs(ctx context.Context, userID string, ids []string) ([]*models.Chat, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+	if err := validateIDList("chat_id", ids); err != nil {
+		return nil, err
+	}
+
+	ids = distinctIDs(ids)
+
+	maxBatch := s.config.MaxChatBatchSize
+	if maxBatch <= 0 {
+		maxBatch = defaultMaxChatBatchSize
+	}
+	if len(ids) > maxBatch {
+		return nil, fmt.Errorf("%w: at most %d chats per request", ErrBatchTooLarge, maxBatch)
+	}
+
+	chats, err := s.repository.GetChatsByIDs(ctx, userID, ids)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get chats")
 		return nil, err
 	}
 
 	return chats, nil
 }
 
-func (s *chatService) SendMessage(ctx context.Context, chatID, senderID, content string) (*models.Message, error) {
+func (s *chatService) resolveChatSource(source string) (string, error) {
+	if source == "" {
+		return "", nil
+	}
+
+	allowed := s.config.AllowedChatSources
+	if len(allowed) == 0 {
+		allowed = DefaultChatSources
+	}
+	for _, known := range allowed {
+		if known == source {
+			return source, nil
+		}
+	}
+
+	if s.config.UnknownSourcePolicy == UnknownSourcePolicyStore {
+		return ChatSourceUnknown, nil
+	}
+	return "", ErrUnknownChatSource
+}
+
+// identity resolves the acting user of a call. The authenticated
+// user from the context wins over an ID supplied in the request body, except
+// for trusted service callers acting on behalf of the requested user. Without
+// authentication the requested ID is used as is.
+func identity(ctx context.Context, requested string) string {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return requested
+	}
+	if principal.Service && requested != "" {
+		return requested
+	}
+	return principal.UserID
+}
+
+func isServiceCaller(ctx context.Context) bool {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	return ok && principal.Service
+}
+
+// viewerID resolves the user a read is made on behalf of, like identity. It
+// is empty when there is nobody to check access for: an unauthenticated call
+// that names no user, or a trusted service reading on its own behalf.
+func viewerID(ctx context.Context, requested string) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && principal.Service && requested == "" {
+		return ""
+	}
+	return identity(ctx, requested)
+}
+
+func (s *chatService) getParticipantChat(ctx context.Context, chatID, userID string) (*models.Chat, error) {
 	chat, err := s.repository.GetChatByID(ctx, chatID)
 	if err != nil {
-		return nil, fmt.Errorf("chat not found")
+		if !errors.Is(err, repository.ErrChatNotFound) {
+			s.logger.WithError(err).Error("Failed to look up chat")
+		}
+		return nil, err
 	}
 
-	if chat.UserID1 != senderID && chat.UserID2 != senderID {
-		return nil, fmt.Errorf("user is not a participant in this chat")
+	if !chat.HasParticipant(userID) {
+		return nil, ErrNotParticipant
 	}
 
-	msg := &models.Message{
-		ID:      uuid.New().String(),
-		ChatID:  chatID,
-		SenderID: senderID,
-		Content: content,
+	return chat, nil
+}
+
+// GetUserChats returns the user's chats up to the configured hard cap. When the
+// user has more chats than that, the list is cut off and marked Truncated so
+// unpaginated callers cannot trigger unbounded responses.
+func (s *chatService) GetUserChats(ctx context.Context, userID string, opts UserChatsOptions) (*models.ChatList, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+	if err := validateArchiveFilter(opts.Archived); err != nil {
+		return nil, err
+	}
+
+	hardCap := s.config.ChatListHardCap
+	if hardCap <= 0 {
+		hardCap = defaultChatListMaxLen
+	}
+
+	filter := repository.UserChatsFilter{
+		IncludeEmpty:   s.config.IncludeEmptyChats,
+		ExcludeBlocked: s.config.HideBlockedChats,
+		Archived:       opts.Archived,
+		Limit:          hardCap + 1,
 	}
 
-	err = s.repository.CreateMessage(ctx, msg)
+	chats, err := s.repository.GetUserChats(ctx, userID, filter)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to send message")
+		s.logger.WithError(err).Error("Failed to get user chats")
 		return nil, err
 	}
 
-	s.logger.WithFields(logrus.Fields{
-		"message_id": msg.ID,
-		"chat_id":    chatID,
-		"sender_id":  senderID,
-	}).Info("Message sent")
+	list := &models.ChatList{Chats: chats}
+	if len(chats) > hardCap {
+		list.Chats = chats[:hardCap]
+		list.Truncated = true
+		s.logger.WithFields(logrus.Fields{
+			"user_id":  userID,
+			"hard_cap": hardCap,
+		}).Warn("User chat list truncated at hard cap")
+	}
 
-	return msg, nil
+	for _, chat := range list.Chats {
+		chat.IsCreator = chat.CreatedBy == userID
+	}
+
+	return list, nil
 }
 
-func (s *chatService) GetChatMessages(ctx context.Context, chatID string, limit int, beforeMessageID string) ([]*models.Message, error) {
-	if limit <= 0 {
-		limit = 50
+func (s *chatService) GetDirectChatsForPeers(ctx context.Context, userID string, peerIDs []string) (map[string]*models.Chat, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"user_id", userID}); err != nil {
+		return nil, err
 	}
-	if limit > 100 {
-		limit = 100
+	if err := validateIDList("peer_id", peerIDs); err != nil {
+		return nil, err
+	}
+
+	if len(peerIDs) > maxPeerLookup {
+		return nil, fmt.Errorf("%w: at most %d peer ids per request", ErrBatchTooLarge, maxPeerLookup)
+	}
+
+	// The result is keyed by canonical ID, matching the IDs stored on chats.
+	userID = canonicalID(userID)
+	result := make(map[string]*models.Chat, len(peerIDs))
+	peers := make([]string, 0, len(peerIDs))
+	for _, peerID := range distinctIDs(peerIDs) {
+		if peerID == userID {
+			continue
+		}
+		result[peerID] = nil
+		peers = append(peers, peerID)
+	}
+
+	if len(peers) == 0 {
+		return result, nil
 	}
 
-	messages, err := s.repository.GetChatMessages(ctx, chatID, limit, beforeMessageID)
+	chats, err := s.repository.GetChatsWithPeers(ctx, userID, peers)
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to get chat messages")
+		s.logger.WithError(err).Error("Failed to get chats for peers")
 		return nil, err
 	}
 
-	return messages, nil
+	for _, chat := range chats {
+		peerID := chat.UserID1
+		if peerID == userID {
+			peerID = chat.UserID2
+		}
+		result[peerID] = chat
+	}
+
+	return result, nil
 }
 
-func (s *chatService) MarkMessagesAsRead(ctx context.Context, chatID, userID string) (int, error) {
-	chat, err := s.repository.GetChatByID(ctx, chatID)
+// DeleteChat hard-deletes the chat with all its messages, or with
+// DeleteChatForMe (the default) only hides it for userID until a newer
+// message arrives, leaving other participants' copies intact.
+func (s *chatService) DeleteChat(ctx context.Context, chatID, userID string, mode DeleteChatMode) error {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return err
+	}
+
+	if mode == "" {
+		mode = DeleteChatForMe
+	}
+	if mode != DeleteChatForMe && mode != DeleteChatHard {
+		return ErrInvalidDeleteMode
+	}
+
+	chat, err := s.getParticipantChat(ctx, chatID, userID)
 	if err != nil {
-		return 0, fmt.Errorf("chat not found")
+		return err
 	}
 
-	if chat.UserID1 != userID && chat.UserID2 != userID {
-		return 0, fmt.Errorf("user is not a participant in this chat")
+	// Deleting a group for everyone is reserved for whoever created it, or a
+	// trusted service acting on a participant's behalf.
+	if mode == DeleteChatHard && chat.Type == models.ChatTypeGroup && chat.CreatedBy != userID && !isServiceCaller(ctx) {
+		return ErrNotChatCreator
 	}
 
-	count, err := s.repository.MarkMessagesAsRead(ctx, chatID, userID)
+	if mode == DeleteChatHard {
+		err = s.repository.DeleteChat(ctx, chatID, userID)
+	} else {
+		err = s.repository.HideChat(ctx, chatID, userID)
+	}
 	if err != nil {
-		s.logger.WithError(err).Error("Failed to mark messages as read")
-		return 0, err
+		if !errors.Is(err, repository.ErrChatNotFound) {
+			s.logger.WithError(err).Error("Failed to delete chat")
+		}
+		return err
 	}
 
-	return count, nil
+	s.logger.WithFields(logrus.Fields{
+		"chat_id": chatID,
+		"user_id": userID,
+		"mode":    mode,
+	}).Info("Chat deleted")
+
+	if mode == DeleteChatHard {
+		s.hub.publish(&models.ChatEvent{
+			Type:   models.ChatEventChatDeleted,
+			ChatID: chatID,
+		})
+	}
+
+	return nil
+}
+
+func (s *chatService) SendMessage(ctx context.Context, chatID, senderID, content string, opts SendMessageOptions) (*models.Message, error) {
+	senderID = identity(ctx, senderID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"sender_id", senderID}); err != nil {
+		return nil, err
+	}
+
+	rateLimit, err := s.checkRateLimit(ctx, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	chat, err := s.getParticipantChat(ctx, chatID, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkNotBlocked(ctx, chat, senderID); err != nil {
+		return nil, err
+	}
+
+	if opts.Announcement {
+		if chat.Type != models.ChatTypeGroup {
+			return nil, fmt.Errorf("%w: only group chats have announcements", ErrInvalidAnnouncement)
+		}
+		if chat.CreatedBy != senderID && !isServiceCaller(ctx) {
+			return nil, ErrNotChatCreator
+		}
+	}
+
+	// The repository enforces the quota; checking here as well keeps fast
+	// writes from acknowledging messages that are bound to be rejected.
+	if s.config.MaxMessagesPerChat > 0 && chat.MessageCount >= s.config.MaxMessagesPerChat {
+		return nil, ErrChatQuotaExceeded
+	}
+
+	msg := &models.Message{
+		ID:           uuid.New().String(),
+		ChatID:       chatID,
+		SenderID:     senderID,
+		Announcement: opts.Announcement,
+		RateLimit:    rateLimit,
+	}
+
+	if err := s.applyContent(msg, content); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateAttachments(opts.Attachments); err != nil {
+		return nil, err
+	}
+	msg.Attachments = opts.Attachments
+
+	if opts.ReplyToID != "" {
+		if err := s.resolveReplyTo(ctx, chatID, opts.ReplyToID); err != nil {
+			return nil, err
+		}
+		msg.ReplyToID = opts.ReplyToID
+	}
+
+	if s.writeConcern(opts.WriteConcern) == WriteConcernFast {
+		msg.CreatedAt = time.Now().UTC()
+		ack := *msg
+		s.pendingWrites.Add(1)
+		go func() {
+			defer s.pendingWrites.Done()
+			s.persistMessage(context.WithoutCancel(ctx), chat, msg, opts, true)
+		}()
+		return &ack, nil
+	}
+
+	if err := s.persistMessage(ctx, chat, msg, opts, false); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// WaitForPendingWrites blocks until every acknowledged fast write has been
+// stored and published, or ctx is done.
+func (s *chatService) WaitForPendingWrites(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pendingWrites.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *chatService) writeConcern(requested WriteConcern) WriteConcern {
+	concern := requested
+	if concern == "" {
+		concern = s.config.DefaultWriteConcern
+	}
+	if concern == WriteConcernFast && s.config.AllowFastWrites {
+		return WriteConcernFast
+	}
+	return WriteConcernDurable
+}
+
+// persistMessage stores msg, retrying once on an ID collision unless the
+// policy rejects duplicates, and publishes it to stream subscribers and the
+// external event publisher. An acked message has already been returned to
+// the client, so its ID is never replaced.
+func (s *chatService) persistMessage(ctx context.Context, chat *models.Chat, msg *models.Message, opts SendMessageOptions, acked bool) error {
+	err := s.createMessage(ctx, msg, opts)
+	if errors.Is(err, repository.ErrDuplicateMessageID) && !acked && s.config.DuplicateIDPolicy != DuplicateIDPolicyReject {
+		s.logger.WithField("message_id", msg.ID).Warn("Message ID collision, retrying with a new ID")
+		msg.ID = uuid.New().String()
+		err = s.createMessage(ctx, msg, opts)
+	}
+	if err != nil {
+		if acked {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"message_id": msg.ID,
+				"chat_id":    msg.ChatID,
+			}).Error("Failed to store acknowledged message")
+			return err
+		}
+		if !errors.Is(err, ErrChatQuotaExceeded) {
+			s.logger.WithError(err).Error("Failed to send message")
+		}
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": msg.ID,
+		"chat_id":    msg.ChatID,
+		"sender_id":  msg.SenderID,
+	}).Info("Message sent")
+
+	s.hub.publish(&models.ChatEvent{
+		Type:    models.ChatEventMessageCreated,
+		ChatID:  msg.ChatID,
+		Message: msg,
+	})
+
+	s.publishMessageCreated(ctx, chat, msg)
+
+	return nil
+}
+
+// publishMessageCreated notifies other services of a committed message. The
+// message is already stored, so failures are logged and not returned.
+func (s *chatService) publishMessageCreated(ctx context.Context, chat *models.Chat, msg *models.Message) {
+	if s.config.Publisher == nil {
+		return
+	}
+
+	recipients := make([]string, 0, len(chat.ParticipantIDs))
+	for _, id := range chat.ParticipantIDs {
+		if id != msg.SenderID {
+			recipients = append(recipients, id)
+		}
+	}
+
+	// Announcements can be set to override mutes, so then there is nobody
+	// to look up. If the lookup fails, err on the side of notifying
+	// everyone.
+	var muted []string
+	if !msg.Announcement || !s.config.AnnouncementsNotifyMuted {
+		var err error
+		muted, err = s.repository.GetMutedUserIDs(ctx, msg.ChatID, recipients)
+		if err != nil {
+			s.logger.WithError(err).WithField("chat_id", msg.ChatID).Warn("Failed to look up muted recipients")
+		}
+		recipients = withoutIDs(recipients, muted)
+	}
+
+	err := s.config.Publisher.PublishMessageCreated(ctx, &events.MessageCreated{
+		MessageID:         msg.ID,
+		ChatID:            msg.ChatID,
+		SenderID:          msg.SenderID,
+		RecipientIDs:      recipients,
+		MutedRecipientIDs: muted,
+		CreatedAt:         msg.CreatedAt,
+		Announcement:      msg.Announcement,
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("message_id", msg.ID).Error("Failed to publish message created event")
+	}
 }
 
+func (s *chatService) EditMessage(ctx context.Context, messageID, senderID, newContent string) (*models.Message, error) {
+	senderID = identity(ctx, senderID)
+
+	if err := validateIDs(idField{"message_id", messageID}, idField{"sender_id", senderID}); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.getSenderMessage(ctx, messageID, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyContent(msg, newContent); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.UpdateMessageContent(ctx, msg); err != nil {
+		s.logger.WithError(err).Error("Failed to edit message")
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": msg.ID,
+		"chat_id":    msg.ChatID,
+		"sender_id":  senderID,
+	}).Info("Message edited")
+
+	s.hub.publish(&models.ChatEvent{
+		Type:    models.ChatEventMessageEdited,
+		ChatID:  msg.ChatID,
+		Message: msg,
+	})
+
+	return msg, nil
+}
+
+func (s *chatService) DeleteMessage(ctx context.Context, messageID, senderID string) error {
+	senderID = identity(ctx, senderID)
+
+	if err := validateIDs(idField{"message_id", messageID}, idField{"sender_id", senderID}); err != nil {
+		return err
+	}
+
+	msg, err := s.getSenderMessage(ctx, messageID, senderID)
+	if err != nil {
+		return err
+	}
+
+	clearReactions := s.config.ReactionsOnDelete != ReactionsOnDeleteKeep
+	deletedAt, cleared, err := s.repository.SoftDeleteMessage(ctx, messageID, clearReactions)
+	if err != nil {
+		if !errors.Is(err, repository.ErrMessageNotFound) {
+			s.logger.WithError(err).Error("Failed to delete message")
+		}
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": messageID,
+		"chat_id":    msg.ChatID,
+		"sender_id":  senderID,
+	}).Info("Message deleted")
+
+	// Only identify the message: its content was removed on purpose and
+	// must not reach subscribers.
+	s.hub.publish(&models.ChatEvent{
+		Type:   models.ChatEventMessageDeleted,
+		ChatID: msg.ChatID,
+		Message: &models.Message{
+			ID:        msg.ID,
+			ChatID:    msg.ChatID,
+			DeletedAt: &deletedAt,
+		},
+	})
+	for _, reaction := range cleared {
+		s.publishReaction(models.ChatEventReactionRemoved, msg, reaction.UserID, reaction.Emoji)
+	}
+
+	return nil
+}
+
+// checkRateLimit applies the configured limiter to senderID and reports what
+// the sender has left, warning once that is at or below the warn ratio of
+// the limit. Limiter failures (e.g. an unreachable backing store) are logged
+// and let the message through rather than blocking all sends.
+func (s *chatService) checkRateLimit(ctx context.Context, senderID string) (*models.RateLimitStatus, error) {
+	if s.config.RateLimiter == nil {
+		return nil, nil
+	}
+
+	decision, err := s.config.RateLimiter.Take(ctx, senderID)
+	if err != nil {
+		s.logger.WithError(err).Warn("Rate limiter unavailable, allowing message")
+		return nil, nil
+	}
+	if !decision.Allowed {
+		return nil, ErrRateLimited
+	}
+
+	warnRatio := s.config.RateLimitWarnRatio
+	if warnRatio <= 0 {
+		warnRatio = defaultRateLimitWarnRatio
+	}
+	return &models.RateLimitStatus{
+		Remaining: decision.Remaining,
+		Limit:     decision.Limit,
+		Warning:   float64(decision.Remaining) <= warnRatio*float64(decision.Limit),
+	}, nil
+}
+
+func (s *chatService) getSenderMessage(ctx context.Context, messageID, senderID string) (*models.Message, error) {
+	msg, err := s.getVisibleMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.SenderID != senderID {
+		return nil, ErrNotMessageSender
+	}
+
+	return msg, nil
+}
+
+// GetMessage returns a single message, with its reply preview, to a
+// participant of the chat it belongs to.
+func (s *chatService) GetMessage(ctx context.Context, messageID, userID string) (*models.Message, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"message_id", messageID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.getVisibleMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, msg.ChatID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.attachReplyPreviews(ctx, []*models.Message{msg}); err != nil {
+		s.logger.WithError(err).Error("Failed to get reply previews")
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// getVisibleMessage looks up a message, treating soft-deleted ones as missing.
+func (s *chatService) getVisibleMessage(ctx context.Context, messageID string) (*models.Message, error) {
+	msg, err := s.repository.GetMessageByID(ctx, messageID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrMessageNotFound) {
+			s.logger.WithError(err).Error("Failed to look up message")
+		}
+		return nil, err
+	}
+
+	if msg.DeletedAt != nil {
+		return nil, repository.ErrMessageNotFound
+	}
+
+	return msg, nil
+}
+
+// applyContent validates and sets the message content along with the derived
+// content hash and language, rejecting languages outside the allowlist.
+func (s *chatService) applyContent(msg *models.Message, content string) error {
+	content = s.fitContent(content)
+	if err := s.validateContent(content); err != nil {
+		return err
+	}
+
+	msg.Content = content
+	msg.ContentHash = ""
+	msg.Language = ""
+
+	if s.config.ContentHashing {
+		msg.ContentHash = hashContent(content)
+	}
+
+	if s.config.LanguageDetection == LanguageDetectionTag || s.config.LanguageDetection == LanguageDetectionReject {
+		msg.Language = detectLanguage(content)
+		if s.config.LanguageDetection == LanguageDetectionReject && !s.languageAllowed(msg.Language) {
+			return ErrLanguageNotAllowed
+		}
+	}
+
+	return nil
+}
+
+func (s *chatService) createMessage(ctx context.Context, msg *models.Message, opts SendMessageOptions) error {
+	if !opts.MarkRead {
+		return s.repository.CreateMessage(ctx, msg, s.config.MaxMessagesPerChat)
+	}
+
+	count, err := s.repository.CreateMessageMarkingRead(ctx, msg, s.config.MaxMessagesPerChat)
+	if err != nil {
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"chat_id":      msg.ChatID,
+		"user_id":      msg.SenderID,
+		"marked_count": count,
+	}).Debug("Marked messages as read on send")
+
+	return nil
+}
+
+// GetChatMessages returns a page of messages in chronological order. cursor is
+// either an opaque token from a previous page's NextCursor or PrevCursor or,
+// for older clients, the ID of the message to page back from.
+func (s *chatService) GetChatMessages(ctx context.Context, chatID, userID string, limit int, cursor string, includeDeleted bool) (*models.MessagePage, error) {
+	if err := validateIDs(idField{"chat_id", chatID}); err != nil {
+		return nil, err
+	}
+
+	if userID = viewerID(ctx, userID); userID != "" {
+		if err := validateIDs(idField{"user_id", userID}); err != nil {
+			return nil, err
+		}
+		if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	position, err := s.resolveCursor(ctx, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	messages, err := s.repository.GetChatMessages(ctx, chatID, limit+1, position, includeDeleted)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get chat messages")
+		return nil, err
+	}
+
+	page, before := pageMessages(messages, limit, position)
+
+	if err := s.attachReactions(ctx, page.Messages, userID); err != nil {
+		s.logger.WithError(err).Error("Failed to get message reactions")
+		return nil, err
+	}
+
+	if err := s.attachAttachments(ctx, page.Messages); err != nil {
+		s.logger.WithError(err).Error("Failed to get message attachments")
+		return nil, err
+	}
+
+	if err := s.attachReplyPreviews(ctx, page.Messages); err != nil {
+		s.logger.WithError(err).Error("Failed to get reply previews")
+		return nil, err
+	}
+
+	if s.config.JoinMarkers {
+		if page.Joins, err = s.pageJoins(ctx, chatID, page, before); err != nil {
+			s.logger.WithError(err).Error("Failed to get participant joins")
+			return nil, err
+		}
+	}
+
+	return page, nil
+}
+
+// pageMessages cuts the limit+1 messages fetched from position down to a
+// page and sets its cursors. A page fetched back from a position has newer
+// messages, from the position on, and one fetched forward has older ones;
+// the extra message tells whether there are more in the fetch direction.
+// It also returns the position the page ends before, nil for the newest
+// page.
+func pageMessages(messages []*models.Message, limit int, position *models.MessageCursor) (*models.MessagePage, *models.MessageCursor) {
+	page := &models.MessagePage{Messages: messages}
+	before := position
+	switch {
+	case position != nil && position.After:
+		before = nil
+		if len(messages) > limit {
+			page.Messages = messages[:limit]
+			next := messages[limit]
+			before = &models.MessageCursor{CreatedAt: next.CreatedAt, ID: next.ID}
+			page.PrevCursor = encodeAfterCursor(page.Messages[limit-1])
+		}
+		if len(page.Messages) > 0 {
+			page.NextCursor = encodeCursor(page.Messages[0])
+		}
+	default:
+		if len(messages) > limit {
+			page.Messages = messages[1:]
+			page.NextCursor = encodeCursor(page.Messages[0])
+		}
+		if position != nil && len(page.Messages) > 0 {
+			page.PrevCursor = encodeAfterCursor(page.Messages[len(page.Messages)-1])
+		}
+	}
+	return page, before
+}
+
+// pageJoins returns the group chat joins that fall within the page's span:
+// from its oldest message (or the beginning, on the last page) up to the
+// cursor it was fetched before (or now, on the first page). Consecutive pages
+// therefore never repeat a join.
+func (s *chatService) pageJoins(ctx context.Context, chatID string, page *models.MessagePage, before *models.MessageCursor) ([]*models.Participant, error) {
+	chat, err := s.repository.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Type != models.ChatTypeGroup {
+		return nil, nil
+	}
+
+	participants, err := s.repository.GetChatParticipants(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	var joins []*models.Participant
+	for _, p := range participants {
+		if page.NextCursor != "" && p.JoinedAt.Before(page.Messages[0].CreatedAt) {
+			continue
+		}
+		if before != nil && !p.JoinedAt.Before(before.CreatedAt) {
+			continue
+		}
+		joins = append(joins, p)
+	}
+
+	return joins, nil
+}
+
+func (s *chatService) resolveCursor(ctx context.Context, cursor string) (*models.MessageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	if _, err := uuid.Parse(cursor); err != nil {
+		return decodeCursor(cursor)
+	}
+
+	msg, err := s.repository.GetMessageByID(ctx, cursor)
+	if err != nil {
+		if errors.Is(err, repository.ErrMessageNotFound) {
+			return nil, ErrInvalidCursor
+		}
+		s.logger.WithError(err).Error("Failed to resolve message cursor")
+		return nil, err
+	}
+
+	return &models.MessageCursor{CreatedAt: msg.CreatedAt, ID: msg.ID}, nil
+}
+
+func (s *chatService) MarkMessagesAsRead(ctx context.Context, chatID, userID string) (int, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return 0, err
+	}
+
+	count, err := s.repository.MarkMessagesAsRead(ctx, chatID, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to mark messages as read")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// MarkMessagesAsDelivered records that userID's client has received the
+// chat's messages from others, for the delivered tick.
+func (s *chatService) MarkMessagesAsDelivered(ctx context.Context, chatID, userID string) (int, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return 0, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return 0, err
+	}
+
+	count, err := s.repository.MarkMessagesAsDelivered(ctx, chatID, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to mark messages as delivered")
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// SubscribeChatEvents streams events for a chat to a participant until ctx is
+// cancelled. The returned channel is closed when the subscription ends, either
+// through cancellation or because the consumer fell too far behind.
+func (s *chatService) SubscribeChatEvents(ctx context.Context, chatID, userID string) (<-chan *models.ChatEvent, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	sub := s.hub.subscribe(chatID)
+	go func() {
+		<-ctx.Done()
+		s.hub.unsubscribe(sub)
+	}()
+
+	return sub.events, nil
+}
+
+// GetMessageReaders lists who has read a message and when, for a caller who
+// is a participant in the message's chat.
+func (s *chatService) GetMessageReaders(ctx context.Context, messageID, userID string) ([]*models.MessageRead, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"message_id", messageID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.getVisibleMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, msg.ChatID, userID); err != nil {
+		return nil, err
+	}
+
+	readers, err := s.repository.GetMessageReaders(ctx, messageID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get message readers")
+		return nil, err
+	}
+
+	return readers, nil
+}
+
+func (s *chatService) GetFirstUnreadMessage(ctx context.Context, chatID, userID string) (*models.Message, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.repository.GetFirstUnreadMessage(ctx, chatID, userID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get first unread message")
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (s *chatService) GetChatStats(ctx context.Context, chatID, userID string) (*models.ChatStats, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	chat, err := s.getParticipantChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ChatStats{
+		ChatID:       chat.ID,
+		MessageCount: chat.MessageCount,
+		BytesUsed:    chat.BytesUsed,
+	}, nil
+}
+
+// GetChatPolicies resolves the limits that apply to a chat. All of them are
+// global settings today; the chat itself only contributes its message count
+// towards the quota.
+func (s *chatService) GetChatPolicies(ctx context.Context, chatID, userID string) (*models.ChatPolicies, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	chat, err := s.getParticipantChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := &models.ChatPolicies{
+		ChatID:             chat.ID,
+		MaxMessageLength:   s.maxMessageLength(),
+		LengthMode:         s.lengthMode(),
+		OversizePolicy:     s.oversizePolicy(),
+		MaxMessagesPerChat: s.config.MaxMessagesPerChat,
+		AllowedLanguages:   s.config.AllowedLanguages,
+		WriteConcern:       string(s.writeConcern("")),
+		FastWritesAllowed:  s.config.AllowFastWrites,
+	}
+	if s.config.MaxMessagesPerChat > 0 {
+		policies.MessagesRemaining = max(s.config.MaxMessagesPerChat-chat.MessageCount, 0)
+	}
+
+	return policies, nil
+}
+
+func (s *chatService) GetMessageHistogram(ctx context.Context, chatID, userID, bucket string, from, to time.Time, fillGaps bool) ([]*models.HistogramBucket, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	width, ok := histogramBuckets[bucket]
+	if !ok {
+		return nil, ErrInvalidBucket
+	}
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return nil, ErrInvalidTimeRange
+	}
+	if to.Sub(from)/width > maxHistogramBuckets {
+		return nil, fmt.Errorf("%w: more than %d %s buckets", ErrInvalidTimeRange, maxHistogramBuckets, bucket)
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	buckets, err := s.repository.GetMessageHistogram(ctx, chatID, bucket, from.UTC(), to.UTC(), fillGaps)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get message histogram")
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+func (s *chatService) GetMostDuplicatedContent(ctx context.Context, limit int) ([]*models.DuplicateContent, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	duplicates, err := s.repository.GetTopDuplicatedContent(ctx, limit)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get duplicated content")
+		return nil, err
+	}
+
+	return duplicates, nil
+}
+
+func (s *chatService) GetContentSpread(ctx context.Context, contentHash string) (*models.ContentSpread, error) {
+	if contentHash == "" {
+		return nil, fmt.Errorf("content hash is required")
+	}
+
+	spread, err := s.repository.GetContentSpread(ctx, contentHash)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get content spread")
+		return nil, err
+	}
+
+	return spread, nil
+}
+
+// GetAuditLog returns audit entries matching filter, newest first. The log
+// spans every user, so only the service principal may read it.
+func (s *chatService) GetAuditLog(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	if !isServiceCaller(ctx) {
+		return nil, ErrServiceOnly
+	}
+	if filter.ActorID != "" {
+		if err := validateIDs(idField{"actor_id", filter.ActorID}); err != nil {
+			return nil, err
+		}
+	}
+	if filter.ChatID != "" {
+		if err := validateIDs(idField{"chat_id", filter.ChatID}); err != nil {
+			return nil, err
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrInvalidTimeRange
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+	if filter.Limit > 500 {
+		filter.Limit = 500
+	}
+
+	entries, err := s.repository.GetAuditLog(ctx, filter)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get audit log")
+		return nil, err
+	}
+
+	return entries, nil
+}
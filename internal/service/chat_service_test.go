@@ -0,0 +1,902 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/auth"
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/ratelimit"
+	"metachat/chat-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	testChatID     = "3d6f8a2e-1c4b-4e5f-9a7b-2c3d4e5f6a7b"
+	testStrangerID = "5c4b3a29-1807-4f6e-9d5c-4b3a29180706"
+)
+
+func TestDistinctIDs(t *testing.T) {
+	const (
+		upper = "6F1C2A9E-3B4D-4C5E-8F7A-1B2C3D4E5F60"
+		mixed = "6f1C2a9E-3b4D-4c5E-8f7A-1b2C3d4E5f60"
+	)
+
+	tests := []struct {
+		name string
+		ids  []string
+		want []string
+	}{
+		{"empty", nil, []string{}},
+		{"already distinct", []string{testUserID, testOtherID}, []string{testUserID, testOtherID}},
+		{"exact repeat", []string{testUserID, testOtherID, testUserID}, []string{testUserID, testOtherID}},
+		{"upper and lower case", []string{upper, testUserID}, []string{testUserID}},
+		{"mixed case first", []string{mixed, testOtherID, upper, testUserID}, []string{testUserID, testOtherID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distinctIDs(tt.ids); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("distinctIDs(%v) = %v, want %v", tt.ids, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetChatChecksParticipation(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		wantErr     error
+		wantCreator bool
+	}{
+		{"unauthenticated", context.Background(), nil, false},
+		{"creator", auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID}), nil, true},
+		{"other participant", auth.WithPrincipal(context.Background(), auth.Principal{UserID: testOtherID}), nil, false},
+		{"stranger", auth.WithPrincipal(context.Background(), auth.Principal{UserID: testStrangerID}), ErrNotParticipant, false},
+		{"service", auth.WithPrincipal(context.Background(), auth.Principal{UserID: testStrangerID, Service: true}), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chat, err := svc.GetChat(tt.ctx, testChatID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetChat() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if chat != nil {
+					t.Errorf("GetChat() returned %+v alongside the error", chat)
+				}
+				return
+			}
+			if chat.IsCreator != tt.wantCreator {
+				t.Errorf("IsCreator = %v, want %v", chat.IsCreator, tt.wantCreator)
+			}
+		})
+	}
+}
+
+func TestCreateChatCanonicalizesIDs(t *testing.T) {
+	upperUser := strings.ToUpper(testUserID)
+	upperOther := strings.ToUpper(testOtherID)
+
+	t.Run("same user in different case", func(t *testing.T) {
+		svc, _ := newTestService(newFakeRepository(), Config{})
+		_, err := svc.CreateChat(context.Background(), upperUser, testUserID, CreateChatOptions{})
+		if !errors.Is(err, ErrTooFewParticipants) {
+			t.Fatalf("CreateChat() error = %v, want ErrTooFewParticipants", err)
+		}
+	})
+
+	t.Run("existing pair in different case", func(t *testing.T) {
+		repo := newFakeRepository()
+		existing := repo.addChat(testChatID, testUserID, testOtherID)
+		svc, _ := newTestService(repo, Config{})
+
+		chat, err := svc.CreateChat(context.Background(), upperOther, upperUser, CreateChatOptions{})
+		if err != nil {
+			t.Fatalf("CreateChat() error = %v", err)
+		}
+		if chat.ID != existing.ID {
+			t.Errorf("CreateChat() = chat %s, want the existing chat %s", chat.ID, existing.ID)
+		}
+		if len(repo.chats) != 1 {
+			t.Errorf("stored %d chats, want 1", len(repo.chats))
+		}
+	})
+
+	t.Run("new pair stored in canonical form", func(t *testing.T) {
+		repo := newFakeRepository()
+		svc, _ := newTestService(repo, Config{})
+
+		chat, err := svc.CreateChat(context.Background(), upperUser, upperOther, CreateChatOptions{})
+		if err != nil {
+			t.Fatalf("CreateChat() error = %v", err)
+		}
+		if chat.UserID1 != testUserID || chat.UserID2 != testOtherID || chat.CreatedBy != testUserID {
+			t.Errorf("CreateChat() stored %s/%s created by %s, want lowercase IDs", chat.UserID1, chat.UserID2, chat.CreatedBy)
+		}
+	})
+}
+
+func TestGetDirectChatsForPeersCanonicalizesIDs(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+
+	peers := []string{strings.ToUpper(testOtherID), testOtherID, testStrangerID, strings.ToUpper(testUserID)}
+	got, err := svc.GetDirectChatsForPeers(context.Background(), strings.ToUpper(testUserID), peers)
+	if err != nil {
+		t.Fatalf("GetDirectChatsForPeers() error = %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("GetDirectChatsForPeers() returned %d peers, want 2: %v", len(got), got)
+	}
+	if chat := got[testOtherID]; chat == nil || chat.ID != testChatID {
+		t.Errorf("peer %s = %v, want chat %s", testOtherID, chat, testChatID)
+	}
+	if chat, ok := got[testStrangerID]; !ok || chat != nil {
+		t.Errorf("peer %s = %v, %v, want a nil entry", testStrangerID, chat, ok)
+	}
+}
+
+func TestGetAuditLogRequiresServicePrincipal(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+	}{
+		{"unauthenticated", context.Background(), ErrServiceOnly},
+		{"user", auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID}), ErrServiceOnly},
+		{"service", auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID, Service: true}), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			svc, _ := newTestService(repo, Config{})
+
+			_, err := svc.GetAuditLog(tt.ctx, models.AuditFilter{ActorID: testOtherID})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("GetAuditLog() error = %v, want %v", err, tt.err)
+			}
+			if reads := len(repo.auditFilters); (tt.err == nil) != (reads == 1) {
+				t.Errorf("repository reads = %d", reads)
+			}
+		})
+	}
+}
+
+func TestGetAuditLogValidatesFilter(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID, Service: true})
+	from := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		filter models.AuditFilter
+		err    error
+	}{
+		{"bad actor", models.AuditFilter{ActorID: "nope"}, ErrInvalidID},
+		{"bad chat", models.AuditFilter{ChatID: "nope"}, ErrInvalidID},
+		{"reversed range", models.AuditFilter{From: &from, To: &to}, ErrInvalidTimeRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestService(newFakeRepository(), Config{})
+			if _, err := svc.GetAuditLog(ctx, tt.filter); !errors.Is(err, tt.err) {
+				t.Errorf("GetAuditLog() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	repo := newFakeRepository()
+	svc, _ := newTestService(repo, Config{})
+	if _, err := svc.GetAuditLog(ctx, models.AuditFilter{Limit: 10000}); err != nil {
+		t.Fatalf("GetAuditLog() error = %v", err)
+	}
+	if got := repo.auditFilters[0].Limit; got != 500 {
+		t.Errorf("limit = %d, want the 500 cap", got)
+	}
+}
+
+func TestSendMessageWarnsNearRateLimit(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{
+		RateLimiter:        ratelimit.NewMemoryLimiter(10),
+		RateLimitWarnRatio: 0.2,
+	})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	// With 10 a minute, the 8th send leaves 2, which is 20% of the limit.
+	for i := 1; i <= 10; i++ {
+		msg, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{})
+		if err != nil {
+			t.Fatalf("send %d: error = %v", i, err)
+		}
+		want := models.RateLimitStatus{Remaining: 10 - i, Limit: 10, Warning: i >= 8}
+		if msg.RateLimit == nil || *msg.RateLimit != want {
+			t.Errorf("send %d: rate limit = %+v, want %+v", i, msg.RateLimit, want)
+		}
+	}
+
+	if _, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{}); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("send over the limit: error = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestSendMessageWithoutLimiterHasNoRateLimit(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	msg, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if msg.RateLimit != nil {
+		t.Errorf("rate limit = %+v, want none without a limiter", msg.RateLimit)
+	}
+}
+
+func TestSendMessageOversizePolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   string
+		err    error
+	}{
+		{"reject by default", "", "", ErrMessageTooLong},
+		{"reject", OversizePolicyReject, "", ErrMessageTooLong},
+		{"truncate", OversizePolicyTruncate, "héllo wo…", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addChat(testChatID, testUserID, testOtherID)
+			svc, _ := newTestService(repo, Config{MaxMessageLength: 9, OversizePolicy: tt.policy})
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+			msg, err := svc.SendMessage(ctx, testChatID, "", "héllo world", SendMessageOptions{})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SendMessage() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if msg.Content != tt.want {
+				t.Errorf("content = %q, want %q", msg.Content, tt.want)
+			}
+			stored, _ := repo.GetMessageByID(ctx, msg.ID)
+			if stored.Content != tt.want {
+				t.Errorf("stored content = %q, want %q", stored.Content, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetChatMessagesPagesBothWays(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	// Seven messages make pages of three, three and one.
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		msg := &models.Message{
+			ID:        uuid.NewString(),
+			ChatID:    testChatID,
+			SenderID:  testUserID,
+			Content:   fmt.Sprintf("message %d", i),
+			CreatedAt: createdAt.Add(time.Duration(i) * time.Minute),
+		}
+		if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+	}
+
+	getPage := func(cursor string) *models.MessagePage {
+		t.Helper()
+		page, err := svc.GetChatMessages(ctx, testChatID, "", 3, cursor, false)
+		if err != nil {
+			t.Fatalf("GetChatMessages(%q) error = %v", cursor, err)
+		}
+		return page
+	}
+	contents := func(page *models.MessagePage) []string {
+		var got []string
+		for _, msg := range page.Messages {
+			got = append(got, msg.Content)
+		}
+		return got
+	}
+
+	// Page back to the oldest messages, then forward again, expecting the
+	// same pages on the way up as on the way down.
+	pages := []*models.MessagePage{getPage("")}
+	if pages[0].PrevCursor != "" {
+		t.Errorf("newest page PrevCursor = %q, want none", pages[0].PrevCursor)
+	}
+	for pages[len(pages)-1].NextCursor != "" {
+		pages = append(pages, getPage(pages[len(pages)-1].NextCursor))
+	}
+	if len(pages) != 3 {
+		t.Fatalf("paged back through %d pages, want 3", len(pages))
+	}
+
+	for i := len(pages) - 1; i > 0; i-- {
+		if pages[i].PrevCursor == "" {
+			t.Fatalf("page %d has no PrevCursor", i)
+		}
+		newer := getPage(pages[i].PrevCursor)
+		if got, want := contents(newer), contents(pages[i-1]); !slices.Equal(got, want) {
+			t.Errorf("forward from page %d = %v, want %v", i, got, want)
+		}
+		if newer.NextCursor != pages[i-1].NextCursor || newer.PrevCursor != pages[i-1].PrevCursor {
+			t.Errorf("forward from page %d cursors = (%q, %q), want (%q, %q)",
+				i, newer.NextCursor, newer.PrevCursor, pages[i-1].NextCursor, pages[i-1].PrevCursor)
+		}
+	}
+}
+
+func TestSendMessageDuplicateIDPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		concern WriteConcern
+		wantErr error
+		// stored is whether the message ends up in the repository.
+		stored bool
+	}{
+		{"retry by default", "", WriteConcernDurable, nil, true},
+		{"retry", DuplicateIDPolicyRetry, WriteConcernDurable, nil, true},
+		{"reject", DuplicateIDPolicyReject, WriteConcernDurable, repository.ErrDuplicateMessageID, false},
+		// The acked ID can't change, so a fast write is never retried.
+		{"fast write", DuplicateIDPolicyRetry, WriteConcernFast, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addChat(testChatID, testUserID, testOtherID)
+			// The first insert collides with an existing primary key.
+			repo.createErrs = []error{repository.ErrDuplicateMessageID}
+			svc, hook := newTestService(repo, Config{DuplicateIDPolicy: tt.policy, AllowFastWrites: true})
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+			msg, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{WriteConcern: tt.concern})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SendMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			if err := svc.WaitForPendingWrites(ctx); err != nil {
+				t.Fatalf("WaitForPendingWrites() error = %v", err)
+			}
+
+			if len(repo.messages) > 1 || (len(repo.messages) == 1) != tt.stored {
+				t.Fatalf("stored %d messages, want stored = %v", len(repo.messages), tt.stored)
+			}
+			if tt.stored {
+				if _, ok := repo.messages[msg.ID]; !ok {
+					t.Errorf("returned ID %s is not the stored one", msg.ID)
+				}
+			}
+			if tt.concern == WriteConcernFast {
+				if entry := hook.LastEntry(); entry == nil || entry.Message != "Failed to store acknowledged message" {
+					t.Errorf("last log = %v, want the lost fast write logged", entry)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDirectChatsForPeers(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	// The caller is the second user of this pair.
+	reversed := repo.addChat(uuid.NewString(), testStrangerID, testUserID)
+	repo.addGroupChat(uuid.NewString(), testUserID, testOtherID, testStrangerID)
+	svc, _ := newTestService(repo, Config{})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	missing := uuid.NewString()
+	// Duplicates and the caller's own ID are folded away.
+	chats, err := svc.GetDirectChatsForPeers(ctx, "", []string{testOtherID, missing, testStrangerID, testOtherID, testUserID})
+	if err != nil {
+		t.Fatalf("GetDirectChatsForPeers() error = %v", err)
+	}
+
+	if len(chats) != 3 {
+		t.Errorf("got %d peers, want 3: %v", len(chats), chats)
+	}
+	if chat := chats[testOtherID]; chat == nil || chat.ID != testChatID {
+		t.Errorf("chat with %s = %v, want %s", testOtherID, chat, testChatID)
+	}
+	if chat := chats[testStrangerID]; chat == nil || chat.ID != reversed.ID {
+		t.Errorf("chat with %s = %v, want %s", testStrangerID, chat, reversed.ID)
+	}
+	if chat, ok := chats[missing]; !ok || chat != nil {
+		t.Errorf("peer without a chat = %v, present %v; want a nil entry", chat, ok)
+	}
+}
+
+func TestGetDirectChatsForPeersCapsPeers(t *testing.T) {
+	svc, _ := newTestService(newFakeRepository(), Config{})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	peers := make([]string, maxPeerLookup+1)
+	for i := range peers {
+		peers[i] = uuid.NewString()
+	}
+	if _, err := svc.GetDirectChatsForPeers(ctx, "", peers); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("GetDirectChatsForPeers() with %d peers error = %v, want ErrBatchTooLarge", len(peers), err)
+	}
+	if _, err := svc.GetDirectChatsForPeers(ctx, "", peers[:maxPeerLookup]); err != nil {
+		t.Errorf("GetDirectChatsForPeers() with %d peers error = %v", maxPeerLookup, err)
+	}
+}
+
+func TestCreateChatSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		policy  string
+		source  string
+		want    string
+		wantErr error
+	}{
+		{"none", nil, "", "", "", nil},
+		{"default allowlist", nil, "", "support", "support", nil},
+		{"unknown rejected", nil, "", "billboard", "", ErrUnknownChatSource},
+		{"unknown stored", nil, UnknownSourcePolicyStore, "billboard", ChatSourceUnknown, nil},
+		{"configured allowlist", []string{"billboard"}, "", "billboard", "billboard", nil},
+		{"outside configured allowlist", []string{"billboard"}, UnknownSourcePolicyReject, "support", "", ErrUnknownChatSource},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			svc, _ := newTestService(repo, Config{AllowedChatSources: tt.allowed, UnknownSourcePolicy: tt.policy})
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+			chat, err := svc.CreateChat(ctx, "", testOtherID, CreateChatOptions{Source: tt.source})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateChat() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(repo.chats) != 0 {
+					t.Error("chat with a rejected source was stored")
+				}
+				return
+			}
+
+			got, err := svc.GetChat(ctx, chat.ID)
+			if err != nil {
+				t.Fatalf("GetChat() error = %v", err)
+			}
+			if chat.Source != tt.want || got.Source != tt.want {
+				t.Errorf("source = %q, read back %q; want %q", chat.Source, got.Source, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendMessageEnforcesChatQuota(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{MaxMessagesPerChat: 2})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	count := func() int64 {
+		t.Helper()
+		n, err := svc.GetChatMessageCount(ctx, testChatID, "")
+		if err != nil {
+			t.Fatalf("GetChatMessageCount() error = %v", err)
+		}
+		return n
+	}
+
+	var sent []*models.Message
+	for i := 1; i <= 2; i++ {
+		msg, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{})
+		if err != nil {
+			t.Fatalf("send %d: error = %v", i, err)
+		}
+		sent = append(sent, msg)
+		if n := count(); n != int64(i) {
+			t.Errorf("count after send %d = %d, want %d", i, n, i)
+		}
+	}
+
+	if _, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{}); !errors.Is(err, ErrChatQuotaExceeded) {
+		t.Fatalf("send over the quota: error = %v, want ErrChatQuotaExceeded", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("count after the rejected send = %d, want 2", n)
+	}
+
+	// Deleting a message frees its slot.
+	if err := svc.DeleteMessage(ctx, sent[0].ID, ""); err != nil {
+		t.Fatalf("DeleteMessage() error = %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("count after deleting = %d, want 1", n)
+	}
+	if _, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{}); err != nil {
+		t.Errorf("send after deleting: error = %v", err)
+	}
+}
+
+func TestIsCreatorFollowsCaller(t *testing.T) {
+	repo := newFakeRepository()
+	// testUserID created the direct chat, testOtherID the group.
+	repo.addChat(testChatID, testUserID, testOtherID)
+	group := repo.addGroupChat(uuid.NewString(), testOtherID, testUserID)
+	svc, _ := newTestService(repo, Config{IncludeEmptyChats: true})
+
+	for _, caller := range []string{testUserID, testOtherID} {
+		ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: caller})
+		wantCreated := map[string]bool{testChatID: caller == testUserID, group.ID: caller == testOtherID}
+
+		for chatID, want := range wantCreated {
+			chat, err := svc.GetChat(ctx, chatID)
+			if err != nil {
+				t.Fatalf("GetChat() error = %v", err)
+			}
+			if chat.IsCreator != want {
+				t.Errorf("GetChat(%s) as %s: IsCreator = %v, want %v", chatID, caller, chat.IsCreator, want)
+			}
+		}
+
+		list, err := svc.GetUserChats(ctx, "", UserChatsOptions{})
+		if err != nil {
+			t.Fatalf("GetUserChats() error = %v", err)
+		}
+		if len(list.Chats) != len(wantCreated) {
+			t.Fatalf("GetUserChats() as %s returned %d chats, want %d", caller, len(list.Chats), len(wantCreated))
+		}
+		for _, chat := range list.Chats {
+			if chat.IsCreator != wantCreated[chat.ID] {
+				t.Errorf("GetUserChats() as %s: %s IsCreator = %v, want %v", caller, chat.ID, chat.IsCreator, wantCreated[chat.ID])
+			}
+		}
+	}
+}
+
+func TestGetUserChatsEmptyChats(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	empty := repo.addChat(uuid.NewString(), testUserID, testStrangerID)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	sender, _ := newTestService(repo, Config{})
+	if _, err := sender.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	tests := []struct {
+		includeEmpty bool
+		want         []string
+	}{
+		{true, []string{testChatID, empty.ID}},
+		{false, []string{testChatID}},
+	}
+	for _, tt := range tests {
+		svc, _ := newTestService(repo, Config{IncludeEmptyChats: tt.includeEmpty})
+		list, err := svc.GetUserChats(ctx, "", UserChatsOptions{})
+		if err != nil {
+			t.Fatalf("GetUserChats() error = %v", err)
+		}
+		var got []string
+		for _, chat := range list.Chats {
+			got = append(got, chat.ID)
+		}
+		slices.Sort(got)
+		slices.Sort(tt.want)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("IncludeEmptyChats %v: chats = %v, want %v", tt.includeEmpty, got, tt.want)
+		}
+	}
+}
+
+func TestCreateGroupChatInfersType(t *testing.T) {
+	third := uuid.NewString()
+	tests := []struct {
+		name         string
+		participants []string
+		override     models.ChatType
+		want         models.ChatType
+		wantErr      error
+	}{
+		{"two participants", []string{testOtherID}, "", models.ChatTypeDirect, nil},
+		{"creator listed again", []string{testUserID, testOtherID}, "", models.ChatTypeDirect, nil},
+		{"three participants", []string{testOtherID, third}, "", models.ChatTypeGroup, nil},
+		{"group of two", []string{testOtherID}, models.ChatTypeGroup, models.ChatTypeGroup, nil},
+		{"direct of three", []string{testOtherID, third}, models.ChatTypeDirect, "", ErrInvalidChatType},
+		{"unknown type", []string{testOtherID}, "channel", "", ErrInvalidChatType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			svc, _ := newTestService(repo, Config{})
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+			chat, err := svc.CreateGroupChat(ctx, "", tt.participants, "team", CreateChatOptions{Type: tt.override})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateGroupChat() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			stored, err := repo.GetChatByID(ctx, chat.ID)
+			if err != nil {
+				t.Fatalf("GetChatByID() error = %v", err)
+			}
+			if chat.Type != tt.want || stored.Type != tt.want {
+				t.Errorf("type = %q, stored %q; want %q", chat.Type, stored.Type, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateChatTypeOverride(t *testing.T) {
+	repo := newFakeRepository()
+	svc, _ := newTestService(repo, Config{})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	chat, err := svc.CreateChat(ctx, "", testOtherID, CreateChatOptions{Type: models.ChatTypeGroup})
+	if err != nil {
+		t.Fatalf("CreateChat() error = %v", err)
+	}
+	if chat.Type != models.ChatTypeGroup {
+		t.Errorf("type = %q, want %q", chat.Type, models.ChatTypeGroup)
+	}
+
+	if _, err := svc.CreateChat(ctx, "", testOtherID, CreateChatOptions{Type: "channel"}); !errors.Is(err, ErrInvalidChatType) {
+		t.Errorf("CreateChat() with an unknown type error = %v, want ErrInvalidChatType", err)
+	}
+}
+
+func TestGetUserChatsTruncatesAtHardCap(t *testing.T) {
+	repo := newFakeRepository()
+	for i := 0; i < 5; i++ {
+		repo.addChat(uuid.NewString(), testUserID, uuid.NewString())
+	}
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	tests := []struct {
+		hardCap       int
+		wantChats     int
+		wantTruncated bool
+	}{
+		{3, 3, true},
+		{5, 5, false},
+		{10, 5, false},
+	}
+	for _, tt := range tests {
+		svc, hook := newTestService(repo, Config{IncludeEmptyChats: true, ChatListHardCap: tt.hardCap})
+		list, err := svc.GetUserChats(ctx, "", UserChatsOptions{})
+		if err != nil {
+			t.Fatalf("GetUserChats() error = %v", err)
+		}
+		if len(list.Chats) != tt.wantChats || list.Truncated != tt.wantTruncated {
+			t.Errorf("hard cap %d: got %d chats, truncated %v; want %d, %v",
+				tt.hardCap, len(list.Chats), list.Truncated, tt.wantChats, tt.wantTruncated)
+		}
+		if warned := hook.LastEntry() != nil && hook.LastEntry().Message == "User chat list truncated at hard cap"; warned != tt.wantTruncated {
+			t.Errorf("hard cap %d: truncation warning logged = %v, want %v", tt.hardCap, warned, tt.wantTruncated)
+		}
+	}
+}
+
+func TestGetChatMessagesPlacesJoinMarkers(t *testing.T) {
+	repo := newFakeRepository()
+	late, last := uuid.NewString(), uuid.NewString()
+	group := repo.addGroupChat(uuid.NewString(), testUserID, testOtherID, late, last)
+	direct := repo.addChat(testChatID, testUserID, testOtherID)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(minute int) time.Time { return start.Add(time.Duration(minute) * time.Minute) }
+	// The founders join before m1, late between m2 and m3 and last after m4.
+	repo.joins[group.ID] = []*models.Participant{
+		{UserID: testUserID, JoinedAt: at(0)},
+		{UserID: testOtherID, JoinedAt: at(0)},
+		{UserID: late, JoinedAt: at(3)},
+		{UserID: last, JoinedAt: at(6)},
+	}
+	for _, chat := range []*models.Chat{group, direct} {
+		for i, minute := range []int{1, 2, 4, 5} {
+			msg := &models.Message{ID: uuid.NewString(), ChatID: chat.ID, SenderID: testUserID, Content: fmt.Sprintf("m%d", i+1), CreatedAt: at(minute)}
+			if err := repo.CreateMessage(ctx, msg, 0); err != nil {
+				t.Fatalf("CreateMessage() error = %v", err)
+			}
+		}
+	}
+	joined := func(page *models.MessagePage) []string {
+		var users []string
+		for _, join := range page.Joins {
+			users = append(users, join.UserID)
+		}
+		slices.Sort(users)
+		return users
+	}
+
+	svc, _ := newTestService(repo, Config{JoinMarkers: true})
+	newest, err := svc.GetChatMessages(ctx, group.ID, "", 2, "", false)
+	if err != nil {
+		t.Fatalf("GetChatMessages() error = %v", err)
+	}
+	if got, want := joined(newest), []string{last}; !slices.Equal(got, want) {
+		t.Errorf("joins on the page of m3 and m4 = %v, want %v", got, want)
+	}
+	oldest, err := svc.GetChatMessages(ctx, group.ID, "", 2, newest.NextCursor, false)
+	if err != nil {
+		t.Fatalf("GetChatMessages() error = %v", err)
+	}
+	want := []string{testUserID, testOtherID, late}
+	slices.Sort(want)
+	if got := joined(oldest); !slices.Equal(got, want) {
+		t.Errorf("joins on the page of m1 and m2 = %v, want %v", got, want)
+	}
+
+	page, err := svc.GetChatMessages(ctx, direct.ID, "", 2, "", false)
+	if err != nil {
+		t.Fatalf("GetChatMessages() error = %v", err)
+	}
+	if page.Joins != nil {
+		t.Errorf("direct chat joins = %v, want none", page.Joins)
+	}
+
+	svc, _ = newTestService(repo, Config{})
+	page, err = svc.GetChatMessages(ctx, group.ID, "", 2, "", false)
+	if err != nil {
+		t.Fatalf("GetChatMessages() error = %v", err)
+	}
+	if page.Joins != nil {
+		t.Errorf("joins with markers off = %v, want none", page.Joins)
+	}
+}
+
+func TestSendMessageWriteConcerns(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested WriteConcern
+		allowFast bool
+		// waits is whether SendMessage waits for the publish.
+		waits bool
+	}{
+		{"durable", WriteConcernDurable, true, true},
+		{"fast", WriteConcernFast, true, false},
+		// The server policy turns a fast request into a durable one.
+		{"fast not allowed", WriteConcernFast, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addChat(testChatID, testUserID, testOtherID)
+			publisher := &recordingPublisher{hold: make(chan struct{})}
+			svc, _ := newTestService(repo, Config{Publisher: publisher, AllowFastWrites: tt.allowFast})
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+			type result struct {
+				msg *models.Message
+				err error
+			}
+			sent := make(chan result, 1)
+			go func() {
+				msg, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{WriteConcern: tt.requested})
+				sent <- result{msg, err}
+			}()
+
+			var res result
+			select {
+			case res = <-sent:
+				if tt.waits {
+					t.Fatal("SendMessage returned before the message was published")
+				}
+			case <-time.After(50 * time.Millisecond):
+				if !tt.waits {
+					t.Fatal("fast SendMessage waited for the publish")
+				}
+			}
+
+			close(publisher.hold)
+			if tt.waits {
+				res = <-sent
+			}
+			if res.err != nil {
+				t.Fatalf("SendMessage() error = %v", res.err)
+			}
+			if tt.waits && publisher.published() != 1 {
+				t.Errorf("durable send returned with %d events published, want 1", publisher.published())
+			}
+
+			if err := svc.WaitForPendingWrites(ctx); err != nil {
+				t.Fatalf("WaitForPendingWrites() error = %v", err)
+			}
+			if _, err := repo.GetMessageByID(ctx, res.msg.ID); err != nil {
+				t.Errorf("acknowledged message %s not stored: %v", res.msg.ID, err)
+			}
+			if n := publisher.published(); n != 1 {
+				t.Errorf("published %d events, want 1", n)
+			}
+		})
+	}
+}
+
+func TestGetChatReturnsCallersLastRead(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+	readerCtx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+	senderCtx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testOtherID})
+
+	base := time.Now().UTC()
+	send := func(n int) *models.Message {
+		t.Helper()
+		msg := &models.Message{ID: uuid.NewString(), ChatID: testChatID, SenderID: testOtherID, Content: "hi", CreatedAt: base.Add(time.Duration(n) * time.Second)}
+		if err := repo.CreateMessage(context.Background(), msg, 0); err != nil {
+			t.Fatalf("CreateMessage() error = %v", err)
+		}
+		return msg
+	}
+	lastRead := func(ctx context.Context) *models.ReadPointer {
+		t.Helper()
+		chat, err := svc.GetChat(ctx, testChatID)
+		if err != nil {
+			t.Fatalf("GetChat() error = %v", err)
+		}
+		return chat.LastRead
+	}
+	markRead := func() {
+		t.Helper()
+		if _, err := svc.MarkMessagesAsRead(readerCtx, testChatID, ""); err != nil {
+			t.Fatalf("MarkMessagesAsRead() error = %v", err)
+		}
+	}
+
+	send(1)
+	if pointer := lastRead(readerCtx); pointer != nil {
+		t.Fatalf("LastRead before reading = %+v, want none", pointer)
+	}
+
+	second := send(2)
+	markRead()
+	pointer := lastRead(readerCtx)
+	if pointer == nil || pointer.MessageID != second.ID || !pointer.MessageCreatedAt.Equal(second.CreatedAt) || pointer.ReadAt.IsZero() {
+		t.Fatalf("LastRead after reading = %+v, want %s", pointer, second.ID)
+	}
+	// The pointer is the caller's own: the sender hasn't read anything.
+	if other := lastRead(senderCtx); other != nil {
+		t.Errorf("sender's LastRead = %+v, want none", other)
+	}
+
+	// A newer message leaves the pointer where the last read put it.
+	third := send(3)
+	if got := lastRead(readerCtx); got == nil || got.MessageID != second.ID || !got.ReadAt.Equal(pointer.ReadAt) {
+		t.Errorf("LastRead after a new message = %+v, want it still at %s", got, second.ID)
+	}
+	markRead()
+	if got := lastRead(readerCtx); got == nil || got.MessageID != third.ID {
+		t.Errorf("LastRead after reading again = %+v, want %s", got, third.ID)
+	}
+}
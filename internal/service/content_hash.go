@@ -0,0 +1,15 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const contentHashLength = 32
+
+// hashContent returns a truncated hex-encoded SHA-256 of the message content,
+// used to group identical messages for spam and duplicate analytics.
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])[:contentHashLength]
+}
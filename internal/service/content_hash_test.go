@@ -0,0 +1,28 @@
+package service
+
+import "testing"
+
+func TestHashContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"empty", "", "e3b0c44298fc1c149afbf4c8996fb924"},
+		{"ascii", "hello", "2cf24dba5fb0a30e26e83b2ac5b9e29e"},
+		{"case sensitive", "Hello", "185f8db32271fe25f561a6fc938b2e26"},
+		{"multibyte", "héllo 👋", "241bff4036211b66e25dc44c43c7305f"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hashContent(tt.content)
+			if got != tt.want {
+				t.Errorf("hashContent(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+			if len(got) != contentHashLength {
+				t.Errorf("len(hashContent(%q)) = %d, want %d", tt.content, len(got), contentHashLength)
+			}
+		})
+	}
+}
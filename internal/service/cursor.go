@@ -0,0 +1,61 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// afterPrefix marks a cursor that pages towards newer messages. Cursors
+// without it page back, as all of them did before paging forward existed.
+const afterPrefix = "after:"
+
+// encodeCursor builds an opaque page token from a message's (created_at, id)
+// position, which unlike the random UUID alone is chronologically ordered.
+// The token pages back to the messages before msg.
+func encodeCursor(msg *models.Message) string {
+	raw := strconv.FormatInt(msg.CreatedAt.UnixNano(), 10) + ":" + msg.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// encodeAfterCursor is encodeCursor for the messages after msg.
+func encodeAfterCursor(msg *models.Message) string {
+	raw := afterPrefix + strconv.FormatInt(msg.CreatedAt.UnixNano(), 10) + ":" + msg.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*models.MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	position, after := strings.CutPrefix(string(raw), afterPrefix)
+	nanos, id, found := strings.Cut(position, ":")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	ts, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: bad message id", ErrInvalidCursor)
+	}
+
+	return &models.MessageCursor{
+		CreatedAt: time.Unix(0, ts).UTC(),
+		ID:        id,
+		After:     after,
+	}, nil
+}
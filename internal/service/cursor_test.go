@@ -0,0 +1,95 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+	}{
+		{"nanosecond precision", time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)},
+		{"non-utc zone", time.Date(2024, 3, 1, 14, 30, 45, 0, time.FixedZone("CEST", 2*60*60))},
+		{"before epoch", time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &models.Message{ID: testUserID, CreatedAt: tt.createdAt}
+
+			got, err := decodeCursor(encodeCursor(msg))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if !got.CreatedAt.Equal(tt.createdAt) {
+				t.Errorf("CreatedAt = %v, want %v", got.CreatedAt, tt.createdAt)
+			}
+			if got.CreatedAt.Location() != time.UTC {
+				t.Errorf("CreatedAt location = %v, want UTC", got.CreatedAt.Location())
+			}
+			if got.ID != msg.ID {
+				t.Errorf("ID = %q, want %q", got.ID, msg.ID)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejectsTampering(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	valid := encodeCursor(&models.Message{ID: testUserID, CreatedAt: time.Unix(0, 1700000000000000000)})
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("1:" + testUserID))},
+		{"bare message id", testUserID},
+		{"missing separator", encode("1700000000000000000")},
+		{"non-numeric timestamp", encode("yesterday:" + testUserID)},
+		{"timestamp overflow", encode("99999999999999999999:" + testUserID)},
+		{"bad message id", encode("1700000000000000000:alice")},
+		{"truncated", valid[:len(valid)-4]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) error = %v, want ErrInvalidCursor", tt.cursor, err)
+			}
+		})
+	}
+}
+
+func TestCursorDirection(t *testing.T) {
+	msg := &models.Message{ID: testUserID, CreatedAt: time.Unix(0, 1700000000000000000)}
+
+	tests := []struct {
+		name   string
+		cursor string
+		after  bool
+	}{
+		{"back", encodeCursor(msg), false},
+		{"forward", encodeAfterCursor(msg), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if got.After != tt.after || got.ID != msg.ID || !got.CreatedAt.Equal(msg.CreatedAt) {
+				t.Errorf("decodeCursor() = %+v, want %s from the message", got, tt.name)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/models"
+)
+
+const (
+	EditHistoryParticipants = "participants"
+	EditHistoryAdmin        = "admin"
+)
+
+// GetMessageEditHistory returns a message's earlier versions, oldest first.
+// By default any participant in the message's chat may read it; with
+// EditHistoryVisibility set to admin only the service principal may, and it
+// may also read the history of deleted messages.
+func (s *chatService) GetMessageEditHistory(ctx context.Context, messageID, userID string) ([]*models.MessageEdit, error) {
+	if err := validateIDs(idField{"message_id", messageID}); err != nil {
+		return nil, err
+	}
+
+	if s.config.EditHistoryVisibility == EditHistoryAdmin {
+		if !isServiceCaller(ctx) {
+			return nil, ErrServiceOnly
+		}
+		if _, err := s.repository.GetMessageByID(ctx, messageID); err != nil {
+			return nil, err
+		}
+	} else if userID = viewerID(ctx, userID); userID != "" {
+		if err := validateIDs(idField{"user_id", userID}); err != nil {
+			return nil, err
+		}
+		msg, err := s.getVisibleMessage(ctx, messageID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.getParticipantChat(ctx, msg.ChatID, userID); err != nil {
+			return nil, err
+		}
+	} else if _, err := s.getVisibleMessage(ctx, messageID); err != nil {
+		return nil, err
+	}
+
+	edits, err := s.repository.GetMessageEdits(ctx, messageID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get message edit history")
+		return nil, err
+	}
+
+	return edits, nil
+}
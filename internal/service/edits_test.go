@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"metachat/chat-service/internal/auth"
+)
+
+func TestGetMessageEditHistoryListsEditsInOrder(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+	sender := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	msg, err := svc.SendMessage(sender, testChatID, "", "first", SendMessageOptions{})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	for _, content := range []string{"second", "third"} {
+		if _, err := svc.EditMessage(sender, msg.ID, "", content); err != nil {
+			t.Fatalf("EditMessage() error = %v", err)
+		}
+	}
+
+	reader := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testOtherID})
+	edits, err := svc.GetMessageEditHistory(reader, msg.ID, "")
+	if err != nil {
+		t.Fatalf("GetMessageEditHistory() error = %v", err)
+	}
+	var got []string
+	for _, edit := range edits {
+		got = append(got, edit.OldContent)
+		if edit.EditorID != testUserID {
+			t.Errorf("editor = %q, want the sender", edit.EditorID)
+		}
+	}
+	if len(got) != 2 || got[0] != "first" || got[1] != "second" {
+		t.Errorf("history = %q, want [first second]", got)
+	}
+}
+
+func TestGetMessageEditHistoryVisibility(t *testing.T) {
+	participant := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testOtherID})
+	stranger := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testStrangerID})
+	trusted := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testStrangerID, Service: true})
+
+	tests := []struct {
+		name       string
+		visibility string
+		ctx        context.Context
+		err        error
+	}{
+		{"participant", "", participant, nil},
+		{"stranger", "", stranger, ErrNotParticipant},
+		{"service", "", trusted, nil},
+		{"participant when admin only", EditHistoryAdmin, participant, ErrServiceOnly},
+		{"service when admin only", EditHistoryAdmin, trusted, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addChat(testChatID, testUserID, testOtherID)
+			svc, _ := newTestService(repo, Config{EditHistoryVisibility: tt.visibility})
+
+			sender := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+			msg, err := svc.SendMessage(sender, testChatID, "", "first", SendMessageOptions{})
+			if err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+
+			if _, err := svc.GetMessageEditHistory(tt.ctx, msg.ID, ""); !errors.Is(err, tt.err) {
+				t.Errorf("GetMessageEditHistory() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
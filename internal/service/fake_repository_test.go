@@ -0,0 +1,486 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"metachat/chat-service/internal/events"
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// fakeRepository is an in-memory ChatRepository covering the calls the
+// service tests make; anything else panics through the nil embedded
+// interface.
+type fakeRepository struct {
+	repository.ChatRepository
+
+	mu       sync.Mutex
+	chats    map[string]*models.Chat
+	messages map[string]*models.Message
+	edits    map[string][]*models.MessageEdit
+	// reads maps a message ID to its readers and when they read it.
+	reads map[string]map[string]time.Time
+	// reactions maps a message ID to its reactions as "user/emoji" keys.
+	reactions map[string]map[string]bool
+	// auditFilters records the filters GetAuditLog was called with.
+	auditFilters []models.AuditFilter
+	// createErrs are returned, in order, by the next CreateMessage calls
+	// before they store anything.
+	createErrs []error
+	// reactionQueries counts GetReactionCounts calls.
+	reactionQueries int
+	// muted maps a chat ID to the users who muted it.
+	muted map[string]map[string]bool
+	// joins maps a chat ID to its participants and when they joined.
+	joins map[string][]*models.Participant
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{
+		chats:     make(map[string]*models.Chat),
+		messages:  make(map[string]*models.Message),
+		reads:     make(map[string]map[string]time.Time),
+		edits:     make(map[string][]*models.MessageEdit),
+		reactions: make(map[string]map[string]bool),
+		muted:     make(map[string]map[string]bool),
+		joins:     make(map[string][]*models.Participant),
+	}
+}
+
+// addChat stores a direct chat between two users, created by the first.
+func (r *fakeRepository) addChat(chatID, userID1, userID2 string) *models.Chat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	chat := &models.Chat{
+		ID:             chatID,
+		UserID1:        userID1,
+		UserID2:        userID2,
+		Type:           models.ChatTypeDirect,
+		CreatedBy:      userID1,
+		ParticipantIDs: []string{userID1, userID2},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	r.chats[chatID] = chat
+	return chat
+}
+
+// addGroupChat stores a group chat of the given members, created by the
+// first.
+func (r *fakeRepository) addGroupChat(chatID string, memberIDs ...string) *models.Chat {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	chat := &models.Chat{
+		ID:             chatID,
+		Name:           "group",
+		Type:           models.ChatTypeGroup,
+		CreatedBy:      memberIDs[0],
+		ParticipantIDs: append([]string(nil), memberIDs...),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	r.chats[chatID] = chat
+	return chat
+}
+
+func (r *fakeRepository) GetChatByID(_ context.Context, id string) (*models.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	chat, ok := r.chats[id]
+	if !ok {
+		return nil, repository.ErrChatNotFound
+	}
+	copied := *chat
+	copied.ParticipantIDs = append([]string(nil), chat.ParticipantIDs...)
+	return &copied, nil
+}
+
+func (r *fakeRepository) GetLastRead(_ context.Context, chatID, userID string) (*models.ReadPointer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pointer *models.ReadPointer
+	for _, msg := range r.sortedMessages(chatID) {
+		if readAt, ok := r.reads[msg.ID][userID]; ok {
+			pointer = &models.ReadPointer{MessageID: msg.ID, MessageCreatedAt: msg.CreatedAt, ReadAt: readAt}
+		}
+	}
+	return pointer, nil
+}
+
+// MarkMessagesAsRead records a read by userID on the chat's messages from
+// others that they haven't read yet.
+func (r *fakeRepository) MarkMessagesAsRead(_ context.Context, chatID, userID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	count := 0
+	for _, msg := range r.sortedMessages(chatID) {
+		if msg.SenderID == userID {
+			continue
+		}
+		if _, ok := r.reads[msg.ID][userID]; ok {
+			continue
+		}
+		if r.reads[msg.ID] == nil {
+			r.reads[msg.ID] = make(map[string]time.Time)
+		}
+		r.reads[msg.ID][userID] = now
+		count++
+	}
+	return count, nil
+}
+
+// sortedMessages returns the chat's messages oldest first, ordered like the
+// repository's (created_at, id) pages. Callers hold r.mu.
+func (r *fakeRepository) sortedMessages(chatID string) []*models.Message {
+	var messages []*models.Message
+	for _, msg := range r.messages {
+		if msg.ChatID == chatID {
+			messages = append(messages, msg)
+		}
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		if !messages[i].CreatedAt.Equal(messages[j].CreatedAt) {
+			return messages[i].CreatedAt.Before(messages[j].CreatedAt)
+		}
+		return messages[i].ID < messages[j].ID
+	})
+	return messages
+}
+
+func newTestService(repo repository.ChatRepository, cfg Config) (*chatService, *test.Hook) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	return NewChatService(repo, cfg, logger).(*chatService), hook
+}
+
+func (r *fakeRepository) GetChatByUsers(_ context.Context, userID1, userID2 string) (*models.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, chat := range r.chats {
+		if chat.Type == models.ChatTypeDirect &&
+			(chat.UserID1 == userID1 && chat.UserID2 == userID2 || chat.UserID1 == userID2 && chat.UserID2 == userID1) {
+			copied := *chat
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrChatNotFound
+}
+
+func (r *fakeRepository) GetChatsWithPeers(_ context.Context, userID string, peerIDs []string) ([]*models.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var chats []*models.Chat
+	for _, chat := range r.chats {
+		if chat.Type != models.ChatTypeDirect {
+			continue
+		}
+		for _, peerID := range peerIDs {
+			if chat.UserID1 == userID && chat.UserID2 == peerID || chat.UserID2 == userID && chat.UserID1 == peerID {
+				copied := *chat
+				chats = append(chats, &copied)
+			}
+		}
+	}
+	return chats, nil
+}
+
+// GetUserChats returns up to filter.Limit of the chats userID takes part in,
+// leaving out those without messages unless filter.IncludeEmpty is set. The
+// other filters are ignored.
+func (r *fakeRepository) GetUserChats(_ context.Context, userID string, filter repository.UserChatsFilter) ([]*models.Chat, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var chats []*models.Chat
+	for _, chat := range r.chats {
+		if chat.HasParticipant(userID) && (filter.IncludeEmpty || chat.MessageCount > 0) {
+			copied := *chat
+			chats = append(chats, &copied)
+		}
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].ID < chats[j].ID })
+	if filter.Limit > 0 && len(chats) > filter.Limit {
+		chats = chats[:filter.Limit]
+	}
+	return chats, nil
+}
+
+func (r *fakeRepository) CreateChat(_ context.Context, chat *models.Chat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	chat.Type = models.ChatTypeDirect
+	chat.ParticipantIDs = []string{chat.UserID1, chat.UserID2}
+	chat.CreatedAt, chat.UpdatedAt = now, now
+	copied := *chat
+	r.chats[chat.ID] = &copied
+	return nil
+}
+
+func (r *fakeRepository) CreateGroupChat(_ context.Context, chat *models.Chat) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	chat.Type = models.ChatTypeGroup
+	chat.CreatedAt, chat.UpdatedAt = now, now
+	copied := *chat
+	copied.ParticipantIDs = append([]string(nil), chat.ParticipantIDs...)
+	r.chats[chat.ID] = &copied
+	return nil
+}
+
+func (r *fakeRepository) IsBlockedBy(context.Context, string, []string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeRepository) GetAuditLog(_ context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.auditFilters = append(r.auditFilters, filter)
+	return nil, nil
+}
+
+func (r *fakeRepository) CreateMessage(_ context.Context, msg *models.Message, maxMessages int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.createErrs) > 0 {
+		err := r.createErrs[0]
+		r.createErrs = r.createErrs[1:]
+		return err
+	}
+	if _, ok := r.messages[msg.ID]; ok {
+		return repository.ErrDuplicateMessageID
+	}
+	if chat, ok := r.chats[msg.ChatID]; ok && maxMessages > 0 && chat.MessageCount >= maxMessages {
+		return repository.ErrChatQuotaExceeded
+	}
+
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now().UTC()
+	}
+	copied := *msg
+	r.messages[msg.ID] = &copied
+	if chat, ok := r.chats[msg.ChatID]; ok {
+		chat.MessageCount++
+	}
+	return nil
+}
+
+func (r *fakeRepository) GetMessageByID(_ context.Context, id string) (*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, ok := r.messages[id]
+	if !ok {
+		return nil, repository.ErrMessageNotFound
+	}
+	copied := *msg
+	return &copied, nil
+}
+
+func (r *fakeRepository) UpdateMessageContent(_ context.Context, msg *models.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.messages[msg.ID]
+	if !ok || stored.DeletedAt != nil {
+		return repository.ErrMessageNotFound
+	}
+
+	editedAt := time.Now().UTC()
+	r.edits[msg.ID] = append(r.edits[msg.ID], &models.MessageEdit{
+		MessageID:  msg.ID,
+		OldContent: stored.Content,
+		EditorID:   msg.SenderID,
+		EditedAt:   editedAt,
+	})
+	stored.Content = msg.Content
+	stored.EditedAt = &editedAt
+	msg.EditedAt = &editedAt
+	return nil
+}
+
+func (r *fakeRepository) GetMessageEdits(_ context.Context, messageID string) ([]*models.MessageEdit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*models.MessageEdit(nil), r.edits[messageID]...), nil
+}
+
+func (r *fakeRepository) ToggleReaction(_ context.Context, messageID, userID, emoji string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.reactions[messageID] == nil {
+		r.reactions[messageID] = make(map[string]bool)
+	}
+	key := userID + "/" + emoji
+	if r.reactions[messageID][key] {
+		delete(r.reactions[messageID], key)
+		return false, nil
+	}
+	r.reactions[messageID][key] = true
+	return true, nil
+}
+
+func (r *fakeRepository) SoftDeleteMessage(_ context.Context, id string, clearReactions bool) (time.Time, []*models.Reaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, ok := r.messages[id]
+	if !ok || msg.DeletedAt != nil {
+		return time.Time{}, nil, repository.ErrMessageNotFound
+	}
+	deletedAt := time.Now().UTC()
+	msg.DeletedAt = &deletedAt
+	if chat, ok := r.chats[msg.ChatID]; ok {
+		chat.MessageCount--
+	}
+
+	var cleared []*models.Reaction
+	if clearReactions {
+		for key := range r.reactions[id] {
+			userID, emoji, _ := strings.Cut(key, "/")
+			cleared = append(cleared, &models.Reaction{UserID: userID, Emoji: emoji})
+		}
+		delete(r.reactions, id)
+	}
+	return deletedAt, cleared, nil
+}
+
+func (r *fakeRepository) GetChatMessages(_ context.Context, chatID string, limit int, cursor *models.MessageCursor, includeDeleted bool) ([]*models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var messages []*models.Message
+	for _, msg := range r.sortedMessages(chatID) {
+		if cursor != nil {
+			before := msg.CreatedAt.Before(cursor.CreatedAt) ||
+				msg.CreatedAt.Equal(cursor.CreatedAt) && msg.ID < cursor.ID
+			after := !before && msg.ID != cursor.ID
+			if cursor.After && !after || !cursor.After && !before {
+				continue
+			}
+		}
+		if msg.DeletedAt != nil && !includeDeleted {
+			continue
+		}
+		copied := *msg
+		messages = append(messages, &copied)
+	}
+	if len(messages) > limit {
+		if cursor != nil && cursor.After {
+			messages = messages[:limit]
+		} else {
+			messages = messages[len(messages)-limit:]
+		}
+	}
+	return messages, nil
+}
+
+func (r *fakeRepository) GetAttachments(context.Context, []string) (map[string][]*models.Attachment, error) {
+	return nil, nil
+}
+
+func (r *fakeRepository) GetReactionCounts(_ context.Context, messageIDs []string, viewerID string) (map[string][]*models.ReactionCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reactionQueries++
+	counts := make(map[string][]*models.ReactionCount)
+	for _, id := range messageIDs {
+		byEmoji := make(map[string]*models.ReactionCount)
+		for key := range r.reactions[id] {
+			userID, emoji, _ := strings.Cut(key, "/")
+			rc, ok := byEmoji[emoji]
+			if !ok {
+				rc = &models.ReactionCount{Emoji: emoji}
+				byEmoji[emoji] = rc
+				counts[id] = append(counts[id], rc)
+			}
+			rc.Count++
+			rc.Reacted = rc.Reacted || userID == viewerID
+		}
+		sort.Slice(counts[id], func(i, j int) bool { return counts[id][i].Emoji < counts[id][j].Emoji })
+	}
+	return counts, nil
+}
+
+func (r *fakeRepository) GetReplyDepth(_ context.Context, messageID string, maxDepth int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	depth := 0
+	for msg := r.messages[messageID]; msg != nil && msg.ReplyToID != "" && depth < maxDepth; msg = r.messages[msg.ReplyToID] {
+		depth++
+	}
+	return depth, nil
+}
+
+func (r *fakeRepository) GetChatParticipants(_ context.Context, chatID string) ([]*models.Participant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.joins[chatID], nil
+}
+
+func (r *fakeRepository) GetMutedUserIDs(_ context.Context, chatID string, userIDs []string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var muted []string
+	for _, id := range userIDs {
+		if r.muted[chatID][id] {
+			muted = append(muted, id)
+		}
+	}
+	return muted, nil
+}
+
+// recordingPublisher keeps the events published to it. With hold set, each
+// publish waits until hold is closed.
+type recordingPublisher struct {
+	mu      sync.Mutex
+	created []*events.MessageCreated
+	hold    chan struct{}
+}
+
+func (p *recordingPublisher) PublishMessageCreated(_ context.Context, event *events.MessageCreated) error {
+	if p.hold != nil {
+		<-p.hold
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.created = append(p.created, event)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func (p *recordingPublisher) published() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.created)
+}
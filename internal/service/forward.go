@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ForwardMessage copies a message, with its attachments, into toChatID as a
+// new message from senderID. The sender must be in both chats. Forwarding a
+// forward keeps pointing at the original message.
+func (s *chatService) ForwardMessage(ctx context.Context, messageID, fromChatID, toChatID, senderID string) (*models.Message, error) {
+	senderID = identity(ctx, senderID)
+
+	if err := validateIDs(
+		idField{"message_id", messageID},
+		idField{"from_chat_id", fromChatID},
+		idField{"to_chat_id", toChatID},
+		idField{"sender_id", senderID},
+	); err != nil {
+		return nil, err
+	}
+
+	source, err := s.getVisibleMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.getParticipantChat(ctx, source.ChatID, senderID); err != nil {
+		return nil, err
+	}
+	if source.ChatID != canonicalID(fromChatID) {
+		return nil, repository.ErrMessageNotFound
+	}
+
+	rateLimit, err := s.checkRateLimit(ctx, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.getParticipantChat(ctx, toChatID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotBlocked(ctx, target, senderID); err != nil {
+		return nil, err
+	}
+
+	msg := &models.Message{
+		ID:              uuid.New().String(),
+		ChatID:          target.ID,
+		SenderID:        senderID,
+		ForwardedFromID: source.ID,
+		RateLimit:       rateLimit,
+	}
+	if source.ForwardedFromID != "" {
+		msg.ForwardedFromID = source.ForwardedFromID
+	}
+
+	if err := s.applyContent(msg, source.Content); err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.repository.GetAttachments(ctx, []string{source.ID})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get message attachments")
+		return nil, err
+	}
+	msg.Attachments = attachments[source.ID]
+
+	if err := s.persistMessage(ctx, target, msg, SendMessageOptions{}, false); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id":     msg.ID,
+		"forwarded_from": msg.ForwardedFromID,
+		"chat_id":        msg.ChatID,
+	}).Info("Message forwarded")
+
+	return msg, nil
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"metachat/chat-service/internal/metrics"
+	"metachat/chat-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultStreamBufferSize    = 64
+	defaultSlowConsumerTimeout = time.Second
+	defaultFanOutWorkers       = 4
+	// fanOutQueueSize is how many events each fan-out worker holds before
+	// publishing waits for it.
+	fanOutQueueSize = 1024
+)
+
+// What the hub does with a subscriber whose buffer is full.
+const (
+	// SlowConsumerDisconnect drops the subscriber so its stream ends and
+	// the client resubscribes.
+	SlowConsumerDisconnect = "disconnect"
+	// SlowConsumerDropOldest discards the oldest buffered event to make
+	// room for the new one.
+	SlowConsumerDropOldest = "drop_oldest"
+	// SlowConsumerBlock waits up to SlowConsumerTimeout for room, then
+	// disconnects.
+	SlowConsumerBlock = "block"
+)
+
+type subscription struct {
+	chatID string
+	events chan *models.ChatEvent
+	// done is closed when the subscription ends, releasing a publisher
+	// blocked on it.
+	done chan struct{}
+	// mu orders sends on events with closing it.
+	mu     sync.Mutex
+	closed bool
+}
+
+// fanOutItem is an event for a worker to deliver to the subscribers the
+// chat had when it was published or, with flushed set, a marker the worker
+// closes once everything queued before it has been delivered.
+type fanOutItem struct {
+	event   *models.ChatEvent
+	subs    []*subscription
+	flushed chan struct{}
+}
+
+// eventHub fans chat events out to in-process subscribers keyed by chat ID.
+// Delivery happens on a fixed pool of workers, so publishing returns without
+// waiting for subscribers. Each chat is always handled by the same worker,
+// which keeps its events in publish order for every subscriber.
+// A subscriber whose buffer is full is handled by the slow-consumer policy;
+// when it is dropped, its channel is closed so the stream can terminate and
+// the client reconnect.
+type eventHub struct {
+	mu           sync.RWMutex
+	subscribers  map[string]map[*subscription]struct{}
+	queues       []chan fanOutItem
+	bufferSize   int
+	policy       string
+	blockTimeout time.Duration
+	logger       *logrus.Logger
+}
+
+func newEventHub(cfg Config, logger *logrus.Logger) *eventHub {
+	bufferSize := cfg.StreamBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultStreamBufferSize
+	}
+	policy := cfg.SlowConsumerPolicy
+	if policy != SlowConsumerDropOldest && policy != SlowConsumerBlock {
+		policy = SlowConsumerDisconnect
+	}
+	blockTimeout := cfg.SlowConsumerTimeout
+	if blockTimeout <= 0 {
+		blockTimeout = defaultSlowConsumerTimeout
+	}
+	workers := cfg.FanOutWorkers
+	if workers <= 0 {
+		workers = defaultFanOutWorkers
+	}
+
+	h := &eventHub{
+		subscribers:  make(map[string]map[*subscription]struct{}),
+		queues:       make([]chan fanOutItem, workers),
+		bufferSize:   bufferSize,
+		policy:       policy,
+		blockTimeout: blockTimeout,
+		logger:       logger,
+	}
+	for i := range h.queues {
+		h.queues[i] = make(chan fanOutItem, fanOutQueueSize)
+		go h.fanOut(h.queues[i])
+	}
+	return h
+}
+
+func (h *eventHub) subscribe(chatID string) *subscription {
+	sub := &subscription{
+		chatID: chatID,
+		events: make(chan *models.ChatEvent, h.bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[chatID] == nil {
+		h.subscribers[chatID] = make(map[*subscription]struct{})
+	}
+	h.subscribers[chatID][sub] = struct{}{}
+
+	return sub
+}
+
+func (h *eventHub) unsubscribe(sub *subscription) {
+	h.mu.Lock()
+	subs, ok := h.subscribers[sub.chatID]
+	if _, found := subs[sub]; !ok || !found {
+		h.mu.Unlock()
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.chatID)
+	}
+	h.mu.Unlock()
+
+	close(sub.done)
+	sub.mu.Lock()
+	sub.closed = true
+	close(sub.events)
+	sub.mu.Unlock()
+}
+
+// publish queues event for delivery to the chat's current subscribers. It
+// only waits if the chat's worker has a full queue.
+func (h *eventHub) publish(event *models.ChatEvent) {
+	h.mu.RLock()
+	subs := make([]*subscription, 0, len(h.subscribers[event.ChatID]))
+	for sub := range h.subscribers[event.ChatID] {
+		subs = append(subs, sub)
+	}
+	h.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(event.ChatID))
+	h.queues[hash.Sum32()%uint32(len(h.queues))] <- fanOutItem{event: event, subs: subs}
+}
+
+// flush waits until every event published before it has been delivered.
+func (h *eventHub) flush() {
+	flushed := make([]chan struct{}, len(h.queues))
+	for i, queue := range h.queues {
+		flushed[i] = make(chan struct{})
+		queue <- fanOutItem{flushed: flushed[i]}
+	}
+	for _, done := range flushed {
+		<-done
+	}
+}
+
+func (h *eventHub) fanOut(queue <-chan fanOutItem) {
+	for item := range queue {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		for _, sub := range item.subs {
+			if !h.deliver(sub, item.event) {
+				h.logger.WithFields(logrus.Fields{
+					"chat_id": item.event.ChatID,
+					"policy":  h.policy,
+				}).Warn("Dropping slow stream subscriber")
+				h.unsubscribe(sub)
+			}
+		}
+	}
+}
+
+// deliver hands event to sub, applying the slow-consumer policy if its
+// buffer is full. It reports false if the subscriber should be dropped.
+func (h *eventHub) deliver(sub *subscription, event *models.ChatEvent) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.closed {
+		return true
+	}
+	select {
+	case sub.events <- event:
+		return true
+	default:
+	}
+
+	switch h.policy {
+	case SlowConsumerDropOldest:
+		// Only fan-out workers send, one at a time under sub.mu, so once
+		// an event is taken out there is room for this one.
+		select {
+		case <-sub.events:
+			metrics.StreamEventsDroppedTotal.Inc()
+		default:
+		}
+		sub.events <- event
+		return true
+	case SlowConsumerBlock:
+		timer := time.NewTimer(h.blockTimeout)
+		defer timer.Stop()
+		select {
+		case sub.events <- event:
+			return true
+		case <-sub.done:
+			return true
+		case <-timer.C:
+			return false
+		}
+	default:
+		return false
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"metachat/chat-service/internal/metrics"
+	"metachat/chat-service/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func newTestHub(cfg Config) *eventHub {
+	logger, _ := test.NewNullLogger()
+	return newEventHub(cfg, logger)
+}
+
+func publishN(hub *eventHub, from, to int) {
+	for i := from; i <= to; i++ {
+		hub.publish(&models.ChatEvent{Type: models.ChatEventTyping, ChatID: testChatID, UserID: fmt.Sprint(i)})
+	}
+}
+
+// drain reads what sub has buffered and reports whether its channel was
+// closed.
+func drain(sub *subscription) (got []string, closed bool) {
+	for {
+		select {
+		case event, ok := <-sub.events:
+			if !ok {
+				return got, true
+			}
+			got = append(got, event.UserID)
+		default:
+			return got, false
+		}
+	}
+}
+
+func TestHubSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		policy     string
+		wantEvents []string
+		wantClosed bool
+	}{
+		{"", []string{"1", "2"}, true},
+		{SlowConsumerDisconnect, []string{"1", "2"}, true},
+		{SlowConsumerDropOldest, []string{"2", "3"}, false},
+		{SlowConsumerBlock, []string{"1", "2"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("policy=%q", tt.policy), func(t *testing.T) {
+			hub := newTestHub(Config{
+				StreamBufferSize:    2,
+				SlowConsumerPolicy:  tt.policy,
+				SlowConsumerTimeout: 20 * time.Millisecond,
+			})
+			stalled := hub.subscribe(testChatID)
+			dropped := testutil.ToFloat64(metrics.StreamEventsDroppedTotal)
+
+			// The third event finds the stalled subscriber's buffer full.
+			publishN(hub, 1, 3)
+			hub.flush()
+
+			got, closed := drain(stalled)
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantEvents) || closed != tt.wantClosed {
+				t.Errorf("subscriber got %v, closed %v; want %v, closed %v", got, closed, tt.wantEvents, tt.wantClosed)
+			}
+			wantDropped := 0.0
+			if tt.policy == SlowConsumerDropOldest {
+				wantDropped = 1
+			}
+			if n := testutil.ToFloat64(metrics.StreamEventsDroppedTotal) - dropped; n != wantDropped {
+				t.Errorf("dropped events metric grew by %v, want %v", n, wantDropped)
+			}
+		})
+	}
+}
+
+func TestHubBlockPolicyWaitsForConsumer(t *testing.T) {
+	hub := newTestHub(Config{
+		StreamBufferSize:    1,
+		SlowConsumerPolicy:  SlowConsumerBlock,
+		SlowConsumerTimeout: 5 * time.Second,
+	})
+	sub := hub.subscribe(testChatID)
+
+	go func() {
+		// Catch up after the publisher has had to wait.
+		time.Sleep(20 * time.Millisecond)
+		for range sub.events {
+		}
+	}()
+
+	start := time.Now()
+	publishN(hub, 1, 3)
+	hub.flush()
+	if waited := time.Since(start); waited >= 5*time.Second {
+		t.Fatalf("publish waited %v, want it released once the consumer read", waited)
+	}
+
+	hub.mu.RLock()
+	_, subscribed := hub.subscribers[testChatID][sub]
+	hub.mu.RUnlock()
+	if !subscribed {
+		t.Error("consumer that caught up within the timeout was dropped")
+	}
+	hub.unsubscribe(sub)
+}
+
+func TestHubUnsubscribeReleasesBlockedPublisher(t *testing.T) {
+	hub := newTestHub(Config{
+		StreamBufferSize:    1,
+		SlowConsumerPolicy:  SlowConsumerBlock,
+		SlowConsumerTimeout: 5 * time.Second,
+	})
+	sub := hub.subscribe(testChatID)
+
+	publishN(hub, 1, 2)
+	time.Sleep(20 * time.Millisecond)
+	hub.unsubscribe(sub)
+
+	flushed := make(chan struct{})
+	go func() {
+		hub.flush()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("delivery still blocked after the subscriber left")
+	}
+}
+
+func TestHubPublishDoesNotWaitForSubscribers(t *testing.T) {
+	hub := newTestHub(Config{
+		StreamBufferSize:    1,
+		SlowConsumerPolicy:  SlowConsumerBlock,
+		SlowConsumerTimeout: time.Second,
+	})
+	for i := 0; i < 100; i++ {
+		sub := hub.subscribe(testChatID)
+		defer hub.unsubscribe(sub)
+	}
+
+	// Every subscriber is stalled, so delivering synchronously would wait
+	// out the block timeout on each event after the first.
+	start := time.Now()
+	publishN(hub, 1, 5)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("publishing took %v with stalled subscribers, want it not to wait", elapsed)
+	}
+}
+
+func TestHubKeepsPerChatOrder(t *testing.T) {
+	const chats, events = 8, 200
+	hub := newTestHub(Config{StreamBufferSize: events, FanOutWorkers: 3})
+
+	chatIDs := make([]string, chats)
+	subs := make([]*subscription, chats)
+	for i := range chatIDs {
+		chatIDs[i] = fmt.Sprintf("chat-%d", i)
+		subs[i] = hub.subscribe(chatIDs[i])
+	}
+
+	// Publish to every chat at once, each chat from its own goroutine.
+	var wg sync.WaitGroup
+	for _, chatID := range chatIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < events; n++ {
+				hub.publish(&models.ChatEvent{Type: models.ChatEventTyping, ChatID: chatID, UserID: fmt.Sprint(n)})
+			}
+		}()
+	}
+	wg.Wait()
+	hub.flush()
+
+	for i, sub := range subs {
+		got, _ := drain(sub)
+		if len(got) != events {
+			t.Fatalf("%s got %d events, want %d", chatIDs[i], len(got), events)
+		}
+		for n, userID := range got {
+			if userID != fmt.Sprint(n) {
+				t.Fatalf("%s event %d = %s, want events in publish order", chatIDs[i], n, userID)
+			}
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"github.com/abadojack/whatlanggo"
+)
+
+const (
+	LanguageDetectionOff    = "off"
+	LanguageDetectionTag    = "tag"
+	LanguageDetectionReject = "reject"
+)
+
+// detectLanguage returns the ISO 639-1 code of the content language, or an
+// empty string when the detector is not confident (e.g. very short messages).
+func detectLanguage(content string) string {
+	info := whatlanggo.Detect(content)
+	if !info.IsReliable() {
+		return ""
+	}
+	return info.Lang.Iso6391()
+}
+
+func (s *chatService) languageAllowed(language string) bool {
+	if language == "" || len(s.config.AllowedLanguages) == 0 {
+		return true
+	}
+	for _, allowed := range s.config.AllowedLanguages {
+		if allowed == language {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"metachat/chat-service/internal/auth"
+)
+
+const (
+	englishSample = "The weather is lovely today, shall we go for a walk in the park after lunch?"
+	germanSample  = "Das Wetter ist heute wunderschön, wollen wir nach dem Mittagessen im Park spazieren gehen?"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{englishSample, "en"},
+		{germanSample, "de"},
+		{"Il fait très beau aujourd'hui, allons-nous nous promener dans le parc après le déjeuner ?", "fr"},
+		{"Hoy hace muy buen tiempo. ¿Quieres que vayamos a caminar por el parque después de la comida con los niños?", "es"},
+		{"Сегодня прекрасная погода, может быть, погуляем в парке после обеда?", "ru"},
+		// Too short to call.
+		{"ok", ""},
+		{"👍", ""},
+	}
+
+	for _, tt := range tests {
+		if got := detectLanguage(tt.content); got != tt.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestSendMessageLanguageModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		content  string
+		wantErr  error
+		wantLang string
+	}{
+		{"off", LanguageDetectionOff, germanSample, nil, ""},
+		{"tag", LanguageDetectionTag, germanSample, nil, "de"},
+		{"reject allowed", LanguageDetectionReject, englishSample, nil, "en"},
+		{"reject other", LanguageDetectionReject, germanSample, ErrLanguageNotAllowed, ""},
+		// Messages the detector can't call are let through.
+		{"reject unsure", LanguageDetectionReject, "ok", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addChat(testChatID, testUserID, testOtherID)
+			svc, _ := newTestService(repo, Config{LanguageDetection: tt.mode, AllowedLanguages: []string{"en"}})
+			ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+			msg, err := svc.SendMessage(ctx, testChatID, "", tt.content, SendMessageOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SendMessage() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if len(repo.messages) != 0 {
+					t.Errorf("rejected message was stored")
+				}
+				return
+			}
+			if msg.Language != tt.wantLang || repo.messages[msg.ID].Language != tt.wantLang {
+				t.Errorf("language = %q, stored %q; want %q", msg.Language, repo.messages[msg.ID].Language, tt.wantLang)
+			}
+		})
+	}
+}
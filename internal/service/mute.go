@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MuteChat silences push notifications from the chat for userID, until the
+// given time if one is set or otherwise until UnmuteChat.
+func (s *chatService) MuteChat(ctx context.Context, chatID, userID string, until *time.Time) error {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return err
+	}
+
+	if until != nil && !until.After(time.Now().UTC()) {
+		return ErrInvalidTimeRange
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repository.SetChatMute(ctx, chatID, userID, true, until); err != nil {
+		s.logger.WithError(err).Error("Failed to mute chat")
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"chat_id": chatID,
+		"user_id": userID,
+	}).Info("Chat muted")
+
+	return nil
+}
+
+func (s *chatService) UnmuteChat(ctx context.Context, chatID, userID string) error {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repository.SetChatMute(ctx, chatID, userID, false, nil); err != nil {
+		s.logger.WithError(err).Error("Failed to unmute chat")
+		return err
+	}
+
+	return nil
+}
+
+func withoutIDs(ids, exclude []string) []string {
+	if len(exclude) == 0 {
+		return ids
+	}
+
+	skip := make(map[string]bool, len(exclude))
+	for _, id := range exclude {
+		skip[id] = true
+	}
+
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !skip[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
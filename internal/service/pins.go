@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+
+	"metachat/chat-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PinMessage pins a message in its chat. Any participant may pin, and
+// pinning an already pinned message succeeds without changing who pinned it.
+func (s *chatService) PinMessage(ctx context.Context, messageID, userID string) error {
+	userID = identity(ctx, userID)
+
+	msg, err := s.getPinnableMessage(ctx, messageID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.PinMessage(ctx, msg, userID, s.config.MaxPinsPerChat); err != nil {
+		s.logger.WithError(err).Error("Failed to pin message")
+		return err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"message_id": messageID,
+		"chat_id":    msg.ChatID,
+		"user_id":    userID,
+	}).Info("Message pinned")
+
+	return nil
+}
+
+func (s *chatService) UnpinMessage(ctx context.Context, messageID, userID string) error {
+	userID = identity(ctx, userID)
+
+	if _, err := s.getPinnableMessage(ctx, messageID, userID); err != nil {
+		return err
+	}
+
+	if err := s.repository.UnpinMessage(ctx, messageID, userID); err != nil {
+		s.logger.WithError(err).Error("Failed to unpin message")
+		return err
+	}
+
+	return nil
+}
+
+func (s *chatService) GetPinnedMessages(ctx context.Context, chatID, userID string) ([]*models.PinnedMessage, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	pins, err := s.repository.GetPinnedMessages(ctx, chatID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get pinned messages")
+		return nil, err
+	}
+
+	return pins, nil
+}
+
+func (s *chatService) getPinnableMessage(ctx context.Context, messageID, userID string) (*models.Message, error) {
+	if err := validateIDs(idField{"message_id", messageID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.getVisibleMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, msg.ChatID, userID); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
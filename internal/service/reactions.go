@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"metachat/chat-service/internal/models"
+)
+
+var ErrInvalidReaction = errors.New("invalid reaction")
+
+const maxReactionLength = 16
+
+// What happens to a message's reactions when it is deleted.
+const (
+	ReactionsOnDeleteClear = "clear"
+	ReactionsOnDeleteKeep  = "keep"
+)
+
+func validateReaction(emoji string) error {
+	if strings.TrimSpace(emoji) == "" {
+		return fmt.Errorf("%w: emoji is required", ErrInvalidReaction)
+	}
+	if utf8.RuneCountInString(emoji) > maxReactionLength {
+		return fmt.Errorf("%w: emoji is too long", ErrInvalidReaction)
+	}
+	return nil
+}
+
+func (s *chatService) AddReaction(ctx context.Context, messageID, userID, emoji string) error {
+	userID = identity(ctx, userID)
+
+	msg, err := s.checkReaction(ctx, messageID, userID, emoji)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.AddReaction(ctx, messageID, userID, emoji); err != nil {
+		s.logger.WithError(err).Error("Failed to add reaction")
+		return err
+	}
+
+	s.publishReaction(models.ChatEventReactionAdded, msg, userID, emoji)
+	return nil
+}
+
+func (s *chatService) RemoveReaction(ctx context.Context, messageID, userID, emoji string) error {
+	userID = identity(ctx, userID)
+
+	msg, err := s.checkReaction(ctx, messageID, userID, emoji)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.RemoveReaction(ctx, messageID, userID, emoji); err != nil {
+		s.logger.WithError(err).Error("Failed to remove reaction")
+		return err
+	}
+
+	s.publishReaction(models.ChatEventReactionRemoved, msg, userID, emoji)
+	return nil
+}
+
+// ToggleReaction adds the caller's reaction if absent and removes it if
+// present, returning whether they have reacted afterwards. The repository
+// applies concurrent toggles one at a time, so two rapid toggles always
+// cancel out.
+func (s *chatService) ToggleReaction(ctx context.Context, messageID, userID, emoji string) (bool, error) {
+	userID = identity(ctx, userID)
+
+	msg, err := s.checkReaction(ctx, messageID, userID, emoji)
+	if err != nil {
+		return false, err
+	}
+
+	reacted, err := s.repository.ToggleReaction(ctx, messageID, userID, emoji)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to toggle reaction")
+		return false, err
+	}
+
+	eventType := models.ChatEventReactionRemoved
+	if reacted {
+		eventType = models.ChatEventReactionAdded
+	}
+	s.publishReaction(eventType, msg, userID, emoji)
+	return reacted, nil
+}
+
+func (s *chatService) publishReaction(eventType models.ChatEventType, msg *models.Message, userID, emoji string) {
+	s.hub.publish(&models.ChatEvent{
+		Type:    eventType,
+		ChatID:  msg.ChatID,
+		Message: &models.Message{ID: msg.ID, ChatID: msg.ChatID},
+		UserID:  userID,
+		Emoji:   emoji,
+	})
+}
+
+// checkReaction validates the reaction and that userID may react to the
+// message, i.e. it exists, is not deleted and userID is in its chat.
+func (s *chatService) checkReaction(ctx context.Context, messageID, userID, emoji string) (*models.Message, error) {
+	if err := validateIDs(idField{"message_id", messageID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+	if err := validateReaction(emoji); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.getVisibleMessage(ctx, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, msg.ChatID, userID); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// attachReactions sets each message's reaction counts, flagging those
+// viewerID is part of, with one repository call for the whole page.
+func (s *chatService) attachReactions(ctx context.Context, messages []*models.Message, viewerID string) error {
+	ids := make([]string, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.ID
+	}
+
+	counts, err := s.repository.GetReactionCounts(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		msg.Reactions = counts[msg.ID]
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"metachat/chat-service/internal/auth"
+	"metachat/chat-service/internal/models"
+)
+
+func TestToggleReactionPublishesEachChange(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+	ctx, cancel := context.WithCancel(auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID}))
+	defer cancel()
+
+	msg, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	events, err := svc.SubscribeChatEvents(ctx, testChatID, "")
+	if err != nil {
+		t.Fatalf("SubscribeChatEvents() error = %v", err)
+	}
+
+	want := []struct {
+		reacted bool
+		event   models.ChatEventType
+	}{
+		{true, models.ChatEventReactionAdded},
+		{false, models.ChatEventReactionRemoved},
+	}
+	for i, w := range want {
+		reacted, err := svc.ToggleReaction(ctx, msg.ID, "", "👍")
+		if err != nil {
+			t.Fatalf("toggle %d: error = %v", i+1, err)
+		}
+		if reacted != w.reacted {
+			t.Errorf("toggle %d: reacted = %v, want %v", i+1, reacted, w.reacted)
+		}
+		event := <-events
+		if event.Type != w.event || event.Emoji != "👍" || event.UserID != testUserID {
+			t.Errorf("toggle %d: event = %+v, want %s", i+1, event, w.event)
+		}
+	}
+}
+
+func TestDeleteMessageReactionPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		kept   bool
+	}{
+		{"default", "", false},
+		{"clear", ReactionsOnDeleteClear, false},
+		{"keep", ReactionsOnDeleteKeep, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeRepository()
+			repo.addChat(testChatID, testUserID, testOtherID)
+			svc, _ := newTestService(repo, Config{ReactionsOnDelete: tt.policy})
+			ctx, cancel := context.WithCancel(auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID}))
+			defer cancel()
+
+			msg, err := svc.SendMessage(ctx, testChatID, "", "hello", SendMessageOptions{})
+			if err != nil {
+				t.Fatalf("SendMessage() error = %v", err)
+			}
+			if _, err := svc.ToggleReaction(ctx, msg.ID, "", "👍"); err != nil {
+				t.Fatalf("ToggleReaction() error = %v", err)
+			}
+			events, err := svc.SubscribeChatEvents(ctx, testChatID, "")
+			if err != nil {
+				t.Fatalf("SubscribeChatEvents() error = %v", err)
+			}
+
+			if err := svc.DeleteMessage(ctx, msg.ID, ""); err != nil {
+				t.Fatalf("DeleteMessage() error = %v", err)
+			}
+			svc.hub.flush()
+
+			if kept := len(repo.reactions[msg.ID]) > 0; kept != tt.kept {
+				t.Errorf("reactions kept = %v, want %v", kept, tt.kept)
+			}
+			if event := <-events; event.Type != models.ChatEventMessageDeleted {
+				t.Fatalf("first event = %s, want %s", event.Type, models.ChatEventMessageDeleted)
+			}
+			select {
+			case event := <-events:
+				if tt.kept {
+					t.Errorf("unexpected %s event with reactions kept", event.Type)
+				} else if event.Type != models.ChatEventReactionRemoved || event.UserID != testUserID || event.Emoji != "👍" {
+					t.Errorf("event = %+v, want the reaction removed", event)
+				}
+			default:
+				if !tt.kept {
+					t.Errorf("no %s event for the cleared reaction", models.ChatEventReactionRemoved)
+				}
+			}
+		})
+	}
+}
+
+func TestGetChatMessagesAggregatesReactions(t *testing.T) {
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{})
+	ctx := context.Background()
+	userCtx := auth.WithPrincipal(ctx, auth.Principal{UserID: testUserID})
+	otherCtx := auth.WithPrincipal(ctx, auth.Principal{UserID: testOtherID})
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		msg, err := svc.SendMessage(userCtx, testChatID, "", "hello", SendMessageOptions{})
+		if err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+		ids = append(ids, msg.ID)
+	}
+	// The first message has a reaction from each user, the second only
+	// one from the other user and the third none.
+	for _, r := range []struct {
+		ctx   context.Context
+		msgID string
+		emoji string
+	}{
+		{userCtx, ids[0], "👍"},
+		{otherCtx, ids[0], "👍"},
+		{otherCtx, ids[0], "🎉"},
+		{otherCtx, ids[1], "👍"},
+	} {
+		if _, err := svc.ToggleReaction(r.ctx, r.msgID, "", r.emoji); err != nil {
+			t.Fatalf("ToggleReaction() error = %v", err)
+		}
+	}
+
+	page, err := svc.GetChatMessages(userCtx, testChatID, "", 10, "", false)
+	if err != nil {
+		t.Fatalf("GetChatMessages() error = %v", err)
+	}
+	if repo.reactionQueries != 1 {
+		t.Errorf("reaction queries = %d, want 1 for the page", repo.reactionQueries)
+	}
+
+	want := map[string][]models.ReactionCount{
+		ids[0]: {{Emoji: "🎉", Count: 1}, {Emoji: "👍", Count: 2, Reacted: true}},
+		ids[1]: {{Emoji: "👍", Count: 1}},
+		ids[2]: nil,
+	}
+	if len(page.Messages) != len(want) {
+		t.Fatalf("page has %d messages, want %d", len(page.Messages), len(want))
+	}
+	for _, msg := range page.Messages {
+		var got []models.ReactionCount
+		for _, rc := range msg.Reactions {
+			got = append(got, *rc)
+		}
+		if !slices.Equal(got, want[msg.ID]) {
+			t.Errorf("message %s reactions = %v, want %v", msg.ID, got, want[msg.ID])
+		}
+	}
+}
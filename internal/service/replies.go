@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"metachat/chat-service/internal/models"
+	"metachat/chat-service/internal/repository"
+)
+
+var (
+	ErrInvalidReply = errors.New("invalid reply target")
+	ErrReplyTooDeep = errors.New("reply chain too deep")
+)
+
+const replyPreviewLength = 100
+
+// resolveReplyTo checks that the replied-to message exists, isn't deleted,
+// belongs to the chat being sent to and, with MaxReplyDepth set, that
+// replying to it keeps the chain within that depth.
+func (s *chatService) resolveReplyTo(ctx context.Context, chatID, replyToID string) error {
+	if err := validateIDs(idField{"reply_to_message_id", replyToID}); err != nil {
+		return err
+	}
+
+	parent, err := s.getVisibleMessage(ctx, replyToID)
+	if errors.Is(err, repository.ErrMessageNotFound) {
+		return fmt.Errorf("%w: message does not exist", ErrInvalidReply)
+	}
+	if err != nil {
+		return err
+	}
+
+	if parent.ChatID != chatID {
+		return fmt.Errorf("%w: message is in another chat", ErrInvalidReply)
+	}
+
+	if maxDepth := s.config.MaxReplyDepth; maxDepth > 0 {
+		depth, err := s.repository.GetReplyDepth(ctx, replyToID, maxDepth)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to get reply depth")
+			return err
+		}
+		// The reply would sit one level below its parent.
+		if depth >= maxDepth {
+			return fmt.Errorf("%w: replies may be at most %d deep", ErrReplyTooDeep, maxDepth)
+		}
+	}
+	return nil
+}
+
+// GetThreadReplies returns the replies to a message, oldest first.
+func (s *chatService) GetThreadReplies(ctx context.Context, parentMessageID, userID string) ([]*models.Message, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"message_id", parentMessageID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	parent, err := s.getVisibleMessage(ctx, parentMessageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, parent.ChatID, userID); err != nil {
+		return nil, err
+	}
+
+	replies, err := s.repository.GetReplies(ctx, parentMessageID)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get thread replies")
+		return nil, err
+	}
+
+	return replies, nil
+}
+
+// attachReplyPreviews quotes the start of each replied-to message. Parents
+// that have since been deleted get no preview.
+func (s *chatService) attachReplyPreviews(ctx context.Context, messages []*models.Message) error {
+	var ids []string
+	for _, msg := range messages {
+		if msg.ReplyToID != "" {
+			ids = append(ids, msg.ReplyToID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	parents, err := s.repository.GetMessagesByIDs(ctx, distinctIDs(ids))
+	if err != nil {
+		return err
+	}
+
+	previews := make(map[string]string, len(parents))
+	for _, parent := range parents {
+		if parent.DeletedAt == nil {
+			previews[parent.ID] = truncateRunes(parent.Content, replyPreviewLength)
+		}
+	}
+
+	for _, msg := range messages {
+		msg.ReplyPreview = previews[msg.ReplyToID]
+	}
+	return nil
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
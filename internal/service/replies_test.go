@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"metachat/chat-service/internal/auth"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		n    int
+		want string
+	}{
+		{"empty", "", 5, ""},
+		{"shorter than limit", "héllo", 10, "héllo"},
+		{"exactly at limit", "héllo", 5, "héllo"},
+		{"cuts between multibyte runes", "héllo", 2, "hé…"},
+		{"emoji", "👋🙂👍", 2, "👋🙂…"},
+		{"cjk", "你好世界", 3, "你好世…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateRunes(tt.s, tt.n); got != tt.want {
+				t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendMessageLimitsReplyDepth(t *testing.T) {
+	const maxDepth = 3
+	repo := newFakeRepository()
+	repo.addChat(testChatID, testUserID, testOtherID)
+	svc, _ := newTestService(repo, Config{MaxReplyDepth: maxDepth})
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: testUserID})
+
+	root, err := svc.SendMessage(ctx, testChatID, "", "root", SendMessageOptions{})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	// Build the chain down to the limit; each reply answers the last one.
+	parent := root
+	for depth := 1; depth <= maxDepth; depth++ {
+		reply, err := svc.SendMessage(ctx, testChatID, "", fmt.Sprintf("reply %d", depth), SendMessageOptions{ReplyToID: parent.ID})
+		if err != nil {
+			t.Fatalf("reply at depth %d: error = %v", depth, err)
+		}
+		parent = reply
+	}
+
+	if _, err := svc.SendMessage(ctx, testChatID, "", "too deep", SendMessageOptions{ReplyToID: parent.ID}); !errors.Is(err, ErrReplyTooDeep) {
+		t.Errorf("reply at depth %d: error = %v, want ErrReplyTooDeep", maxDepth+1, err)
+	}
+	// Branching off higher up the chain is still allowed.
+	if _, err := svc.SendMessage(ctx, testChatID, "", "another branch", SendMessageOptions{ReplyToID: root.ID}); err != nil {
+		t.Errorf("reply to the root: error = %v", err)
+	}
+}
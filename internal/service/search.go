@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"metachat/chat-service/internal/models"
+)
+
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+const (
+	maxSearchQueryLength = 100
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 50
+	searchContextSize    = 2
+	snippetRadius        = 40
+)
+
+func normalizeSearch(query string, limit int) (string, int, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return "", 0, fmt.Errorf("%w: query is required", ErrInvalidSearchQuery)
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return "", 0, fmt.Errorf("%w: maximum is %d characters", ErrInvalidSearchQuery, maxSearchQueryLength)
+	}
+
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	return query, limit, nil
+}
+
+// SearchMessages finds messages in a chat matching query, newest first, each
+// with a couple of the messages around it for context.
+func (s *chatService) SearchMessages(ctx context.Context, chatID, userID, query string, limit int) ([]*models.SearchHit, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	query, limit, err := normalizeSearch(query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	matches, err := s.repository.SearchChatMessages(ctx, chatID, query, limit)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to search messages")
+		return nil, err
+	}
+
+	if len(matches) == 0 {
+		return []*models.SearchHit{}, nil
+	}
+
+	ids := make([]string, len(matches))
+	for i, msg := range matches {
+		ids[i] = msg.ID
+	}
+
+	surrounding, err := s.repository.GetMessageContext(ctx, chatID, ids, searchContextSize)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to get search context")
+		return nil, err
+	}
+
+	return searchHits(matches, surrounding, searchContextSize), nil
+}
+
+// searchHits pairs each match with up to radius messages either side of it.
+// surrounding holds the matches' neighbourhoods in chronological order, so a
+// match's context is the messages next to it in that slice.
+func searchHits(matches, surrounding []*models.Message, radius int) []*models.SearchHit {
+	positions := make(map[string]int, len(surrounding))
+	for i, msg := range surrounding {
+		positions[msg.ID] = i
+	}
+
+	hits := make([]*models.SearchHit, len(matches))
+	for i, msg := range matches {
+		hit := &models.SearchHit{Message: msg}
+		if pos, ok := positions[msg.ID]; ok {
+			start := max(pos-radius, 0)
+			end := min(pos+1+radius, len(surrounding))
+			hit.Before = surrounding[start:pos:pos]
+			hit.After = surrounding[pos+1 : end : end]
+		}
+		hits[i] = hit
+	}
+	return hits
+}
+
+// SearchAllMessages searches every chat userID belongs to. Hits are grouped
+// by chat, with chats ordered by their newest hit; cursor continues from a
+// previous page's NextCursor.
+func (s *chatService) SearchAllMessages(ctx context.Context, userID, query string, limit int, cursor string) (*models.SearchResultPage, error) {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"user_id", userID}); err != nil {
+		return nil, err
+	}
+
+	query, limit, err := normalizeSearch(query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var before *models.MessageCursor
+	if cursor != "" {
+		if before, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+		if before.After {
+			return nil, fmt.Errorf("%w: search only pages back", ErrInvalidCursor)
+		}
+	}
+
+	matches, err := s.repository.SearchUserMessages(ctx, userID, query, limit+1, before)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to search user messages")
+		return nil, err
+	}
+
+	page := &models.SearchResultPage{}
+	if len(matches) > limit {
+		matches = matches[:limit]
+		page.NextCursor = encodeCursor(matches[limit-1])
+	}
+
+	groups := make(map[string]*models.ChatSearchResults)
+	for _, msg := range matches {
+		group, ok := groups[msg.ChatID]
+		if !ok {
+			group = &models.ChatSearchResults{ChatID: msg.ChatID}
+			groups[msg.ChatID] = group
+			page.Chats = append(page.Chats, group)
+		}
+		group.Hits = append(group.Hits, &models.SearchSnippet{
+			Message: msg,
+			Snippet: snippet(msg.Content, query),
+		})
+	}
+
+	return page, nil
+}
+
+// snippet cuts content down to the first case-insensitive occurrence of query
+// with some surrounding text, falling back to the start of the content when
+// the query doesn't occur literally (e.g. full-text matches).
+func snippet(content, query string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	needle := []rune(strings.ToLower(query))
+
+	start := 0
+	if len(lower) == len(runes) {
+		if i := indexRunes(lower, needle); i >= 0 {
+			start = i
+		}
+	}
+
+	from := max(start-snippetRadius, 0)
+	to := min(start+len(needle)+snippetRadius, len(runes))
+
+	out := string(runes[from:to])
+	if from > 0 {
+		out = "…" + out
+	}
+	if to < len(runes) {
+		out += "…"
+	}
+	return out
+}
+
+func indexRunes(haystack, needle []rune) int {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if string(haystack[i:i+len(needle)]) == string(needle) {
+			return i
+		}
+	}
+	return -1
+}
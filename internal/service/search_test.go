@@ -0,0 +1,136 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"metachat/chat-service/internal/models"
+)
+
+func TestSnippet(t *testing.T) {
+	pad := func(s string, n int) string { return strings.Repeat(s, n) }
+
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		want    string
+	}{
+		{
+			name:    "short content is kept whole",
+			content: "héllo wörld",
+			query:   "wörld",
+			want:    "héllo wörld",
+		},
+		{
+			name:    "window counts runes not bytes",
+			content: pad("é", 50) + "match" + pad("ö", 50),
+			query:   "match",
+			want:    "…" + pad("é", 40) + "match" + pad("ö", 40) + "…",
+		},
+		{
+			name:    "case-insensitive",
+			content: pad("x", 45) + "MATCH",
+			query:   "match",
+			want:    "…" + pad("x", 40) + "MATCH",
+		},
+		{
+			name:    "emoji around match",
+			content: pad("👋", 41) + "hi" + pad("🙂", 3),
+			query:   "hi",
+			want:    "…" + pad("👋", 40) + "hi" + pad("🙂", 3),
+		},
+		{
+			name:    "no match starts at the beginning",
+			content: pad("ä", 60),
+			query:   "zz",
+			want:    pad("ä", 42) + "…",
+		},
+		{
+			name:    "case folding keeps rune positions",
+			content: "İ" + pad("a", 50) + "Match",
+			query:   "match",
+			want:    "…" + pad("a", 40) + "Match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snippet(tt.content, tt.query); got != tt.want {
+				t.Errorf("snippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchHits(t *testing.T) {
+	ids := func(messages []*models.Message) []string {
+		out := []string{}
+		for _, msg := range messages {
+			out = append(out, msg.ID)
+		}
+		return out
+	}
+	chain := func(names ...string) []*models.Message {
+		messages := make([]*models.Message, len(names))
+		for i, name := range names {
+			messages[i] = &models.Message{ID: name}
+		}
+		return messages
+	}
+
+	tests := []struct {
+		name        string
+		matches     []string
+		surrounding []string
+		wantBefore  [][]string
+		wantAfter   [][]string
+	}{
+		{
+			name:        "full context",
+			matches:     []string{"c"},
+			surrounding: []string{"a", "b", "c", "d", "e"},
+			wantBefore:  [][]string{{"a", "b"}},
+			wantAfter:   [][]string{{"d", "e"}},
+		},
+		{
+			name:        "at the edges of the chat",
+			matches:     []string{"b", "a"},
+			surrounding: []string{"a", "b", "c", "d"},
+			wantBefore:  [][]string{{"a"}, {}},
+			wantAfter:   [][]string{{"c", "d"}, {"b", "c"}},
+		},
+		{
+			name:        "separate neighbourhoods",
+			matches:     []string{"h", "c"},
+			surrounding: []string{"a", "b", "c", "d", "e", "f", "g", "h", "i"},
+			wantBefore:  [][]string{{"f", "g"}, {"a", "b"}},
+			wantAfter:   [][]string{{"i"}, {"d", "e"}},
+		},
+		{
+			name:        "match missing from context",
+			matches:     []string{"x"},
+			surrounding: []string{"a", "b"},
+			wantBefore:  [][]string{{}},
+			wantAfter:   [][]string{{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := searchHits(chain(tt.matches...), chain(tt.surrounding...), 2)
+			for i, hit := range hits {
+				if hit.Message.ID != tt.matches[i] {
+					t.Errorf("hit %d is %q, want %q", i, hit.Message.ID, tt.matches[i])
+				}
+				if got := ids(hit.Before); !reflect.DeepEqual(got, tt.wantBefore[i]) {
+					t.Errorf("hit %q Before = %v, want %v", hit.Message.ID, got, tt.wantBefore[i])
+				}
+				if got := ids(hit.After); !reflect.DeepEqual(got, tt.wantAfter[i]) {
+					t.Errorf("hit %q After = %v, want %v", hit.Message.ID, got, tt.wantAfter[i])
+				}
+			}
+		})
+	}
+}
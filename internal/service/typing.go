@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"metachat/chat-service/internal/models"
+)
+
+const defaultTypingTTL = 5 * time.Second
+
+// SendTypingEvent broadcasts an ephemeral typing indicator to the chat's
+// subscribers. Nothing is persisted; the event carries an expiry after which
+// clients should clear the indicator unless a fresh one arrives.
+func (s *chatService) SendTypingEvent(ctx context.Context, chatID, userID string) error {
+	userID = identity(ctx, userID)
+
+	if err := validateIDs(idField{"chat_id", chatID}, idField{"user_id", userID}); err != nil {
+		return err
+	}
+
+	if _, err := s.getParticipantChat(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	ttl := s.config.TypingTTL
+	if ttl <= 0 {
+		ttl = defaultTypingTTL
+	}
+
+	s.hub.publish(&models.ChatEvent{
+		Type:      models.ChatEventTyping,
+		ChatID:    chatID,
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+
+	return nil
+}
+
+// StreamTypingEvents is SubscribeChatEvents narrowed to other participants'
+// typing indicators that have not yet expired.
+func (s *chatService) StreamTypingEvents(ctx context.Context, chatID, userID string) (<-chan *models.ChatEvent, error) {
+	userID = identity(ctx, userID)
+
+	events, err := s.SubscribeChatEvents(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	typing := make(chan *models.ChatEvent)
+	go func() {
+		defer close(typing)
+		for event := range events {
+			if event.Type != models.ChatEventTyping || event.UserID == userID || time.Now().After(event.ExpiresAt) {
+				continue
+			}
+			select {
+			case typing <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return typing, nil
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/rivo/uniseg"
+)
+
+var (
+	ErrInvalidID      = errors.New("invalid id")
+	ErrEmptyMessage   = errors.New("message content is empty")
+	ErrMessageTooLong = errors.New("message content is too long")
+)
+
+const defaultMaxMessageLength = 4000
+
+// Length modes decide what a character is when checking content against
+// MaxMessageLength: a code point, a UTF-16 code unit as JavaScript counts
+// them, or a user-perceived character (grapheme cluster).
+const (
+	LengthModeRunes     = "runes"
+	LengthModeUTF16     = "utf16"
+	LengthModeGraphemes = "graphemes"
+)
+
+// Oversize policies decide what happens to content over MaxMessageLength:
+// it is rejected, or cut to fit and ended with the truncation marker.
+const (
+	OversizePolicyReject   = "reject"
+	OversizePolicyTruncate = "truncate"
+)
+
+const defaultTruncationMarker = "…"
+
+type idField struct {
+	name  string
+	value string
+}
+
+// validateIDs rejects empty or non-UUID identifiers before they reach
+// Postgres, where they would otherwise surface as opaque internal errors.
+func validateIDs(fields ...idField) error {
+	for _, field := range fields {
+		if field.value == "" {
+			return fmt.Errorf("%w: %s is required", ErrInvalidID, field.name)
+		}
+		if _, err := uuid.Parse(field.value); err != nil {
+			return fmt.Errorf("%w: %s must be a valid UUID", ErrInvalidID, field.name)
+		}
+	}
+	return nil
+}
+
+// canonicalID returns the lowercase hyphenated form Postgres returns for a
+// UUID, so IDs from requests compare equal to stored ones.
+func canonicalID(id string) string {
+	if parsed, err := uuid.Parse(id); err == nil {
+		return parsed.String()
+	}
+	return id
+}
+
+func validateIDList(name string, ids []string) error {
+	for _, id := range ids {
+		if err := validateIDs(idField{name, id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *chatService) validateContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return ErrEmptyMessage
+	}
+
+	maxLength := s.maxMessageLength()
+	if contentLength(content, s.lengthMode()) > maxLength {
+		return fmt.Errorf("%w: maximum is %d characters", ErrMessageTooLong, maxLength)
+	}
+
+	return nil
+}
+
+func (s *chatService) maxMessageLength() int {
+	if s.config.MaxMessageLength <= 0 {
+		return defaultMaxMessageLength
+	}
+	return s.config.MaxMessageLength
+}
+
+func (s *chatService) oversizePolicy() string {
+	if s.config.OversizePolicy == OversizePolicyTruncate {
+		return OversizePolicyTruncate
+	}
+	return OversizePolicyReject
+}
+
+// fitContent applies the oversize policy: under OversizePolicyTruncate,
+// content over the limit is cut to fit with the marker appended; otherwise
+// it is returned as is for validateContent to reject.
+func (s *chatService) fitContent(content string) string {
+	if s.oversizePolicy() != OversizePolicyTruncate {
+		return content
+	}
+
+	marker := s.config.TruncationMarker
+	if marker == "" {
+		marker = defaultTruncationMarker
+	}
+	return truncateContent(content, s.maxMessageLength(), s.lengthMode(), marker)
+}
+
+// truncateContent cuts content to at most maxLength characters counted in
+// mode, marker included. It only cuts between grapheme clusters, so no
+// user-perceived character is split whatever the mode; the marker is left
+// out if it doesn't fit on its own.
+func truncateContent(content string, maxLength int, mode, marker string) string {
+	if contentLength(content, mode) <= maxLength {
+		return content
+	}
+
+	budget := maxLength - contentLength(marker, mode)
+	if budget < 0 {
+		budget, marker = maxLength, ""
+	}
+
+	end, length := 0, 0
+	state := -1
+	for rest := content; rest != ""; {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		n := contentLength(cluster, mode)
+		if length+n > budget {
+			break
+		}
+		length += n
+		end += len(cluster)
+	}
+
+	return content[:end] + marker
+}
+
+// lengthMode is the configured length mode, falling back to runes when it is
+// unset or unknown.
+func (s *chatService) lengthMode() string {
+	switch s.config.LengthMode {
+	case LengthModeUTF16, LengthModeGraphemes:
+		return s.config.LengthMode
+	default:
+		return LengthModeRunes
+	}
+}
+
+// contentLength counts content's characters the way mode defines them.
+func contentLength(content, mode string) int {
+	switch mode {
+	case LengthModeUTF16:
+		n := 0
+		for _, r := range content {
+			n += utf16.RuneLen(r)
+		}
+		return n
+	case LengthModeGraphemes:
+		return uniseg.GraphemeClusterCount(content)
+	default:
+		return utf8.RuneCountInString(content)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	testUserID  = "6f1c2a9e-3b4d-4c5e-8f7a-1b2c3d4e5f60"
+	testOtherID = "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+)
+
+func TestValidateIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []idField
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"valid", []idField{{"user_id", testUserID}}, false},
+		{"uppercase", []idField{{"user_id", "6F1C2A9E-3B4D-4C5E-8F7A-1B2C3D4E5F60"}}, false},
+		{"several valid", []idField{{"chat_id", testUserID}, {"user_id", testOtherID}}, false},
+		{"empty", []idField{{"user_id", ""}}, true},
+		{"not a uuid", []idField{{"user_id", "alice"}}, true},
+		{"sql", []idField{{"user_id", "1' OR '1'='1"}}, true},
+		{"second invalid", []idField{{"chat_id", testUserID}, {"user_id", "nope"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIDs(tt.fields...)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("validateIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidID) {
+				t.Errorf("validateIDs() error = %v, want ErrInvalidID", err)
+			}
+		})
+	}
+}
+
+func TestCanonicalID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want string
+	}{
+		{"canonical", testUserID, testUserID},
+		{"uppercase", "6F1C2A9E-3B4D-4C5E-8F7A-1B2C3D4E5F60", testUserID},
+		{"braces", "{6f1c2a9e-3b4d-4c5e-8f7a-1b2c3d4e5f60}", testUserID},
+		{"urn", "urn:uuid:6f1c2a9e-3b4d-4c5e-8f7a-1b2c3d4e5f60", testUserID},
+		{"no hyphens", "6f1c2a9e3b4d4c5e8f7a1b2c3d4e5f60", testUserID},
+		{"not a uuid", "alice", "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canonicalID(tt.id); got != tt.want {
+				t.Errorf("canonicalID(%q) = %q, want %q", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	// A skin-toned thumbs up, a family joined by zero-width joiners, a space
+	// and "héllo" with a combining accent.
+	content := "👍🏽👨\u200d👩\u200d👧 he\u0301llo"
+
+	tests := []struct {
+		mode string
+		want int
+	}{
+		{LengthModeRunes, 14},
+		{LengthModeUTF16, 19},
+		{LengthModeGraphemes, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if got := contentLength(content, tt.mode); got != tt.want {
+				t.Errorf("contentLength(%q) = %d, want %d", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateContentLengthMode(t *testing.T) {
+	content := "👍🏽👍🏽👍🏽"
+
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{"bogus", false},
+		{LengthModeRunes, false},
+		{LengthModeUTF16, true},
+		{LengthModeGraphemes, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			// Six runes, twelve UTF-16 units and three graphemes.
+			s := &chatService{config: Config{MaxMessageLength: 6, LengthMode: tt.mode}}
+			err := s.validateContent(content)
+			if tt.wantErr != errors.Is(err, ErrMessageTooLong) {
+				t.Errorf("validateContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTruncateContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		max     int
+		mode    string
+		marker  string
+		want    string
+	}{
+		{"fits", "hello", 5, LengthModeRunes, "…", "hello"},
+		{"ascii", "hello world", 8, LengthModeRunes, "…", "hello w…"},
+		{"multibyte runes", "привет мир", 5, LengthModeRunes, "…", "прив…"},
+		{"keeps combining accent", "he\u0301llo", 3, LengthModeRunes, "…", "h…"},
+		{"no surrogate split", "a👍b", 3, LengthModeUTF16, "…", "a…"},
+		{"graphemes", "👍🏽👍🏽👍🏽", 2, LengthModeGraphemes, "…", "👍🏽…"},
+		{"whole family or nothing", "👨\u200d👩\u200d👧 hi", 4, LengthModeRunes, "…", "…"},
+		{"multi-char marker", "hello world", 8, LengthModeRunes, "[...]", "hel[...]"},
+		{"marker too long", "hello world", 2, LengthModeRunes, "[...]", "he"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateContent(tt.content, tt.max, tt.mode, tt.marker)
+			if got != tt.want {
+				t.Errorf("truncateContent() = %q, want %q", got, tt.want)
+			}
+			if n := contentLength(got, tt.mode); n > tt.max {
+				t.Errorf("truncated to %d characters, over the %d maximum", n, tt.max)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+-- Schema as previously created by InitializeTables. Every statement is guarded,
+-- so databases set up before migrations were versioned upgrade in place.
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS chats (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id1 UUID NOT NULL,
+    user_id2 UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(user_id1, user_id2)
+);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_chat_id ON messages(chat_id);
+CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at);
+CREATE INDEX IF NOT EXISTS idx_messages_chat_created ON messages(chat_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_chats_user1 ON chats(user_id1);
+CREATE INDEX IF NOT EXISTS idx_chats_user2 ON chats(user_id2);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_hash VARCHAR(32);
+CREATE INDEX IF NOT EXISTS idx_messages_content_hash ON messages(content_hash);
+CREATE INDEX IF NOT EXISTS idx_messages_unread ON messages(chat_id) WHERE read_at IS NULL;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS language VARCHAR(8);
+
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS source VARCHAR(32);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id UUID NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    chat_id UUID,
+    target_id UUID,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_chat ON audit_log(chat_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'chats' AND column_name = 'message_count'
+    ) THEN
+        ALTER TABLE chats ADD COLUMN message_count BIGINT NOT NULL DEFAULT 0;
+        UPDATE chats c SET message_count = (SELECT COUNT(*) FROM messages m WHERE m.chat_id = c.id);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'chats' AND column_name = 'created_by'
+    ) THEN
+        ALTER TABLE chats ADD COLUMN created_by UUID;
+        UPDATE chats SET created_by = user_id1;
+        ALTER TABLE chats ALTER COLUMN created_by SET NOT NULL;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'chats' AND column_name = 'bytes_used'
+    ) THEN
+        ALTER TABLE chats ADD COLUMN bytes_used BIGINT NOT NULL DEFAULT 0;
+        UPDATE chats c SET bytes_used = (
+            SELECT COALESCE(SUM(octet_length(m.content)), 0) FROM messages m WHERE m.chat_id = c.id
+        );
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.tables
+        WHERE table_schema = current_schema() AND table_name = 'chat_participants'
+    ) THEN
+        CREATE TABLE chat_participants (
+            chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+            user_id UUID NOT NULL,
+            joined_at TIMESTAMP NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (chat_id, user_id)
+        );
+        CREATE INDEX idx_chat_participants_user ON chat_participants(user_id);
+
+        INSERT INTO chat_participants (chat_id, user_id, joined_at)
+        SELECT id, user_id1, created_at FROM chats
+        UNION
+        SELECT id, user_id2, created_at FROM chats;
+    END IF;
+END $$;
+
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS name VARCHAR(255);
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS type VARCHAR(16) NOT NULL DEFAULT 'direct';
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'chats' AND column_name = 'is_group'
+    ) THEN
+        UPDATE chats SET type = 'group' WHERE is_group;
+        ALTER TABLE chats DROP COLUMN is_group;
+    END IF;
+END $$;
+ALTER TABLE chats ALTER COLUMN user_id1 DROP NOT NULL;
+ALTER TABLE chats ALTER COLUMN user_id2 DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    emoji VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (message_id, user_id, emoji)
+);
+
+ALTER TABLE chat_participants ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.tables
+        WHERE table_schema = current_schema() AND table_name = 'message_reads'
+    ) THEN
+        CREATE TABLE message_reads (
+            message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+            user_id UUID NOT NULL,
+            read_at TIMESTAMP NOT NULL DEFAULT NOW(),
+            PRIMARY KEY (message_id, user_id)
+        );
+
+        INSERT INTO message_reads (message_id, user_id, read_at)
+        SELECT m.id, p.user_id, m.read_at
+        FROM messages m
+        JOIN chats c ON c.id = m.chat_id AND c.type = 'direct'
+        JOIN chat_participants p ON p.chat_id = m.chat_id AND p.user_id <> m.sender_id
+        WHERE m.read_at IS NOT NULL;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGSERIAL PRIMARY KEY,
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    mime_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    filename VARCHAR(255) NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_attachments_message_id ON attachments(message_id);
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+    chat_id UUID NOT NULL REFERENCES chats(id) ON DELETE CASCADE,
+    pinned_by UUID NOT NULL,
+    pinned_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pinned_messages_chat ON pinned_messages(chat_id, pinned_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS pinned_messages;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_reply_to ON messages(reply_to_message_id, created_at) WHERE reply_to_message_id IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN IF EXISTS reply_to_message_id;
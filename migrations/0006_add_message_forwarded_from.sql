@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS forwarded_from_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE messages DROP COLUMN IF EXISTS forwarded_from_message_id;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chat_settings ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE chat_settings DROP COLUMN IF EXISTS archived;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP;
+
+UPDATE messages SET delivered_at = read_at WHERE delivered_at IS NULL AND read_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_messages_undelivered ON messages(chat_id) WHERE delivered_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_messages_undelivered;
+ALTER TABLE messages DROP COLUMN IF EXISTS delivered_at;
@@ -0,0 +1,25 @@
+-- bytes_used now includes attachment sizes. Add those of messages sent
+-- before the change so deleting them doesn't drive the counter negative.
+-- +goose Up
+UPDATE chats c
+SET bytes_used = c.bytes_used + t.total
+FROM (
+    SELECT m.chat_id, SUM(a.size_bytes) AS total
+    FROM attachments a
+    JOIN messages m ON m.id = a.message_id
+    WHERE m.deleted_at IS NULL
+    GROUP BY m.chat_id
+) t
+WHERE c.id = t.chat_id;
+
+-- +goose Down
+UPDATE chats c
+SET bytes_used = GREATEST(c.bytes_used - t.total, 0)
+FROM (
+    SELECT m.chat_id, SUM(a.size_bytes) AS total
+    FROM attachments a
+    JOIN messages m ON m.id = a.message_id
+    WHERE m.deleted_at IS NULL
+    GROUP BY m.chat_id
+) t
+WHERE c.id = t.chat_id;
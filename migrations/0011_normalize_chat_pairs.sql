@@ -0,0 +1,15 @@
+-- Direct chats store their pair smaller user ID first, so each pair has one
+-- spelling and a unique index over it rules out reversed duplicates. A pair
+-- whose reversed twin already exists can't be swapped without breaking
+-- UNIQUE(user_id1, user_id2), so the migration fails until such duplicates
+-- are merged by hand; database.check_pair_normalization lists how many.
+-- +goose Up
+UPDATE chats
+SET user_id1 = user_id2, user_id2 = user_id1
+WHERE user_id1 > user_id2;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chats_normalized_pair
+    ON chats (LEAST(user_id1, user_id2), GREATEST(user_id1, user_id2));
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_chats_normalized_pair;